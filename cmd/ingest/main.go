@@ -1,26 +1,60 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
 	"syscall"
+	"time"
 
 	"go-bot/config"
 	"go-bot/internal/ingest"
 	"go-bot/internal/llm"
+	"go-bot/internal/logging"
+	"go-bot/internal/rag"
+	"go-bot/internal/transport"
 	"go-bot/internal/vector"
 )
 
 func main() {
 	// Parse flags
-	filePath := flag.String("file", "Knowledgebase.json", "Path to the knowledge base JSON file")
+	var files fileList
+	flag.Var(&files, "file", "Knowledge base JSON file or glob pattern; repeatable (default Knowledgebase.json when neither -csv nor -md-dir is given)")
+	var csvFiles fileList
+	flag.Var(&csvFiles, "csv", "Knowledge base CSV file or glob pattern; repeatable")
+	csvColumns := flag.String("csv-columns", "", "CSV column mapping as field=header pairs, e.g. id=Key,answer=Body (fields: id, module, topic, roles, query_variations, answer)")
+	csvListSep := flag.String("csv-list-sep", ";", "Separator of the roles and query_variations cells in CSV files")
+	mdDir := flag.String("md-dir", "", "Directory of markdown/plain-text articles to ingest")
+	chunkSize := flag.Int("chunk-size", 1000, "Chunk size in characters for markdown ingestion")
+	chunkOverlap := flag.Int("chunk-overlap", 200, "Chunk overlap in characters for markdown ingestion")
+	mdRoles := flag.String("md-roles", rag.AllUsersRole, "Comma-separated roles that can see markdown articles")
+	prune := flag.Bool("prune", false, "Delete points whose IDs are no longer in the knowledge base, among the kinds of source (-file, -csv, -md-dir) ingested in this run")
+	dryRun := flag.Bool("dry-run", false, "Parse and embed everything without writing to Qdrant")
+	dedup := flag.Bool("dedup", true, "Skip entries whose text duplicates an earlier entry")
+	recreate := flag.Bool("recreate", false, "Delete and re-create the collection before ingesting (destroys all points)")
+	yes := flag.Bool("yes", false, "Skip the -recreate confirmation prompt")
 	flag.Parse()
 
+	if *recreate && *dryRun {
+		log.Fatal("-recreate cannot be combined with -dry-run")
+	}
+	columns, err := parseCSVColumns(*csvColumns)
+	if err != nil {
+		log.Fatalf("Invalid -csv-columns: %v", err)
+	}
+	columns.ListSeparator = *csvListSep
+
 	// Load config
 	cfg := config.Load()
+	logging.Setup(cfg.LogFormat, cfg.LogLevel)
 
 	if cfg.GroqAPIKey == "" {
 		log.Fatal("GROQ_API_KEY is required")
@@ -39,30 +73,273 @@ func main() {
 		cancel()
 	}()
 
+	// Qdrant and the embedder share one connection pool
+	httpTransport := transport.New(cfg.HTTPMaxIdleConns, cfg.HTTPMaxIdleConnsPerHost, cfg.HTTPIdleConnTimeout)
+
 	// Initialize clients
 	log.Println("Connecting to Qdrant...")
-	vectorClient, err := vector.NewClient(cfg.QdrantHost, cfg.QdrantPort, cfg.CollectionName, cfg.EmbeddingDim)
+	vectorClient, err := vector.NewClient(cfg.QdrantHost, cfg.QdrantHTTPPort, cfg.CollectionName, cfg.EmbeddingDim,
+		vector.WithTLS(cfg.QdrantUseTLS),
+		vector.WithAPIKey(cfg.QdrantAPIKey),
+		vector.WithTransport(httpTransport),
+		vector.WithHybrid(cfg.HybridSearch),
+		vector.WithPayloadIndexes(cfg.PayloadIndexes),
+	)
 	if err != nil {
 		log.Fatalf("Failed to create vector client: %v", err)
 	}
 	defer vectorClient.Close()
 
-	// Ensure collection exists
-	if err := vectorClient.EnsureCollection(ctx); err != nil {
-		log.Fatalf("Failed to ensure collection: %v", err)
+	// Wait for Qdrant, which may still be starting alongside us
+	if err := vectorClient.WaitReady(ctx, cfg.QdrantConnectTimeout); err != nil {
+		log.Fatalf("Failed to connect to Qdrant: %v", err)
+	}
+
+	// Modules with collections of their own get them set up like the
+	// default one
+	collections := []*vector.Client{vectorClient}
+	for _, name := range moduleCollectionNames(cfg.ModuleCollections, cfg.CollectionName) {
+		collections = append(collections, vectorClient.ForCollection(name))
+	}
+
+	// Ensure collections exist; a dry run only checks existing ones
+	for _, c := range collections {
+		if *dryRun {
+			if err := c.ValidateDimension(ctx); errors.Is(err, vector.ErrDimensionMismatch) {
+				log.Fatalf("Embedding dimension mismatch: %v", err)
+			} else if err != nil {
+				log.Printf("Warning: could not validate collection %s: %v", c.CollectionName(), err)
+			}
+		} else if *recreate {
+			if !*yes && !confirm(fmt.Sprintf("Delete every point in collection %q and re-create it?", c.CollectionName())) {
+				log.Fatal("Aborted")
+			}
+			if err := c.RecreateCollection(ctx); err != nil {
+				log.Fatalf("Failed to recreate collection %s: %v", c.CollectionName(), err)
+			}
+		} else {
+			if err := c.EnsureCollection(ctx); err != nil {
+				log.Fatalf("Failed to ensure collection %s: %v", c.CollectionName(), err)
+			}
+			if err := c.ValidateDimension(ctx); err != nil {
+				log.Fatalf("Failed to validate collection %s: %v", c.CollectionName(), err)
+			}
+		}
 	}
 
 	// Initialize embedder
-	embedder := llm.NewEmbedder(cfg.GroqAPIKey)
+	embedder, err := llm.NewEmbedder(cfg.EmbeddingProvider, cfg.EmbeddingURL, cfg.EmbeddingAPIKey, cfg.EmbeddingModel,
+		llm.WithEmbedConcurrency(cfg.EmbedConcurrency),
+		llm.WithEmbedTimeout(cfg.EmbedTimeout),
+		llm.WithEmbedTransport(httpTransport),
+		llm.WithEmbedRetry(cfg.EmbedMaxAttempts, cfg.EmbedRetryBaseDelay),
+		llm.WithEmbedWarmup(cfg.EmbedWarmupTimeout),
+	)
+	if err != nil {
+		log.Fatalf("Failed to create embedder: %v", err)
+	}
+	if cfg.EmbedNormalize {
+		embedder = llm.NewNormalizingEmbedder(embedder)
+	}
+
+	// Load the model up front so its load time isn't charged to the first batch
+	if cfg.EmbedWarmupTimeout > 0 {
+		if elapsed, err := llm.Warmup(ctx, embedder); err != nil {
+			log.Printf("Embedder warm-up failed after %v: %v", elapsed.Round(time.Millisecond), err)
+		} else {
+			log.Printf("Embedder warmed up in %v", elapsed.Round(time.Millisecond))
+		}
+	}
 
 	// Initialize ingestion service
-	ingestService := ingest.NewService(embedder, vectorClient)
+	ingestService := ingest.NewService(embedder, vectorClient,
+		ingest.WithUpsertConcurrency(cfg.IngestUpsertConcurrency),
+		ingest.WithChunking(*chunkSize, *chunkOverlap),
+		ingest.WithDocumentRoles(splitRoles(*mdRoles)),
+		ingest.WithDedup(*dedup),
+		ingest.WithDryRun(*dryRun),
+		ingest.WithCSVColumns(columns),
+		ingest.WithModuleStores(vectorClient.ForModules(cfg.ModuleCollections)),
+	)
+
+	// Run ingestion; Knowledgebase.json is only the default when no other
+	// source is given
+	if len(files) == 0 && len(csvFiles) == 0 && *mdDir == "" {
+		files = fileList{"Knowledgebase.json"}
+	}
+	paths, err := files.expand()
+	if err != nil {
+		log.Fatalf("Invalid -file: %v", err)
+	}
+	csvPaths, err := csvFiles.expand()
+	if err != nil {
+		log.Fatalf("Invalid -csv: %v", err)
+	}
+
+	// A dry run checks every source before failing so CI reports all
+	// problems at once.
+	verb := "Ingested"
+	if *dryRun {
+		verb = "Would ingest"
+	}
+	total, failed := 0, 0
+	fail := func(format string, args ...interface{}) {
+		if !*dryRun {
+			log.Fatalf(format, args...)
+		}
+		log.Printf(format, args...)
+		failed++
+	}
+
+	for _, path := range paths {
+		log.Printf("Starting ingestion from %s...", path)
+		n, err := ingestService.IngestJSONFile(ctx, path)
+		if err != nil {
+			fail("Ingestion of %s failed: %v", path, err)
+			continue
+		}
+		log.Printf("%s %d entries from %s", verb, n, path)
+		total += n
+	}
+
+	for _, path := range csvPaths {
+		log.Printf("Starting CSV ingestion from %s...", path)
+		n, err := ingestService.IngestCSVFile(ctx, path)
+		if err != nil {
+			fail("Ingestion of %s failed: %v", path, err)
+			continue
+		}
+		log.Printf("%s %d entries from %s", verb, n, path)
+		total += n
+	}
+
+	if *mdDir != "" {
+		log.Printf("Starting markdown ingestion from %s...", *mdDir)
+		n, err := ingestService.IngestMarkdownDir(ctx, *mdDir)
+		if err != nil {
+			fail("Markdown ingestion of %s failed: %v", *mdDir, err)
+		} else {
+			log.Printf("%s %d chunks from %s", verb, n, *mdDir)
+			total += n
+		}
+	}
 
-	// Run ingestion
-	log.Printf("Starting ingestion from %s...", *filePath)
-	if err := ingestService.IngestJSONFile(ctx, *filePath); err != nil {
-		log.Fatalf("Ingestion failed: %v", err)
+	log.Printf("%s %d entries in total from %d files", verb, total, len(paths)+len(csvPaths))
+	if failed > 0 {
+		log.Fatalf("Dry run found %d failing sources", failed)
 	}
 
+	if *prune {
+		removed, err := ingestService.Prune(ctx)
+		if err != nil {
+			log.Fatalf("Prune failed: %v", err)
+		}
+		if *dryRun {
+			log.Printf("Would prune %d stale points", removed)
+		} else {
+			log.Printf("Pruned %d stale points", removed)
+		}
+	}
+
+	if *dryRun {
+		log.Println("Dry run completed successfully; nothing was written")
+		return
+	}
 	log.Println("Ingestion completed successfully!")
 }
+
+// moduleCollectionNames returns the distinct collections modules are mapped
+// to, other than the default one, in sorted order.
+func moduleCollectionNames(collections map[string]string, defaultName string) []string {
+	seen := map[string]bool{defaultName: true}
+	var names []string
+	for _, name := range collections {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// confirm asks a yes/no question on stdin and reports whether the answer
+// was yes.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N]: ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// parseCSVColumns parses a -csv-columns value of comma-separated
+// field=header pairs. Unmapped fields keep their default headers.
+func parseCSVColumns(spec string) (ingest.CSVColumns, error) {
+	var columns ingest.CSVColumns
+	fields := map[string]*string{
+		"id":               &columns.ID,
+		"module":           &columns.Module,
+		"topic":            &columns.Topic,
+		"roles":            &columns.Roles,
+		"query_variations": &columns.QueryVariations,
+		"answer":           &columns.Answer,
+	}
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		field, header, ok := strings.Cut(pair, "=")
+		if !ok {
+			return columns, fmt.Errorf("%q is not a field=header pair", pair)
+		}
+		dst, known := fields[strings.TrimSpace(field)]
+		if !known {
+			return columns, fmt.Errorf("unknown field %q", field)
+		}
+		*dst = strings.TrimSpace(header)
+	}
+	return columns, nil
+}
+
+// fileList collects repeated -file flags.
+type fileList []string
+
+func (f *fileList) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *fileList) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// expand resolves glob patterns into file paths. An empty value is skipped
+// so -file "" still disables JSON ingestion.
+func (f fileList) expand() ([]string, error) {
+	var paths []string
+	for _, pattern := range f {
+		if pattern == "" {
+			continue
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("bad pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %q", pattern)
+		}
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
+
+// splitRoles parses a comma-separated role list, dropping empty items.
+func splitRoles(value string) []string {
+	var roles []string
+	for _, role := range strings.Split(value, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go-bot/config"
+	"go-bot/internal/logging"
+	"go-bot/internal/vector"
+)
+
+// pointRecord is one line of the dump.
+type pointRecord struct {
+	ID      string                 `json:"id"`
+	Payload map[string]interface{} `json:"payload,omitempty"`
+	Vector  []float32              `json:"vector,omitempty"`
+}
+
+func main() {
+	// Parse flags
+	pageSize := flag.Int("page-size", 100, "Points fetched per scroll request")
+	limit := flag.Int("limit", 0, "Maximum points to dump (0 dumps everything)")
+	idsOnly := flag.Bool("ids-only", false, "Dump only point IDs, without payloads")
+	pointID := flag.String("id", "", "Print a single point, including its vector, instead of dumping all")
+	module := flag.String("module", "", "Inspect the collection holding this module (see MODULE_COLLECTIONS)")
+	collectionFlag := flag.String("collection", "", "Inspect this collection instead of COLLECTION_NAME")
+	flag.Parse()
+
+	// Load config
+	cfg := config.Load()
+	logging.Setup(cfg.LogFormat, cfg.LogLevel)
+
+	collection := cfg.CollectionName
+	if name, ok := cfg.ModuleCollections[*module]; ok {
+		collection = name
+	}
+	if *collectionFlag != "" {
+		collection = *collectionFlag
+	}
+
+	// Setup context with cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		cancel()
+	}()
+
+	vectorClient, err := vector.NewClient(cfg.QdrantHost, cfg.QdrantHTTPPort, collection, cfg.EmbeddingDim,
+		vector.WithTLS(cfg.QdrantUseTLS),
+		vector.WithAPIKey(cfg.QdrantAPIKey),
+		vector.WithHybrid(cfg.HybridSearch),
+		vector.WithPayloadIndexes(cfg.PayloadIndexes),
+	)
+	if err != nil {
+		log.Fatalf("Failed to create vector client: %v", err)
+	}
+	defer vectorClient.Close()
+
+	if *pointID != "" {
+		point, err := vectorClient.GetPoint(ctx, *pointID)
+		if errors.Is(err, vector.ErrPointNotFound) {
+			log.Fatalf("No point with ID %s in %s", *pointID, collection)
+		} else if err != nil {
+			log.Fatalf("Get point failed: %v", err)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(pointRecord{ID: point.ID, Payload: point.Payload, Vector: point.Vector}); err != nil {
+			log.Fatalf("Write failed: %v", err)
+		}
+		return
+	}
+
+	// Dump every point as a JSON line on stdout
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	enc := json.NewEncoder(out)
+
+	var (
+		offset *uint64
+		dumped int
+	)
+	for {
+		size := *pageSize
+		if *limit > 0 {
+			size = min(size, *limit-dumped)
+		}
+		points, next, err := vectorClient.Scroll(ctx, offset, size, !*idsOnly)
+		if err != nil {
+			out.Flush()
+			log.Fatalf("Scroll failed: %v", err)
+		}
+		for _, p := range points {
+			if err := enc.Encode(pointRecord{ID: p.ID, Payload: p.Payload}); err != nil {
+				log.Fatalf("Write failed: %v", err)
+			}
+		}
+		dumped += len(points)
+
+		if next == nil || (*limit > 0 && dumped >= *limit) {
+			break
+		}
+		offset = next
+	}
+
+	log.Printf("Dumped %d points from %s", dumped, collection)
+}
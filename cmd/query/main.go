@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"go-bot/config"
+	"go-bot/internal/llm"
+	"go-bot/internal/logging"
+	"go-bot/internal/rag"
+	"go-bot/internal/ragconfig"
+	"go-bot/internal/transport"
+	"go-bot/internal/vector"
+)
+
+func main() {
+	// Parse flags
+	query := flag.String("q", "", "Question to ask; read from stdin when empty")
+	stream := flag.Bool("stream", false, "Stream the answer to stdout")
+	topK := flag.Int("top-k", 0, "Number of documents to retrieve (0 uses the default)")
+	language := flag.String("language", "", "Answer language, e.g. Spanish, es or auto (default English)")
+	seed := flag.Int("seed", -1, "Answer deterministically with this seed at temperature 0, for snapshots; negative uses DETERMINISTIC_SEED")
+	flag.Parse()
+
+	if *query == "" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatalf("Failed to read query from stdin: %v", err)
+		}
+		*query = string(data)
+	}
+	*query = strings.TrimSpace(*query)
+	if *query == "" {
+		log.Fatal("A query is required: pass -q or pipe it on stdin")
+	}
+
+	// Load config
+	cfg := config.Load()
+	logging.Setup(cfg.LogFormat, cfg.LogLevel)
+
+	if cfg.GroqAPIKey == "" {
+		log.Fatal("GROQ_API_KEY is required")
+	}
+	if err := llm.ValidateBaseURL(cfg.GroqBaseURL); err != nil {
+		log.Fatalf("GROQ_BASE_URL: %v", err)
+	}
+	if err := llm.ValidateStop(cfg.LLMStopSequences); err != nil {
+		log.Fatalf("LLM_STOP_SEQUENCES: %v", err)
+	}
+
+	// Setup context with cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		cancel()
+	}()
+
+	// Groq, Qdrant and the embedder share one connection pool
+	httpTransport := transport.New(cfg.HTTPMaxIdleConns, cfg.HTTPMaxIdleConnsPerHost, cfg.HTTPIdleConnTimeout)
+
+	// Initialize clients
+	vectorClient, err := vector.NewClient(cfg.QdrantHost, cfg.QdrantHTTPPort, cfg.CollectionName, cfg.EmbeddingDim,
+		vector.WithTLS(cfg.QdrantUseTLS),
+		vector.WithAPIKey(cfg.QdrantAPIKey),
+		vector.WithTransport(httpTransport),
+		vector.WithHybrid(cfg.HybridSearch),
+		vector.WithPayloadIndexes(cfg.PayloadIndexes),
+	)
+	if err != nil {
+		log.Fatalf("Failed to create vector client: %v", err)
+	}
+	defer vectorClient.Close()
+
+	llmClient := llm.NewClient(cfg.GroqAPIKey, cfg.GroqModel, cfg.Temperature,
+		llm.WithRetry(cfg.LLMMaxAttempts, cfg.LLMRetryBaseDelay),
+		llm.WithTimeouts(cfg.LLMRequestTimeout, cfg.LLMStreamIdleTimeout),
+		llm.WithTransport(httpTransport),
+		llm.WithBaseURL(cfg.GroqBaseURL),
+	)
+	embedder, err := llm.NewEmbedder(cfg.EmbeddingProvider, cfg.EmbeddingURL, cfg.EmbeddingAPIKey, cfg.EmbeddingModel,
+		llm.WithEmbedTimeout(cfg.EmbedTimeout),
+		llm.WithEmbedTransport(httpTransport),
+		llm.WithEmbedRetry(cfg.EmbedMaxAttempts, cfg.EmbedRetryBaseDelay),
+		llm.WithEmbedWarmup(cfg.EmbedWarmupTimeout),
+	)
+	if err != nil {
+		log.Fatalf("Failed to create embedder: %v", err)
+	}
+	if cfg.EmbedNormalize {
+		embedder = llm.NewNormalizingEmbedder(embedder)
+	}
+
+	// Initialize RAG service with the same settings as the server
+	ragOpts, err := ragconfig.Options(cfg, vectorClient, nil)
+	if err != nil {
+		log.Fatalf("Failed to configure RAG service: %v", err)
+	}
+	if *seed >= 0 {
+		ragOpts = append(ragOpts, rag.WithDeterministic(*seed))
+	}
+	ragService := rag.NewService(llmClient, embedder, vectorClient, ragOpts...)
+
+	opts := rag.QueryOptions{TopK: *topK, Language: *language}
+
+	if *stream {
+		result, err := ragService.StreamQuery(ctx, *query, opts, os.Stdout)
+		fmt.Println()
+		if err != nil {
+			log.Fatalf("Stream failed: %v", err)
+		}
+		if result != nil && result.Usage != nil {
+			fmt.Printf("\nTokens: %d (prompt %d, completion %d)\n",
+				result.Usage.TotalTokens, result.Usage.PromptTokens, result.Usage.CompletionTokens)
+		}
+		return
+	}
+
+	result, err := ragService.Query(ctx, *query, opts)
+	if err != nil {
+		log.Fatalf("Query failed: %v", err)
+	}
+
+	fmt.Println(result.Answer)
+	if len(result.Sources) > 0 {
+		fmt.Println("\nSources:")
+		for _, s := range result.Sources {
+			fmt.Printf("  %.4f  %s  [%s / %s] %s\n", s.Score, s.ID, s.Module, s.Topic, s.Citation)
+			if s.MatchedVariation != "" {
+				fmt.Printf("          matched %q\n", s.MatchedVariation)
+			}
+		}
+	}
+	fmt.Printf("\nConfidence: %.2f\n", result.Confidence)
+	if result.Usage != nil {
+		fmt.Printf("\nTokens: %d (prompt %d, completion %d)\n",
+			result.Usage.TotalTokens, result.Usage.PromptTokens, result.Usage.CompletionTokens)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"go-bot/internal/rag"
+)
+
+// publicPaths are served without authentication so probes keep working.
+var publicPaths = map[string]bool{
+	"/health": true,
+	"/ready":  true,
+}
+
+// caller is what the server knows about who sent a request, resolved from
+// its API key. Access is decided here, never from the request body.
+type caller struct {
+	// id identifies the caller: a hash of its API key, or its remote IP
+	// for anonymous requests.
+	id string
+
+	// roles filter the documents the caller may read; nil when role-based
+	// access is off.
+	roles []string
+
+	// modules are the only modules the caller may read; nil when its key
+	// isn't limited to modules.
+	modules []string
+
+	// isolated are the modules kept in collections of their own, which
+	// callers without a module grant may not read. Nil when module-based
+	// access is off.
+	isolated map[string]bool
+}
+
+// conversation scopes a client-chosen conversation ID to the caller, so
+// callers can't read or clear each other's history by guessing IDs.
+func (c caller) conversation(id string) string {
+	if id == "" {
+		return ""
+	}
+	return c.id + "/" + id
+}
+
+// errModuleForbidden is returned for requests scoped to a module the caller
+// may not read.
+var errModuleForbidden = errors.New("module not allowed for this API key")
+
+// scope returns the modules a request for requested may search. Without
+// requested modules it is the caller's grant, or nil for the default
+// collection only.
+func (c caller) scope(requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return c.modules, nil
+	}
+	for _, module := range requested {
+		if c.modules != nil && !slices.Contains(c.modules, module) {
+			return nil, errModuleForbidden
+		}
+		if c.modules == nil && c.isolated[module] {
+			return nil, errModuleForbidden
+		}
+	}
+	return requested, nil
+}
+
+type callerKey struct{}
+
+// callerFrom returns the caller stored by authMiddleware.
+func callerFrom(ctx context.Context) caller {
+	c, _ := ctx.Value(callerKey{}).(caller)
+	return c
+}
+
+// access resolves API keys to callers.
+type access struct {
+	keys    []string
+	roles   map[string][]string
+	modules map[string][]string
+
+	// isolated are the modules with collections of their own.
+	isolated map[string]bool
+}
+
+// validate checks that every key given roles or modules is an accepted API
+// key.
+func (a access) validate() error {
+	for key := range a.roles {
+		if !validKey(a.keys, key) {
+			return errors.New("API_KEY_ROLES lists a key missing from API_KEYS")
+		}
+	}
+	for key := range a.modules {
+		if !validKey(a.keys, key) {
+			return errors.New("API_KEY_MODULES lists a key missing from API_KEYS")
+		}
+	}
+	return nil
+}
+
+// callerFor returns the caller for a validated key, or for an anonymous
+// request when key is empty. With role-based access on, callers whose key
+// has no roles only see documents for all users. With module-based access
+// on, callers whose key has no modules can't read isolated modules.
+func (a access) callerFor(key string) caller {
+	var c caller
+	if len(a.roles) > 0 {
+		c.roles = []string{rag.AllUsersRole}
+		if roles := a.roles[key]; key != "" && len(roles) > 0 {
+			c.roles = roles
+		}
+	}
+	if len(a.modules) > 0 {
+		c.isolated = a.isolated
+		if modules := a.modules[key]; key != "" && len(modules) > 0 {
+			c.modules = modules
+		}
+	}
+	return c
+}
+
+// adminPrefix starts the operator routes, which are never served
+// anonymously.
+const adminPrefix = "/admin/"
+
+// authMiddleware requires an "Authorization: Bearer <key>" header matching
+// one of the configured keys, and stores the resolved caller in the request
+// context. With no keys configured authentication is disabled and every
+// request is anonymous, except that admin routes are refused with 403.
+func authMiddleware(a access, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(a.keys) == 0 && strings.HasPrefix(r.URL.Path, adminPrefix) {
+			http.Error(w, "Admin routes require API_KEYS", http.StatusForbidden)
+			return
+		}
+		if len(a.keys) == 0 || publicPaths[r.URL.Path] {
+			c := a.callerFor("")
+			c.id = "ip:" + remoteIP(r)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), callerKey{}, c)))
+			return
+		}
+
+		key, ok := bearerToken(r)
+		if !ok || !validKey(a.keys, key) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="go-bot"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		c := a.callerFor(key)
+		sum := sha256.Sum256([]byte(key))
+		c.id = "key:" + hex.EncodeToString(sum[:8])
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), callerKey{}, c)))
+	})
+}
+
+// bearerToken extracts the token from the Authorization header.
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// remoteIP returns the host part of the request's remote address.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// validKey compares against every key in constant time.
+func validKey(keys []string, key string) bool {
+	valid := false
+	for _, k := range keys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"go-bot/internal/rag"
+)
+
+// maxBatchQueries caps the number of queries in one batch request.
+const maxBatchQueries = 500
+
+// BatchRequest is the body of POST /chat/batch.
+type BatchRequest struct {
+	Queries []string `json:"queries"`
+}
+
+// batchHandler answers a batch of queries with bounded concurrency. Each
+// query gets its own entry in the response, in request order; a failed
+// query yields an entry with Error set instead of failing the batch.
+//
+// A batch costs the caller one rate-limit token per query, taken up front:
+// batches the caller's bucket can't cover are rejected with 429, and
+// batches larger than the burst with 422. A nil limiter disables this.
+func batchHandler(ragService *rag.Service, decoder bodyDecoder, concurrency int, limiter *rateLimiter) http.HandlerFunc {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req BatchRequest
+		if berr := decoder.decode(w, r, &req); berr != nil {
+			writeBodyError(w, berr)
+			return
+		}
+		if len(req.Queries) == 0 {
+			writeValidationError(w, []FieldError{{Field: "queries", Message: "is required"}})
+			return
+		}
+		if len(req.Queries) > maxBatchQueries {
+			writeValidationError(w, []FieldError{{Field: "queries", Message: fmt.Sprintf("must contain at most %d queries", maxBatchQueries)}})
+			return
+		}
+		if limiter != nil {
+			if len(req.Queries) > int(limiter.burst) {
+				writeValidationError(w, []FieldError{{Field: "queries", Message: fmt.Sprintf("must contain at most %d queries under the rate limit", int(limiter.burst))}})
+				return
+			}
+			if ok, wait := limiter.allowN(callerFrom(r.Context()).id, len(req.Queries), time.Now()); !ok {
+				writeTooManyRequests(w, wait)
+				return
+			}
+		}
+
+		// Batches outlive the server-wide write timeout; allow writing the
+		// response until the route deadline.
+		ctx := r.Context()
+		if deadline, ok := ctx.Deadline(); ok {
+			if err := http.NewResponseController(w).SetWriteDeadline(deadline.Add(5 * time.Second)); err != nil {
+				log.Printf("Batch write deadline: %v", err)
+			}
+		}
+
+		responses := make([]ChatResponse, len(req.Queries))
+		sem := make(chan struct{}, concurrency)
+		var wg sync.WaitGroup
+		for i, query := range req.Queries {
+			wg.Add(1)
+			go func(i int, query string) {
+				defer wg.Done()
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+				case <-ctx.Done():
+					responses[i] = ChatResponse{Error: ctx.Err().Error()}
+					return
+				}
+
+				chatReq := ChatRequest{Query: query}
+				if errs := chatReq.Validate(); len(errs) > 0 {
+					responses[i] = ChatResponse{Error: fmt.Sprintf("%s %s", errs[0].Field, errs[0].Message)}
+					return
+				}
+				opts, err := chatReq.queryOptions(callerFrom(ctx))
+				if err != nil {
+					responses[i] = ChatResponse{Error: err.Error()}
+					return
+				}
+				result, err := ragService.Query(ctx, query, opts)
+				if err != nil {
+					log.Printf("Batch query %d error: %v", i, err)
+					responses[i] = ChatResponse{Error: err.Error()}
+					return
+				}
+				recordUsage(ctx, result.Usage)
+				responses[i] = newChatResponse(result)
+			}(i, query)
+		}
+		wg.Wait()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(responses)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Error codes returned when a request body can't be decoded.
+const (
+	codeBodyTooLarge  = "body_too_large"
+	codeMalformedJSON = "malformed_json"
+	codeUnknownField  = "unknown_field"
+)
+
+// ErrorResponse is returned for requests rejected before validation.
+type ErrorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// bodyError describes why a request body was rejected.
+type bodyError struct {
+	status  int
+	code    string
+	message string
+}
+
+// bodyDecoder decodes JSON request bodies up to maxBytes. In strict mode,
+// fields the request type doesn't define are rejected.
+type bodyDecoder struct {
+	maxBytes int64
+	strict   bool
+}
+
+// lenient returns a copy of d that accepts unknown fields, for APIs whose
+// clients send fields we ignore.
+func (d bodyDecoder) lenient() bodyDecoder {
+	d.strict = false
+	return d
+}
+
+// decode reads r's body into v.
+func (d bodyDecoder) decode(w http.ResponseWriter, r *http.Request, v interface{}) *bodyError {
+	body := r.Body
+	if d.maxBytes > 0 {
+		body = http.MaxBytesReader(w, r.Body, d.maxBytes)
+	}
+	dec := json.NewDecoder(body)
+	if d.strict {
+		dec.DisallowUnknownFields()
+	}
+
+	err := dec.Decode(v)
+	if err == nil {
+		return nil
+	}
+
+	var (
+		maxBytesErr *http.MaxBytesError
+		syntaxErr   *json.SyntaxError
+		typeErr     *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return &bodyError{http.StatusRequestEntityTooLarge, codeBodyTooLarge,
+			fmt.Sprintf("request body must be at most %d bytes", maxBytesErr.Limit)}
+	case errors.As(err, &syntaxErr):
+		return &bodyError{http.StatusBadRequest, codeMalformedJSON,
+			fmt.Sprintf("malformed JSON at offset %d", syntaxErr.Offset)}
+	case errors.As(err, &typeErr):
+		return &bodyError{http.StatusBadRequest, codeMalformedJSON,
+			fmt.Sprintf("field %s must be %s", typeErr.Field, typeErr.Type)}
+	case errors.Is(err, io.EOF):
+		return &bodyError{http.StatusBadRequest, codeMalformedJSON, "request body is empty"}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &bodyError{http.StatusBadRequest, codeMalformedJSON, "request body is truncated"}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no typed error for unknown fields
+		return &bodyError{http.StatusBadRequest, codeUnknownField,
+			"unknown field " + strings.TrimPrefix(err.Error(), "json: unknown field ")}
+	default:
+		return &bodyError{http.StatusBadRequest, codeMalformedJSON, "invalid request body"}
+	}
+}
+
+// writeBodyError writes a rejected body's status, code and message.
+func writeBodyError(w http.ResponseWriter, e *bodyError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.status)
+	json.NewEncoder(w).Encode(ErrorResponse{
+		Error: e.message,
+		Code:  e.code,
+	})
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// corsMiddleware adds CORS headers for allowed origins. The request's Origin
+// is echoed back only when it is in allowedOrigins; "*" allows any origin
+// and is meant for local development. Disallowed origins get no CORS
+// headers, so browsers block the response. Outside allow-all mode every
+// response varies by Origin, so caches don't serve one origin's response to
+// another.
+func corsMiddleware(allowedOrigins, methods, headers []string, next http.Handler) http.Handler {
+	allowAll := false
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		if origin == "*" {
+			allowAll = true
+		}
+		allowed[strings.TrimRight(origin, "/")] = true
+	}
+	allowMethods := strings.Join(methods, ", ")
+	allowHeaders := strings.Join(headers, ", ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if !allowAll {
+			w.Header().Add("Vary", "Origin")
+		}
+		switch {
+		case allowAll:
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		case origin != "" && allowed[origin]:
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		default:
+			origin = ""
+		}
+		if allowAll || origin != "" {
+			w.Header().Set("Access-Control-Allow-Methods", allowMethods)
+			w.Header().Set("Access-Control-Allow-Headers", allowHeaders)
+		}
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
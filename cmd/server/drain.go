@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// finishReasonShutdown ends streams that were cut short by a server
+// shutdown.
+const finishReasonShutdown = "shutdown"
+
+// streamStopGrace is how long interrupted streams get to flush and write
+// their finish event.
+const streamStopGrace = 5 * time.Second
+
+// streamTracker counts active streaming responses so shutdown can wait for
+// them, and interrupts the stragglers cleanly when waiting runs out.
+type streamTracker struct {
+	wg     sync.WaitGroup
+	active atomic.Int64
+
+	stopCtx context.Context
+	stop    context.CancelFunc
+}
+
+func newStreamTracker() *streamTracker {
+	stopCtx, stop := context.WithCancel(context.Background())
+	return &streamTracker{stopCtx: stopCtx, stop: stop}
+}
+
+// track registers a stream. The returned context is cancelled if shutdown
+// interrupts the stream; done must be called when the stream ends.
+func (t *streamTracker) track(ctx context.Context) (context.Context, func()) {
+	t.wg.Add(1)
+	t.active.Add(1)
+	ctx, cancel := context.WithCancel(ctx)
+	stopWatch := context.AfterFunc(t.stopCtx, cancel)
+	return ctx, func() {
+		stopWatch()
+		cancel()
+		t.active.Add(-1)
+		t.wg.Done()
+	}
+}
+
+// interrupted reports whether the stream with ctx was cut short by shutdown.
+func (t *streamTracker) interrupted(ctx context.Context) bool {
+	return ctx.Err() != nil && t.stopCtx.Err() != nil
+}
+
+// drain waits up to timeout for active streams to finish on their own, then
+// interrupts the rest and waits up to streamStopGrace for them to end. It
+// returns how many streams were active and how many had to be interrupted.
+func (t *streamTracker) drain(timeout time.Duration) (active, interrupted int) {
+	active = int(t.active.Load())
+
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return active, 0
+	case <-time.After(timeout):
+	}
+
+	interrupted = int(t.active.Load())
+	t.stop()
+	select {
+	case <-done:
+	case <-time.After(streamStopGrace):
+	}
+	return active, interrupted
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// deterministic reports whether the answer to req is reproducible, i.e.
+// sampled at temperature 0 with a seed, taking each from the request or
+// else from the service settings.
+func deterministic(req ChatRequest, temperature float64, seed int) bool {
+	hasSeed := req.Seed != nil
+	if seed >= 0 {
+		temperature, hasSeed = 0, true
+	}
+	if req.Temperature != nil {
+		temperature = *req.Temperature
+	}
+	return hasSeed && temperature == 0
+}
+
+// computeETag returns a strong ETag over the response body.
+func computeETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header matches the ETag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeWithETag writes a JSON body with an ETag, or 304 when the client
+// already has it.
+func writeWithETag(w http.ResponseWriter, r *http.Request, body []byte) {
+	etag := computeETag(body)
+	w.Header().Set("ETag", etag)
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteWithETag(t *testing.T) {
+	body := []byte(`{"answer":"Run payroll from the dashboard."}`)
+	etag := computeETag(body)
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		wantStatus  int
+		wantBody    bool
+	}{
+		{name: "no If-None-Match", wantStatus: http.StatusOK, wantBody: true},
+		{name: "matching", ifNoneMatch: etag, wantStatus: http.StatusNotModified},
+		{name: "matching weak", ifNoneMatch: "W/" + etag, wantStatus: http.StatusNotModified},
+		{name: "matching one of several", ifNoneMatch: `"stale", ` + etag, wantStatus: http.StatusNotModified},
+		{name: "wildcard", ifNoneMatch: "*", wantStatus: http.StatusNotModified},
+		{name: "stale", ifNoneMatch: `"stale"`, wantStatus: http.StatusOK, wantBody: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/chat", nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			rec := httptest.NewRecorder()
+			writeWithETag(rec, req, body)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("ETag"); got != etag {
+				t.Errorf("ETag = %q, want %q", got, etag)
+			}
+			if gotBody := rec.Body.Len() > 0; gotBody != tt.wantBody {
+				t.Errorf("body = %q, want body %v", rec.Body, tt.wantBody)
+			}
+		})
+	}
+
+	if other := computeETag([]byte(`{"answer":"Something else."}`)); other == etag {
+		t.Error("different bodies share an ETag")
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	zero, warm := 0.0, 0.7
+	seed := 42
+
+	tests := []struct {
+		name        string
+		req         ChatRequest
+		temperature float64
+		seed        int
+		want        bool
+	}{
+		{name: "default sampling", temperature: 0.7, seed: -1},
+		{name: "request seed at temperature zero", req: ChatRequest{Seed: &seed, Temperature: &zero}, temperature: 0.7, seed: -1, want: true},
+		{name: "request seed at service temperature zero", req: ChatRequest{Seed: &seed}, seed: -1, want: true},
+		{name: "request seed with warm temperature", req: ChatRequest{Seed: &seed}, temperature: 0.7, seed: -1},
+		{name: "service seed", temperature: 0.7, seed: 7, want: true},
+		{name: "service seed overridden by request temperature", req: ChatRequest{Temperature: &warm}, seed: 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := deterministic(tt.req, tt.temperature, tt.seed); got != tt.want {
+				t.Errorf("deterministic = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// keepAliveComment is an SSE comment line; clients and proxies ignore its
+// content but see traffic on the connection.
+const keepAliveComment = ": keep-alive\n\n"
+
+// keepAliveWriter sits in front of a stream writer and, until the first
+// answer bytes arrive, periodically writes an SSE comment straight to the
+// response so proxies don't close the idle connection. The stream writer
+// must frame the answer as SSE events too, as flushWriter and chunkWriter
+// do; in a plain-text body the comments would end up in the answer.
+type keepAliveWriter struct {
+	next io.Writer
+	w    http.ResponseWriter
+	f    http.Flusher
+
+	mu      sync.Mutex
+	started bool
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// newKeepAliveWriter starts sending keep-alive comments every interval. A
+// zero interval disables them. stop must be called before the handler
+// returns.
+func newKeepAliveWriter(next io.Writer, w http.ResponseWriter, f http.Flusher, interval time.Duration) *keepAliveWriter {
+	kw := &keepAliveWriter{next: next, w: w, f: f, stop: make(chan struct{}), stopped: make(chan struct{})}
+	if interval <= 0 {
+		close(kw.stopped)
+		return kw
+	}
+
+	go func() {
+		defer close(kw.stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-kw.stop:
+				return
+			case <-ticker.C:
+				if !kw.ping() {
+					return
+				}
+			}
+		}
+	}()
+	return kw
+}
+
+// ping writes a keep-alive comment, reporting whether more are needed.
+func (kw *keepAliveWriter) ping() bool {
+	kw.mu.Lock()
+	defer kw.mu.Unlock()
+	if kw.started {
+		return false
+	}
+	if _, err := io.WriteString(kw.w, keepAliveComment); err != nil {
+		return false
+	}
+	kw.f.Flush()
+	return true
+}
+
+func (kw *keepAliveWriter) Write(p []byte) (int, error) {
+	kw.mu.Lock()
+	defer kw.mu.Unlock()
+	kw.started = true
+	return kw.next.Write(p)
+}
+
+// stopKeepAlive ends the keep-alive comments and waits for any in flight.
+func (kw *keepAliveWriter) stopKeepAlive() {
+	select {
+	case <-kw.stop:
+	default:
+		close(kw.stop)
+	}
+	<-kw.stopped
+}
@@ -1,77 +1,401 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"go-bot/config"
+	"go-bot/internal/cache"
 	"go-bot/internal/llm"
+	"go-bot/internal/logging"
 	"go-bot/internal/rag"
+	"go-bot/internal/ragconfig"
+	"go-bot/internal/transport"
 	"go-bot/internal/vector"
+	"go-bot/internal/version"
 )
 
 // ChatRequest represents an incoming chat request.
 type ChatRequest struct {
-	Query  string `json:"query"`
-	Stream bool   `json:"stream"`
+	Query   string   `json:"query"`
+	Stream  bool     `json:"stream"`
+	TopK    int      `json:"top_k,omitempty"`
+	Modules []string `json:"modules,omitempty"`
+
+	// Roles is ignored: the caller's roles come from its API key (see
+	// API_KEY_ROLES). The field is still accepted for older clients.
+	Roles []string `json:"roles,omitempty"`
+
+	ConversationID string   `json:"conversation_id,omitempty"`
+	ScoreThreshold *float32 `json:"score_threshold,omitempty"`
+	MaxTokens      int      `json:"max_tokens,omitempty"`
+
+	// Seed and Temperature override LLM sampling; a seed with temperature
+	// 0 gives reproducible answers for snapshot tests.
+	Seed        *int     `json:"seed,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+
+	// Language is the answer language, e.g. "Spanish" or "es", or "auto"
+	// to answer in the question's language. Defaults to English.
+	Language string `json:"language,omitempty"`
+
+	// Debug asks for the LLM's context and messages in the response. It is
+	// ignored unless the server enables debug responses.
+	Debug bool `json:"debug,omitempty"`
+}
+
+// queryOptions converts request overrides into RAG query options, scoped to
+// what c may read. It returns errModuleForbidden when the request names a
+// module c may not read.
+func (req ChatRequest) queryOptions(c caller) (rag.QueryOptions, error) {
+	modules, err := c.scope(req.Modules)
+	if err != nil {
+		return rag.QueryOptions{}, err
+	}
+	return rag.QueryOptions{
+		TopK:              req.TopK,
+		Modules:           modules,
+		ModulesRestricted: c.modules != nil,
+		Roles:             c.roles,
+
+		ConversationID: c.conversation(req.ConversationID),
+		ScoreThreshold: req.ScoreThreshold,
+		MaxTokens:      req.MaxTokens,
+		Seed:           req.Seed,
+		Temperature:    req.Temperature,
+		Language:       req.Language,
+	}, nil
 }
 
 // ChatResponse represents the response.
 type ChatResponse struct {
-	Answer  string   `json:"answer"`
-	Sources []Source `json:"sources,omitempty"`
+	Answer   string   `json:"answer"`
+	Overview string   `json:"overview,omitempty"`
+	Steps    []string `json:"steps,omitempty"`
+	Sources  []Source `json:"sources,omitempty"`
+
+	// FinishReason is "length" when the answer was cut off.
+	FinishReason string `json:"finish_reason,omitempty"`
+
+	// Confidence (0-1) is derived from the retrieval scores of the sources.
+	Confidence float32 `json:"confidence"`
+
+	// Broadened is true when nothing relevant was found in the requested
+	// modules and the sources come from other modules.
+	Broadened bool `json:"broadened,omitempty"`
+
+	// Error is set instead of an answer for failed batch entries.
+	Error string `json:"error,omitempty"`
+
+	// Debug is the exact input the LLM saw, on request when enabled.
+	Debug *rag.DebugInfo `json:"debug,omitempty"`
+}
+
+// newChatResponse converts a RAG result into the API response.
+func newChatResponse(result *rag.QueryResult) ChatResponse {
+	sources := make([]Source, len(result.Sources))
+	for i, s := range result.Sources {
+		sources[i] = Source{
+			ID:               s.ID,
+			Module:           s.Module,
+			Topic:            s.Topic,
+			Score:            s.Score,
+			Citation:         s.Citation,
+			MatchedVariation: s.MatchedVariation,
+		}
+	}
+
+	return ChatResponse{
+		Answer:       result.Answer,
+		Overview:     result.Overview,
+		Steps:        result.Steps,
+		Sources:      sources,
+		FinishReason: result.FinishReason,
+		Confidence:   result.Confidence,
+		Broadened:    result.Broadened,
+		Debug:        result.Debug,
+	}
 }
 
 // Source is a simplified source reference.
 type Source struct {
-	ID     string  `json:"id"`
-	Module string  `json:"module"`
-	Topic  string  `json:"topic"`
-	Score  float32 `json:"score"`
+	ID               string  `json:"id"`
+	Module           string  `json:"module"`
+	Topic            string  `json:"topic"`
+	Score            float32 `json:"score"`
+	Citation         string  `json:"citation,omitempty"`
+	MatchedVariation string  `json:"matched_variation,omitempty"`
+}
+
+// HealthResponse reports liveness. It is served without authentication, so
+// deployment details are left to /admin/stats.
+type HealthResponse struct {
+	Status  string `json:"status"`
+	Version string `json:"version"`
+}
+
+// AdminStatsResponse reports what is deployed and every collection the
+// server reads: the default one first, then the module collections.
+type AdminStatsResponse struct {
+	Version     string                   `json:"version"`
+	Commit      string                   `json:"commit"`
+	Uptime      string                   `json:"uptime"`
+	Model       string                   `json:"model"`
+	Collection  string                   `json:"collection"`
+	Collections []*vector.CollectionInfo `json:"collections"`
+}
+
+// EstimateResponse reports the estimated prompt size for a query.
+type EstimateResponse struct {
+	SystemTokens   int `json:"system_tokens"`
+	ContextTokens  int `json:"context_tokens"`
+	QuestionTokens int `json:"question_tokens"`
+	TotalTokens    int `json:"total_tokens"`
+}
+
+// DiagnosticCandidate is a retrieval candidate in a diagnostic response.
+type DiagnosticCandidate struct {
+	ID               string  `json:"id"`
+	Module           string  `json:"module"`
+	Topic            string  `json:"topic"`
+	Score            float32 `json:"score"`
+	BelowThreshold   bool    `json:"below_threshold"`
+	NearestVariation string  `json:"nearest_variation,omitempty"`
+	LexicalScore     float64 `json:"lexical_score"`
+}
+
+// DiagnosticResponse explains a retrieval miss. Candidates the caller's
+// roles or modules exclude are only counted.
+type DiagnosticResponse struct {
+	Query            string                `json:"query"`
+	ScoreThreshold   float32               `json:"score_threshold"`
+	Filtered         bool                  `json:"filtered"`
+	Cause            string                `json:"cause"`
+	Candidates       []DiagnosticCandidate `json:"candidates"`
+	ExcludedByFilter int                   `json:"excluded_by_filter"`
 }
 
 func main() {
+	startTime := time.Now()
+
 	// Load config
 	cfg := config.Load()
+	logging.Setup(cfg.LogFormat, cfg.LogLevel)
 
 	if cfg.GroqAPIKey == "" {
 		log.Fatal("GROQ_API_KEY is required")
 	}
+	if err := llm.ValidateBaseURL(cfg.GroqBaseURL); err != nil {
+		log.Fatalf("GROQ_BASE_URL: %v", err)
+	}
+	if err := llm.ValidateStop(cfg.LLMStopSequences); err != nil {
+		log.Fatalf("LLM_STOP_SEQUENCES: %v", err)
+	}
+	auth := access{keys: cfg.APIKeys, roles: cfg.APIKeyRoles, modules: cfg.APIKeyModules, isolated: make(map[string]bool)}
+	for module := range cfg.ModuleCollections {
+		auth.isolated[module] = true
+	}
+	if err := auth.validate(); err != nil {
+		log.Fatalf("Invalid access configuration: %v", err)
+	}
 
 	// Setup context
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Groq, Qdrant and the embedder share one connection pool
+	httpTransport := transport.New(cfg.HTTPMaxIdleConns, cfg.HTTPMaxIdleConnsPerHost, cfg.HTTPIdleConnTimeout)
+
 	// Initialize clients
 	log.Println("Connecting to Qdrant...")
-	vectorClient, err := vector.NewClient(cfg.QdrantHost, cfg.QdrantPort, cfg.CollectionName, cfg.EmbeddingDim)
+	vectorClient, err := vector.NewClient(cfg.QdrantHost, cfg.QdrantHTTPPort, cfg.CollectionName, cfg.EmbeddingDim,
+		vector.WithTLS(cfg.QdrantUseTLS),
+		vector.WithAPIKey(cfg.QdrantAPIKey),
+		vector.WithTransport(httpTransport),
+		vector.WithHybrid(cfg.HybridSearch),
+		vector.WithPayloadIndexes(cfg.PayloadIndexes),
+	)
 	if err != nil {
 		log.Fatalf("Failed to create vector client: %v", err)
 	}
 	defer vectorClient.Close()
 
+	// Wait for Qdrant, which may still be starting alongside us
+	if err := vectorClient.WaitReady(ctx, cfg.QdrantConnectTimeout); err != nil {
+		log.Fatalf("Failed to connect to Qdrant: %v", err)
+	}
+	if err := vectorClient.ValidateDimension(ctx); errors.Is(err, vector.ErrDimensionMismatch) {
+		log.Fatalf("Embedding dimension mismatch: %v", err)
+	} else if err != nil {
+		log.Printf("Warning: could not validate embedding dimension: %v", err)
+	}
+
 	// Initialize LLM and embedder
-	llmClient := llm.NewClient(cfg.GroqAPIKey)
-	embedder := llm.NewEmbedder(cfg.GroqAPIKey)
+	llmClient := llm.NewClient(cfg.GroqAPIKey, cfg.GroqModel, cfg.Temperature,
+		llm.WithRetry(cfg.LLMMaxAttempts, cfg.LLMRetryBaseDelay),
+		llm.WithTimeouts(cfg.LLMRequestTimeout, cfg.LLMStreamIdleTimeout),
+		llm.WithTransport(httpTransport),
+		llm.WithBaseURL(cfg.GroqBaseURL),
+	)
+	embedder, err := llm.NewEmbedder(cfg.EmbeddingProvider, cfg.EmbeddingURL, cfg.EmbeddingAPIKey, cfg.EmbeddingModel,
+		llm.WithEmbedConcurrency(cfg.EmbedConcurrency),
+		llm.WithEmbedTimeout(cfg.EmbedTimeout),
+		llm.WithEmbedTransport(httpTransport),
+		llm.WithEmbedRetry(cfg.EmbedMaxAttempts, cfg.EmbedRetryBaseDelay),
+		llm.WithEmbedWarmup(cfg.EmbedWarmupTimeout),
+	)
+	if err != nil {
+		log.Fatalf("Failed to create embedder: %v", err)
+	}
+	if cfg.EmbedNormalize {
+		embedder = llm.NewNormalizingEmbedder(embedder)
+	}
+
+	// Load the embedding model in the background so a cold model doesn't
+	// delay startup or the first query
+	if cfg.EmbedWarmupTimeout > 0 {
+		go warmUpEmbedder(ctx, embedder)
+	}
+
+	// Caches register here so their footprint shows up in /stats
+	caches := cache.NewRegistry()
 
 	// Initialize RAG service
-	ragService := rag.NewService(llmClient, embedder, vectorClient)
+	ragOpts, err := ragconfig.Options(cfg, vectorClient, caches)
+	if err != nil {
+		log.Fatalf("Failed to configure RAG service: %v", err)
+	}
+	if cfg.ConversationStore == "file" {
+		store, err := rag.NewFileStore(cfg.ConversationFile, cfg.ConversationMaxTurns*2, cfg.ConversationMax)
+		if err != nil {
+			log.Fatalf("Failed to open conversation store: %v", err)
+		}
+		defer store.Close()
+		ragOpts = append(ragOpts, rag.WithConversationStore(store))
+	}
+	var queryTracker *cache.QueryTracker
+	if cfg.QueryStatsSize > 0 {
+		queryTracker = cache.NewQueryTracker(cfg.QueryStatsSize)
+		if cfg.QueryStatsFile != "" {
+			if err := queryTracker.Load(cfg.QueryStatsFile); err != nil {
+				log.Printf("Starting without saved query stats: %v", err)
+			}
+		}
+		caches.Register(queryTracker)
+		ragOpts = append(ragOpts, rag.WithQueryTracker(queryTracker))
+	}
+	ragService := rag.NewService(llmClient, embedder, vectorClient, ragOpts...)
+
+	// Embed the most frequent questions in the background so they skip the
+	// embedder after a restart
+	if queryTracker != nil && cfg.CacheWarmupQueries > 0 {
+		go func() {
+			if _, err := ragService.WarmEmbeddings(ctx, cfg.CacheWarmupQueries); err != nil {
+				log.Printf("Embedding cache warm-up stopped: %v", err)
+			}
+		}()
+	}
 
 	// Setup HTTP server
 	mux := http.NewServeMux()
 
 	// Health check endpoint
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"ok"}`))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(HealthResponse{
+			Status:  "ok",
+			Version: version.Version,
+		})
+	})
+
+	// Readiness endpoint, checking the dependencies needed to answer. With
+	// an embedding cache, cached queries are answered while the embedder
+	// is down, so losing it only degrades the service.
+	required := map[string]func(context.Context) error{
+		"qdrant":              vectorClient.CheckCollection,
+		cfg.EmbeddingProvider: embedder.Ping,
+	}
+	for _, name := range cfg.ModuleCollections {
+		required["qdrant:"+name] = vectorClient.ForCollection(name).CheckCollection
+	}
+	var degradable map[string]func(context.Context) error
+	if cfg.EmbedCacheSize > 0 {
+		degradable = map[string]func(context.Context) error{cfg.EmbeddingProvider: embedder.Ping}
+		delete(required, cfg.EmbeddingProvider)
+	}
+	mux.HandleFunc("/ready", readyHandler(required, degradable))
+
+	// Stats endpoint
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"cache": caches.Summary(),
+		})
+	})
+
+	// Collection stats for operators. Admin routes require an API key, and
+	// are refused outright when API_KEYS is unset
+	mux.HandleFunc("/admin/stats", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		clients := []*vector.Client{vectorClient}
+		seen := map[string]bool{cfg.CollectionName: true}
+		for _, name := range cfg.ModuleCollections {
+			if !seen[name] {
+				seen[name] = true
+				clients = append(clients, vectorClient.ForCollection(name))
+			}
+		}
+
+		stats := AdminStatsResponse{
+			Version:    version.Version,
+			Commit:     version.GetCommit(),
+			Uptime:     time.Since(startTime).Round(time.Second).String(),
+			Model:      cfg.GroqModel,
+			Collection: cfg.CollectionName,
+		}
+		for _, client := range clients {
+			info, err := client.CollectionInfo(r.Context())
+			if err != nil {
+				log.Printf("Collection info error: %v", err)
+				http.Error(w, "Failed to fetch collection info", http.StatusBadGateway)
+				return
+			}
+			stats.Collections = append(stats.Collections, info)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats)
 	})
 
+	// On-demand warm-up, e.g. after the embedding backend restarted
+	mux.HandleFunc("/warmup", warmupHandler(embedder))
+
+	var limiter *rateLimiter
+	if cfg.RateLimitRPS > 0 {
+		limiter = newRateLimiter(cfg.RateLimitRPS, cfg.RateLimitBurst)
+		go limiter.runCleanup(ctx, time.Minute)
+	}
+
+	decoder := bodyDecoder{maxBytes: cfg.MaxRequestBytes, strict: cfg.StrictJSON}
+	chatMetrics := newMetrics()
+	streams := newStreamTracker()
+	mux.HandleFunc("/metrics", metricsHandler(chatMetrics, caches))
+
 	// Chat endpoint
 	mux.HandleFunc("/chat", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -80,13 +404,20 @@ func main() {
 		}
 
 		var req ChatRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		if berr := decoder.decode(w, r, &req); berr != nil {
+			writeBodyError(w, berr)
+			return
+		}
+
+		if errs := req.Validate(); len(errs) > 0 {
+			writeValidationError(w, errs)
 			return
 		}
+		recordQuery(r.Context(), req.Query)
 
-		if req.Query == "" {
-			http.Error(w, "Query is required", http.StatusBadRequest)
+		opts, err := req.queryOptions(callerFrom(r.Context()))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
 			return
 		}
 
@@ -102,34 +433,76 @@ func main() {
 				return
 			}
 
-			// Create a writer that flushes after each write
-			streamWriter := &flushWriter{w: w, f: flusher}
+			// Create a writer that sends the answer as SSE data events,
+			// ended by a finish (or error) event
+			streamWriter := &flushWriter{w: w, f: flusher, minBytes: cfg.StreamMinFlushBytes}
+
+			streamCtx, done := streams.track(r.Context())
+			defer done()
 
-			if err := ragService.StreamQuery(r.Context(), req.Query, streamWriter); err != nil {
-				log.Printf("Stream error: %v", err)
+			// Keep proxies from dropping the connection while the LLM
+			// works towards its first token
+			keepAlive := newKeepAliveWriter(streamWriter, w, flusher, cfg.StreamKeepAliveInterval)
+			result, err := ragService.StreamQuery(streamCtx, req.Query, opts, keepAlive)
+			keepAlive.stopKeepAlive()
+			if err != nil {
+				recordError(r.Context(), err)
+				if errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+					log.Printf("Stream truncated: request deadline exceeded: %v", err)
+				} else if streams.interrupted(streamCtx) {
+					log.Printf("Stream interrupted by shutdown: %v", err)
+				} else {
+					log.Printf("Stream error: %v", err)
+				}
+			}
+			var reason string
+			if result != nil {
+				recordUsage(r.Context(), result.Usage)
+				reason = result.FinishReason
+			}
+			if streams.interrupted(streamCtx) {
+				reason = finishReasonShutdown
+			}
+			if err := streamWriter.Flush(); err != nil {
+				log.Printf("Stream flush error: %v", err)
+			}
+			// Tell the client the answer is incomplete, unless it has gone
+			// away or shutdown already explains the cut
+			if err != nil && !streams.interrupted(streamCtx) && !errors.Is(r.Context().Err(), context.Canceled) {
+				if err := writeErrorEvent(streamWriter, err); err != nil {
+					log.Printf("Stream error event error: %v", err)
+				}
+			}
+			if reason != "" {
+				if err := writeFinishEvent(streamWriter, reason); err != nil {
+					log.Printf("Stream finish event error: %v", err)
+				}
 			}
 		} else {
 			// Non-streaming response
-			result, err := ragService.Query(r.Context(), req.Query)
+			opts.Debug = req.Debug && cfg.DebugResponses
+			result, err := ragService.Query(r.Context(), req.Query, opts)
 			if err != nil {
-				log.Printf("Query error: %v", err)
-				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				recordError(r.Context(), err)
+				status := queryErrorStatus(err)
+				http.Error(w, http.StatusText(status), status)
 				return
 			}
 
-			sources := make([]Source, len(result.Sources))
-			for i, s := range result.Sources {
-				sources[i] = Source{
-					ID:     s.ID,
-					Module: s.Module,
-					Topic:  s.Topic,
-					Score:  s.Score,
-				}
-			}
+			recordUsage(r.Context(), result.Usage)
+
+			resp := newChatResponse(result)
 
-			resp := ChatResponse{
-				Answer:  result.Answer,
-				Sources: sources,
+			// ETags only make sense when answers are deterministic
+			if cfg.ChatETag && deterministic(req, cfg.Temperature, cfg.DeterministicSeed) {
+				body, err := json.Marshal(resp)
+				if err != nil {
+					log.Printf("Encode error: %v", err)
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+					return
+				}
+				writeWithETag(w, r, body)
+				return
 			}
 
 			w.Header().Set("Content-Type", "application/json")
@@ -137,18 +510,145 @@ func main() {
 		}
 	})
 
+	// Batch endpoint for offline evaluation
+	mux.HandleFunc("/chat/batch", batchHandler(ragService, decoder, cfg.BatchConcurrency, limiter))
+
+	// OpenAI-compatible endpoint for drop-in clients
+	mux.HandleFunc("/v1/chat/completions", openAIHandler(ragService, decoder.lenient(), cfg.GroqModel, streams, cfg.StreamKeepAliveInterval))
+
+	// Retrieval diagnostic endpoint
+	mux.HandleFunc("/chat/diagnose", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req ChatRequest
+		if berr := decoder.decode(w, r, &req); berr != nil {
+			writeBodyError(w, berr)
+			return
+		}
+
+		if errs := req.Validate(); len(errs) > 0 {
+			writeValidationError(w, errs)
+			return
+		}
+
+		opts, err := req.queryOptions(callerFrom(r.Context()))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		diag, err := ragService.Diagnose(r.Context(), req.Query, opts)
+		if err != nil {
+			log.Printf("Diagnose error: %v", err)
+			status := queryErrorStatus(err)
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+
+		candidates := make([]DiagnosticCandidate, len(diag.Candidates))
+		for i, c := range diag.Candidates {
+			candidates[i] = DiagnosticCandidate{
+				ID:               c.ID,
+				Module:           c.Module,
+				Topic:            c.Topic,
+				Score:            c.Score,
+				BelowThreshold:   c.BelowThreshold,
+				NearestVariation: c.NearestVariation,
+				LexicalScore:     c.LexicalScore,
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(DiagnosticResponse{
+			Query:            diag.Query,
+			ScoreThreshold:   diag.ScoreThreshold,
+			Filtered:         diag.Filtered,
+			Cause:            diag.Cause,
+			Candidates:       candidates,
+			ExcludedByFilter: diag.ExcludedByFilter,
+		})
+	})
+
+	// Conversation endpoint, used to clear a conversation's history
+	mux.HandleFunc("/conversations/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := strings.TrimPrefix(r.URL.Path, "/conversations/")
+		if id == "" {
+			http.Error(w, "Conversation ID is required", http.StatusBadRequest)
+			return
+		}
+
+		if err := ragService.ClearConversation(r.Context(), callerFrom(r.Context()).conversation(id)); err != nil {
+			log.Printf("Clear conversation error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// Prompt token estimate endpoint
+	mux.HandleFunc("/chat/estimate", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req ChatRequest
+		if berr := decoder.decode(w, r, &req); berr != nil {
+			writeBodyError(w, berr)
+			return
+		}
+
+		if errs := req.Validate(); len(errs) > 0 {
+			writeValidationError(w, errs)
+			return
+		}
+
+		opts, err := req.queryOptions(callerFrom(r.Context()))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		estimate, err := ragService.EstimatePromptTokens(r.Context(), req.Query, opts)
+		if err != nil {
+			log.Printf("Estimate error: %v", err)
+			status := queryErrorStatus(err)
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(EstimateResponse{
+			SystemTokens:   estimate.SystemTokens,
+			ContextTokens:  estimate.ContextTokens,
+			QuestionTokens: estimate.QuestionTokens,
+			TotalTokens:    estimate.TotalTokens,
+		})
+	})
+
 	// Create server
 	server := &http.Server{
 		Addr:         ":" + cfg.Port,
-		Handler:      corsMiddleware(loggingMiddleware(mux)),
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 120 * time.Second,
+		Handler:      corsMiddleware(cfg.CORSAllowedOrigins, cfg.CORSAllowedMethods, cfg.CORSAllowedHeaders, loggingMiddleware(authMiddleware(auth, rateLimitMiddleware(limiter, metricsMiddleware(chatMetrics, timeoutMiddleware(cfg.RouteTimeouts, mux)))))),
+		ReadTimeout:  cfg.ServerReadTimeout,
+		WriteTimeout: cfg.ServerWriteTimeout,
 		IdleTimeout:  120 * time.Second,
 	}
 
 	// Start server in goroutine
 	go func() {
-		log.Printf("Server starting on port %s", cfg.Port)
+		log.Printf("Server %s (%s) starting on port %s", version.Version, version.GetCommit(), cfg.Port)
+		if len(cfg.APIKeys) == 0 {
+			log.Printf("API_KEYS not set; authentication is disabled")
+		}
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server error: %v", err)
 		}
@@ -159,50 +659,165 @@ func main() {
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	<-sigChan
 
+	// Stop accepting requests, give active streams most of the shutdown
+	// timeout to finish, then interrupt the rest cleanly.
 	log.Println("Shutting down server...")
-	shutdownCtx, shutdownCancel := context.WithTimeout(ctx, 10*time.Second)
+	shutdownCtx, shutdownCancel := context.WithTimeout(ctx, cfg.ShutdownTimeout)
 	defer shutdownCancel()
 
-	if err := server.Shutdown(shutdownCtx); err != nil {
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- server.Shutdown(shutdownCtx) }()
+
+	active, interrupted := streams.drain(cfg.ShutdownTimeout - streamStopGrace)
+	log.Printf("Drained %d streaming sessions (%d interrupted)", active, interrupted)
+
+	if err := <-shutdownErr; err != nil {
 		log.Printf("Shutdown error: %v", err)
 	}
 
+	if queryTracker != nil && cfg.QueryStatsFile != "" {
+		if err := queryTracker.Save(cfg.QueryStatsFile); err != nil {
+			log.Printf("Failed to save query stats: %v", err)
+		}
+	}
+
 	log.Println("Server stopped")
 }
 
-// flushWriter wraps a ResponseWriter and Flusher for streaming.
+// flushWriter wraps a ResponseWriter and Flusher for streaming, sending the
+// answer as SSE data events so it shares one framing with the finish and
+// error events. Newlines in the answer split an event into several data
+// lines, which clients join with "\n". When minBytes is positive, writes are
+// buffered until at least minBytes are pending.
 type flushWriter struct {
-	w http.ResponseWriter
-	f http.Flusher
+	w        http.ResponseWriter
+	f        http.Flusher
+	minBytes int
+	buf      []byte
 }
 
 func (fw *flushWriter) Write(p []byte) (int, error) {
-	n, err := fw.w.Write(p)
+	if fw.minBytes <= 0 {
+		if err := fw.writeEvent(p); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	fw.buf = append(fw.buf, p...)
+	if len(fw.buf) < fw.minBytes {
+		return len(p), nil
+	}
+	if err := fw.Flush(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes out any buffered bytes. It must be called once the stream ends.
+func (fw *flushWriter) Flush() error {
+	if len(fw.buf) == 0 {
+		return nil
+	}
+	err := fw.writeEvent(fw.buf)
+	fw.buf = fw.buf[:0]
+	return err
+}
+
+// writeEvent sends p as one data event.
+func (fw *flushWriter) writeEvent(p []byte) error {
+	if len(p) == 0 {
+		return nil
+	}
+	var event []byte
+	for _, line := range bytes.Split(p, []byte("\n")) {
+		event = append(append(append(event, "data: "...), line...), '\n')
+	}
+	event = append(event, '\n')
+	if _, err := fw.w.Write(event); err != nil {
+		return err
+	}
 	fw.f.Flush()
-	return n, err
+	return nil
 }
 
-// loggingMiddleware logs incoming requests.
-func loggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		next.ServeHTTP(w, r)
-		log.Printf("%s %s %v", r.Method, r.URL.Path, time.Since(start))
-	})
+// writeFinishEvent ends a stream with an SSE event carrying the LLM's
+// finish reason, so clients can tell a complete answer from a truncated one.
+func writeFinishEvent(fw *flushWriter, reason string) error {
+	data, err := json.Marshal(map[string]string{"finish_reason": reason})
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(fw.w, "event: finish\ndata: %s\n\n", data); err != nil {
+		return err
+	}
+	fw.f.Flush()
+	return nil
 }
 
-// corsMiddleware adds CORS headers.
-func corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+// Codes of the stream error event.
+const (
+	streamErrTimeout     = "timeout"
+	streamErrUnavailable = "unavailable"
+	streamErrUpstream    = "upstream_error"
+)
+
+// streamErrorDetails returns the code and client-facing message for an
+// error that ended a stream part way.
+func streamErrorDetails(streamErr error) (code, message string) {
+	switch {
+	case errors.Is(streamErr, context.DeadlineExceeded), errors.Is(streamErr, llm.ErrStreamIdle):
+		return streamErrTimeout, "The answer took too long and was cut off."
+	case errors.Is(streamErr, rag.ErrEmbedderUnavailable):
+		return streamErrUnavailable, "The service is temporarily unavailable."
+	}
+	return streamErrUpstream, "The answer stream failed before it was complete."
+}
+
+// writeErrorEvent tells the client that a stream failed part way, so it can
+// show the answer as incomplete instead of silently cut off. Like the answer
+// it is a complete SSE event; the buffered answer must be flushed first.
+func writeErrorEvent(fw *flushWriter, streamErr error) error {
+	code, message := streamErrorDetails(streamErr)
+	data, err := json.Marshal(map[string]string{"code": code, "message": message})
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(fw.w, "event: error\ndata: %s\n\n", data); err != nil {
+		return err
+	}
+	fw.f.Flush()
+	return nil
+}
 
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
+// loggingMiddleware logs each request as a structured record.
+func loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx, stats := withRequestStats(r.Context())
+		sw := newStatusWriter(w)
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		attrs := []any{
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", sw.status,
+			"latency_ms", time.Since(start).Milliseconds(),
+		}
+		if n := stats.queryLength(); n > 0 {
+			attrs = append(attrs, "query_len", n)
+		}
+		if usage := stats.tokenUsage(); usage.TotalTokens > 0 {
+			attrs = append(attrs,
+				"tokens", usage.TotalTokens,
+				"prompt_tokens", usage.PromptTokens,
+				"completion_tokens", usage.CompletionTokens,
+			)
+		}
+		if err := stats.failure(); err != nil {
+			slog.Error("request failed", append(attrs, "error", err.Error())...)
 			return
 		}
-
-		next.ServeHTTP(w, r)
+		slog.Info("request", attrs...)
 	})
 }
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFlushWriterMinBytes(t *testing.T) {
+	deltas := []string{"Open ", "the ", "Payroll ", "dashboard ", "and ", "click ", "Run ", "payroll."}
+
+	tests := []struct {
+		name       string
+		minBytes   int
+		wantEvents int
+	}{
+		{name: "immediate", minBytes: 0, wantEvents: len(deltas)},
+		{name: "smaller than a delta", minBytes: 3, wantEvents: len(deltas)},
+		{name: "buffered", minBytes: 10, wantEvents: 4},
+		{name: "larger than the answer", minBytes: 1000, wantEvents: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			fw := &flushWriter{w: rec, f: rec, minBytes: tt.minBytes}
+			for _, d := range deltas {
+				if _, err := fw.Write([]byte(d)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := fw.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			events := sseData(rec.Body.String())
+			if len(events) != tt.wantEvents {
+				t.Errorf("%d events, want %d: %q", len(events), tt.wantEvents, events)
+			}
+			for i, e := range events[:len(events)-1] {
+				if len(e) < tt.minBytes {
+					t.Errorf("event %d is %d bytes, want at least %d: %q", i, len(e), tt.minBytes, e)
+				}
+			}
+			if got, want := strings.Join(events, ""), strings.Join(deltas, ""); got != want {
+				t.Errorf("answer = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// sseData returns the data of each event in an SSE body, joining multi-line
+// data with "\n" as clients do.
+func sseData(body string) []string {
+	var events []string
+	for _, block := range strings.Split(strings.TrimSuffix(body, "\n\n"), "\n\n") {
+		var lines []string
+		for _, line := range strings.Split(block, "\n") {
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				lines = append(lines, data)
+			}
+		}
+		events = append(events, strings.Join(lines, "\n"))
+	}
+	return events
+}
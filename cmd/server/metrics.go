@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-bot/internal/cache"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the chat latency
+// histogram.
+var latencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// metrics holds the counters exported on /metrics.
+type metrics struct {
+	chatRequests     atomic.Uint64
+	chatErrors       atomic.Uint64
+	inFlight         atomic.Int64
+	promptTokens     atomic.Uint64
+	completionTokens atomic.Uint64
+
+	mu           sync.Mutex
+	bucketCounts []uint64
+	latencySum   float64
+	latencyCount uint64
+}
+
+func newMetrics() *metrics {
+	return &metrics{bucketCounts: make([]uint64, len(latencyBuckets))}
+}
+
+// observeLatency records a chat request duration in the histogram.
+func (m *metrics) observeLatency(d time.Duration) {
+	seconds := d.Seconds()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, upper := range latencyBuckets {
+		if seconds <= upper {
+			m.bucketCounts[i]++
+		}
+	}
+	m.latencySum += seconds
+	m.latencyCount++
+}
+
+// metricsMiddleware counts chat requests, errors, in-flight requests,
+// latency and token usage. It must run inside loggingMiddleware so the
+// request stats are available.
+func metricsMiddleware(m *metrics, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat" && r.URL.Path != "/v1/chat/completions" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		m.chatRequests.Add(1)
+		m.inFlight.Add(1)
+		defer m.inFlight.Add(-1)
+
+		start := time.Now()
+		sw := newStatusWriter(w)
+		next.ServeHTTP(sw, r)
+		m.observeLatency(time.Since(start))
+
+		stats := requestStatsFrom(r.Context())
+		if sw.status >= http.StatusInternalServerError || (stats != nil && stats.failure() != nil) {
+			m.chatErrors.Add(1)
+		}
+		if stats != nil {
+			usage := stats.tokenUsage()
+			m.promptTokens.Add(uint64(usage.PromptTokens))
+			m.completionTokens.Add(uint64(usage.CompletionTokens))
+		}
+	})
+}
+
+// metricsHandler writes the metrics in Prometheus text exposition format.
+func metricsHandler(m *metrics, caches *cache.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+		fmt.Fprintln(w, "# HELP gobot_chat_requests_total Total chat requests.")
+		fmt.Fprintln(w, "# TYPE gobot_chat_requests_total counter")
+		fmt.Fprintf(w, "gobot_chat_requests_total %d\n", m.chatRequests.Load())
+
+		fmt.Fprintln(w, "# HELP gobot_chat_errors_total Chat requests that failed.")
+		fmt.Fprintln(w, "# TYPE gobot_chat_errors_total counter")
+		fmt.Fprintf(w, "gobot_chat_errors_total %d\n", m.chatErrors.Load())
+
+		fmt.Fprintln(w, "# HELP gobot_chat_in_flight Chat requests currently being served.")
+		fmt.Fprintln(w, "# TYPE gobot_chat_in_flight gauge")
+		fmt.Fprintf(w, "gobot_chat_in_flight %d\n", m.inFlight.Load())
+
+		fmt.Fprintln(w, "# HELP gobot_chat_latency_seconds Chat request latency.")
+		fmt.Fprintln(w, "# TYPE gobot_chat_latency_seconds histogram")
+		m.mu.Lock()
+		for i, upper := range latencyBuckets {
+			fmt.Fprintf(w, "gobot_chat_latency_seconds_bucket{le=\"%g\"} %d\n", upper, m.bucketCounts[i])
+		}
+		fmt.Fprintf(w, "gobot_chat_latency_seconds_bucket{le=\"+Inf\"} %d\n", m.latencyCount)
+		fmt.Fprintf(w, "gobot_chat_latency_seconds_sum %g\n", m.latencySum)
+		fmt.Fprintf(w, "gobot_chat_latency_seconds_count %d\n", m.latencyCount)
+		m.mu.Unlock()
+
+		fmt.Fprintln(w, "# HELP gobot_llm_tokens_total LLM tokens used, by kind.")
+		fmt.Fprintln(w, "# TYPE gobot_llm_tokens_total counter")
+		fmt.Fprintf(w, "gobot_llm_tokens_total{kind=\"prompt\"} %d\n", m.promptTokens.Load())
+		fmt.Fprintf(w, "gobot_llm_tokens_total{kind=\"completion\"} %d\n", m.completionTokens.Load())
+
+		summary := caches.Summary()
+		fmt.Fprintln(w, "# HELP gobot_cache_hits_total Cache hits.")
+		fmt.Fprintln(w, "# TYPE gobot_cache_hits_total counter")
+		for _, st := range summary.Caches {
+			fmt.Fprintf(w, "gobot_cache_hits_total{cache=%q} %d\n", st.Name, st.Hits)
+		}
+		fmt.Fprintln(w, "# HELP gobot_cache_misses_total Cache misses.")
+		fmt.Fprintln(w, "# TYPE gobot_cache_misses_total counter")
+		for _, st := range summary.Caches {
+			fmt.Fprintf(w, "gobot_cache_misses_total{cache=%q} %d\n", st.Name, st.Misses)
+		}
+		fmt.Fprintln(w, "# HELP gobot_cache_hit_ratio Fraction of cache lookups that hit.")
+		fmt.Fprintln(w, "# TYPE gobot_cache_hit_ratio gauge")
+		for _, st := range summary.Caches {
+			ratio := 0.0
+			if lookups := st.Hits + st.Misses; lookups > 0 {
+				ratio = float64(st.Hits) / float64(lookups)
+			}
+			fmt.Fprintf(w, "gobot_cache_hit_ratio{cache=%q} %g\n", st.Name, ratio)
+		}
+	}
+}
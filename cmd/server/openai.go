@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"go-bot/internal/llm"
+	"go-bot/internal/rag"
+)
+
+// OpenAIMessage is a message in the OpenAI chat completions format.
+type OpenAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// OpenAIChatRequest is the subset of the OpenAI chat completions request
+// the bot understands. Only the last user message is used as the query;
+// retrieval supplies the rest of the prompt.
+type OpenAIChatRequest struct {
+	Model       string          `json:"model"`
+	Messages    []OpenAIMessage `json:"messages"`
+	Stream      bool            `json:"stream"`
+	MaxTokens   int             `json:"max_tokens,omitempty"`
+	Seed        *int            `json:"seed,omitempty"`
+	Temperature *float64        `json:"temperature,omitempty"`
+}
+
+// OpenAIChoice is a completion choice. Message is set on full responses and
+// Delta on stream chunks.
+type OpenAIChoice struct {
+	Index        int            `json:"index"`
+	Message      *OpenAIMessage `json:"message,omitempty"`
+	Delta        *OpenAIMessage `json:"delta,omitempty"`
+	FinishReason *string        `json:"finish_reason"`
+}
+
+// OpenAIChatResponse is a chat completion or, when streaming, one chunk of it.
+type OpenAIChatResponse struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []OpenAIChoice `json:"choices"`
+	Usage   *llm.Usage     `json:"usage,omitempty"`
+}
+
+// lastUserMessage returns the content of the last message with role "user".
+func (req OpenAIChatRequest) lastUserMessage() string {
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == "user" {
+			return req.Messages[i].Content
+		}
+	}
+	return ""
+}
+
+// openAIHandler serves POST /v1/chat/completions so clients written against
+// the OpenAI API can talk to the bot unchanged.
+func openAIHandler(ragService *rag.Service, decoder bodyDecoder, defaultModel string, streams *streamTracker, keepAliveInterval time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeOpenAIError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		var req OpenAIChatRequest
+		if berr := decoder.decode(w, r, &req); berr != nil {
+			writeOpenAIError(w, berr.status, berr.message)
+			return
+		}
+
+		chatReq := ChatRequest{
+			Query:       req.lastUserMessage(),
+			MaxTokens:   req.MaxTokens,
+			Seed:        req.Seed,
+			Temperature: req.Temperature,
+		}
+		if errs := chatReq.Validate(); len(errs) > 0 {
+			field := errs[0].Field
+			if field == "query" {
+				field = "last user message"
+			}
+			writeOpenAIError(w, http.StatusBadRequest, fmt.Sprintf("%s %s", field, errs[0].Message))
+			return
+		}
+		recordQuery(r.Context(), chatReq.Query)
+
+		opts, err := chatReq.queryOptions(callerFrom(r.Context()))
+		if err != nil {
+			writeOpenAIError(w, http.StatusForbidden, err.Error())
+			return
+		}
+
+		model := req.Model
+		if model == "" {
+			model = defaultModel
+		}
+		resp := OpenAIChatResponse{
+			ID:      newCompletionID(),
+			Created: time.Now().Unix(),
+			Model:   model,
+		}
+
+		if !req.Stream {
+			result, err := ragService.Query(r.Context(), chatReq.Query, opts)
+			if err != nil {
+				recordError(r.Context(), err)
+				status := queryErrorStatus(err)
+				writeOpenAIError(w, status, http.StatusText(status))
+				return
+			}
+			recordUsage(r.Context(), result.Usage)
+
+			resp.Object = "chat.completion"
+			resp.Choices = []OpenAIChoice{{
+				Message:      &OpenAIMessage{Role: "assistant", Content: result.Answer},
+				FinishReason: finishReason(result.FinishReason),
+			}}
+			resp.Usage = result.Usage
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeOpenAIError(w, http.StatusInternalServerError, "Streaming not supported")
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+
+		resp.Object = "chat.completion.chunk"
+		cw := &chunkWriter{w: w, f: flusher, resp: resp}
+
+		streamCtx, done := streams.track(r.Context())
+		defer done()
+
+		keepAlive := newKeepAliveWriter(cw, w, flusher, keepAliveInterval)
+		result, err := ragService.StreamQuery(streamCtx, chatReq.Query, opts, keepAlive)
+		keepAlive.stopKeepAlive()
+		if err != nil {
+			recordError(r.Context(), err)
+			log.Printf("OpenAI stream error: %v", err)
+		}
+
+		var reason string
+		if result != nil {
+			recordUsage(r.Context(), result.Usage)
+			reason = result.FinishReason
+		}
+		switch {
+		case streams.interrupted(streamCtx):
+			reason = finishReasonShutdown
+		case errors.Is(r.Context().Err(), context.Canceled):
+			// The client has gone away
+			return
+		case err != nil:
+			// A failed stream never reports a finish reason, which would
+			// pass the partial answer off as complete
+			if err := cw.fail(err); err != nil {
+				log.Printf("OpenAI stream error chunk error: %v", err)
+			}
+			return
+		}
+		if err := cw.finish(reason); err != nil {
+			log.Printf("OpenAI stream finish error: %v", err)
+		}
+	}
+}
+
+// chunkWriter turns each write from the RAG stream into an OpenAI
+// chat.completion.chunk SSE event.
+type chunkWriter struct {
+	w    http.ResponseWriter
+	f    http.Flusher
+	resp OpenAIChatResponse
+	sent bool
+}
+
+func (cw *chunkWriter) Write(p []byte) (int, error) {
+	delta := &OpenAIMessage{Content: string(p)}
+	if !cw.sent {
+		delta.Role = "assistant"
+		cw.sent = true
+	}
+	if err := cw.writeChunk(OpenAIChoice{Delta: delta}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// finish sends the final chunk carrying the finish reason, followed by the
+// [DONE] sentinel.
+func (cw *chunkWriter) finish(reason string) error {
+	if err := cw.writeChunk(OpenAIChoice{Delta: &OpenAIMessage{}, FinishReason: finishReason(reason)}); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprint(cw.w, "data: [DONE]\n\n"); err != nil {
+		return err
+	}
+	cw.f.Flush()
+	return nil
+}
+
+// fail ends the stream with an error event in the shape of OpenAI's error
+// responses, which OpenAI clients raise as an API error.
+func (cw *chunkWriter) fail(streamErr error) error {
+	code, message := streamErrorDetails(streamErr)
+	data, err := json.Marshal(map[string]interface{}{
+		"error": map[string]string{
+			"message": message,
+			"type":    "server_error",
+			"code":    code,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(cw.w, "data: %s\n\n", data); err != nil {
+		return err
+	}
+	cw.f.Flush()
+	return nil
+}
+
+func (cw *chunkWriter) writeChunk(choice OpenAIChoice) error {
+	chunk := cw.resp
+	chunk.Choices = []OpenAIChoice{choice}
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(cw.w, "data: %s\n\n", data); err != nil {
+		return err
+	}
+	cw.f.Flush()
+	return nil
+}
+
+// finishReason defaults an empty reason to "stop", since answers that didn't
+// come from the LLM are always complete.
+func finishReason(reason string) *string {
+	if reason == "" {
+		reason = "stop"
+	}
+	return &reason
+}
+
+// newCompletionID returns a random completion ID in OpenAI's format.
+func newCompletionID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "chatcmpl-" + hex.EncodeToString(b)
+}
+
+// writeOpenAIError writes an error in the OpenAI error response format.
+func writeOpenAIError(w http.ResponseWriter, status int, message string) {
+	errType := "invalid_request_error"
+	if status >= http.StatusInternalServerError {
+		errType = "server_error"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]string{
+			"message": message,
+			"type":    errType,
+		},
+	})
+}
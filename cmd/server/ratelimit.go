@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimiter is an in-memory token-bucket limiter keyed by client.
+type rateLimiter struct {
+	rate  float64 // tokens added per second
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// allow takes a token for key. When the bucket is empty it returns false
+// and how long until the next token is available.
+func (l *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	return l.allowN(key, 1, now)
+}
+
+// allowN takes n tokens for key at once. When the bucket holds fewer it
+// takes none and returns false and how long until it holds n; n beyond the
+// burst never fits.
+func (l *rateLimiter) allowN(key string, n int, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, lastSeen: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.lastSeen).Seconds()*l.rate)
+	b.lastSeen = now
+
+	if b.tokens < float64(n) {
+		wait := time.Duration((float64(n) - b.tokens) / l.rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens -= float64(n)
+	return true, 0
+}
+
+// cleanup removes buckets that have been idle long enough to refill
+// completely. A new bucket starts full, so dropping them doesn't change
+// behaviour while keeping memory bounded by the clients seen recently.
+func (l *rateLimiter) cleanup(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.lastSeen).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// runCleanup drops refilled buckets every interval until ctx is done.
+func (l *rateLimiter) runCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			l.cleanup(now)
+		}
+	}
+}
+
+// rateLimitedPaths are the routes that cost a rate-limit token per request.
+// /chat/batch takes its tokens itself, one per query.
+var rateLimitedPaths = map[string]bool{
+	"/chat":                true,
+	"/chat/diagnose":       true,
+	"/v1/chat/completions": true,
+}
+
+// rateLimitMiddleware limits chat requests per caller: per validated API
+// key when API keys are configured, otherwise per client IP. It must run
+// after authMiddleware. A nil limiter disables rate limiting.
+func rateLimitMiddleware(l *rateLimiter, next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !rateLimitedPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ok, wait := l.allow(callerFrom(r.Context()).id, time.Now())
+		if !ok {
+			writeTooManyRequests(w, wait)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeTooManyRequests rejects a rate-limited request, telling the client
+// when to retry.
+func writeTooManyRequests(w http.ResponseWriter, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	http.Error(w, "Too many requests", http.StatusTooManyRequests)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// readinessTimeout bounds each dependency check so the probe can't hang.
+const readinessTimeout = 2 * time.Second
+
+// DependencyStatus reports the state of one dependency.
+type DependencyStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ReadyResponse is the /ready payload.
+type ReadyResponse struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]DependencyStatus `json:"dependencies"`
+}
+
+// readyHandler checks every dependency concurrently and returns 503 when any
+// required one fails. A failing degradable dependency reports the service as
+// "degraded" but keeps it ready.
+func readyHandler(required, degradable map[string]func(context.Context) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := ReadyResponse{
+			Status:       "ok",
+			Dependencies: make(map[string]DependencyStatus, len(required)+len(degradable)),
+		}
+
+		var (
+			mu sync.Mutex
+			wg sync.WaitGroup
+		)
+		run := func(name string, check func(context.Context) error, degrades bool) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+				defer cancel()
+
+				status := DependencyStatus{Status: "ok"}
+				if err := check(ctx); err != nil {
+					status = DependencyStatus{Status: "unavailable", Error: err.Error()}
+				}
+
+				mu.Lock()
+				resp.Dependencies[name] = status
+				if status.Status != "ok" {
+					if !degrades {
+						resp.Status = "unavailable"
+					} else if resp.Status == "ok" {
+						resp.Status = "degraded"
+					}
+				}
+				mu.Unlock()
+			}()
+		}
+		for name, check := range required {
+			run(name, check, false)
+		}
+		for name, check := range degradable {
+			run(name, check, true)
+		}
+		wg.Wait()
+
+		w.Header().Set("Content-Type", "application/json")
+		if resp.Status == "unavailable" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(resp)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"go-bot/internal/llm"
+)
+
+// requestStats collects per-request details for the logging middleware.
+type requestStats struct {
+	mu       sync.Mutex
+	usage    llm.Usage
+	queryLen int
+	err      error
+}
+
+type requestStatsKey struct{}
+
+// withRequestStats attaches a fresh requestStats to ctx.
+func withRequestStats(ctx context.Context) (context.Context, *requestStats) {
+	stats := &requestStats{}
+	return context.WithValue(ctx, requestStatsKey{}, stats), stats
+}
+
+// requestStatsFrom returns the request's stats, or nil if none are attached.
+func requestStatsFrom(ctx context.Context) *requestStats {
+	stats, _ := ctx.Value(requestStatsKey{}).(*requestStats)
+	return stats
+}
+
+// recordError marks the request as failed even if a 2xx status was
+// already sent, as happens when a stream breaks midway.
+func recordError(ctx context.Context, err error) {
+	stats := requestStatsFrom(ctx)
+	if stats == nil {
+		return
+	}
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	stats.err = err
+}
+
+// recordQuery notes the length of the request's query for logging.
+func recordQuery(ctx context.Context, query string) {
+	stats := requestStatsFrom(ctx)
+	if stats == nil {
+		return
+	}
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	stats.queryLen = len(query)
+}
+
+// recordUsage adds LLM token usage to the request's stats.
+func recordUsage(ctx context.Context, usage *llm.Usage) {
+	stats := requestStatsFrom(ctx)
+	if stats == nil || usage == nil {
+		return
+	}
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	stats.usage.PromptTokens += usage.PromptTokens
+	stats.usage.CompletionTokens += usage.CompletionTokens
+	stats.usage.TotalTokens += usage.TotalTokens
+}
+
+// tokenUsage returns the accumulated token usage.
+func (s *requestStats) tokenUsage() llm.Usage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.usage
+}
+
+// failure returns the error recorded for the request, if any.
+func (s *requestStats) failure() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// queryLength returns the recorded query length in bytes.
+func (s *requestStats) queryLength() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queryLen
+}
+
+// statusWriter records the status code of a response. Like net/http, only
+// the first WriteHeader counts, and a Write without one implies 200.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func newStatusWriter(w http.ResponseWriter) *statusWriter {
+	return &statusWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	if !sw.wroteHeader {
+		sw.status = code
+		sw.wroteHeader = true
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(p []byte) (int, error) {
+	sw.wroteHeader = true
+	return sw.ResponseWriter.Write(p)
+}
+
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+func (sw *statusWriter) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
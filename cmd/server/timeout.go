@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"go-bot/internal/rag"
+)
+
+// timeoutMiddleware applies a per-route context deadline. Requests that run
+// past their deadline without writing a response get 504.
+func timeoutMiddleware(timeouts map[string]time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout, ok := timeouts[r.URL.Path]
+		if !ok || timeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		tw := &timeoutWriter{ResponseWriter: w}
+		next.ServeHTTP(tw, r.WithContext(ctx))
+
+		if !tw.wroteHeader && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Printf("%s %s exceeded %v deadline", r.Method, r.URL.Path, timeout)
+			http.Error(w, "Request timed out", http.StatusGatewayTimeout)
+		}
+	})
+}
+
+// timeoutWriter records whether a response has been started.
+type timeoutWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.wroteHeader = true
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.wroteHeader = true
+	return tw.ResponseWriter.Write(p)
+}
+
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+func (tw *timeoutWriter) Flush() {
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// queryErrorStatus maps a RAG error to an HTTP status, using 504 when the
+// request deadline was hit and 503 when the embedder is down for an
+// uncached query.
+func queryErrorStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	if errors.Is(err, rag.ErrEmbedderUnavailable) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxQueryLength is the maximum accepted query length in characters.
+const maxQueryLength = 2000
+
+// maxLanguageLength is the maximum accepted length of a language name.
+const maxLanguageLength = 32
+
+// codeValidationFailed is the error code of a 422 validation response.
+const codeValidationFailed = "validation_failed"
+
+// FieldError describes a single invalid request field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrorResponse is returned with 422 when a request fails validation.
+type ValidationErrorResponse struct {
+	Error  string       `json:"error"`
+	Code   string       `json:"code"`
+	Fields []FieldError `json:"fields"`
+}
+
+// Validate checks a chat request and returns every failing field.
+func (req ChatRequest) Validate() []FieldError {
+	var errs []FieldError
+	if strings.TrimSpace(req.Query) == "" {
+		errs = append(errs, FieldError{Field: "query", Message: "is required"})
+	} else if utf8.RuneCountInString(req.Query) > maxQueryLength {
+		errs = append(errs, FieldError{Field: "query", Message: fmt.Sprintf("must be at most %d characters", maxQueryLength)})
+	}
+	if req.ScoreThreshold != nil && (*req.ScoreThreshold < 0 || *req.ScoreThreshold > 1) {
+		errs = append(errs, FieldError{Field: "score_threshold", Message: "must be between 0 and 1"})
+	}
+	if req.MaxTokens < 0 {
+		errs = append(errs, FieldError{Field: "max_tokens", Message: "must be positive"})
+	}
+	if req.Temperature != nil && (*req.Temperature < 0 || *req.Temperature > 2) {
+		errs = append(errs, FieldError{Field: "temperature", Message: "must be between 0 and 2"})
+	}
+	if !validLanguage(req.Language) {
+		errs = append(errs, FieldError{Field: "language", Message: fmt.Sprintf("must be a language name or code of at most %d letters", maxLanguageLength)})
+	}
+	return errs
+}
+
+// validLanguage reports whether language is empty or looks like a language
+// name or code. It keeps arbitrary text out of the system prompt.
+func validLanguage(language string) bool {
+	if utf8.RuneCountInString(language) > maxLanguageLength {
+		return false
+	}
+	for _, r := range language {
+		if !unicode.IsLetter(r) && r != ' ' && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// writeValidationError writes a 422 response listing the failing fields.
+func writeValidationError(w http.ResponseWriter, errs []FieldError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(ValidationErrorResponse{
+		Error:  "validation failed",
+		Code:   codeValidationFailed,
+		Fields: errs,
+	})
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"go-bot/internal/llm"
+)
+
+// WarmupResponse is the /warmup payload.
+type WarmupResponse struct {
+	Status     string `json:"status"`
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// warmUpEmbedder loads the embedding model through the embedder's warm-up
+// path, the one its first embedding would otherwise take, and logs how long
+// it took. Failures are only logged; queries retry on their own. Queries
+// waiting on the warm-up give up with their own context, and /ready pings
+// the embedder without waiting for it.
+func warmUpEmbedder(ctx context.Context, embedder llm.Embedder) {
+	elapsed, err := llm.Warmup(ctx, embedder)
+	if err != nil {
+		log.Printf("Embedder warm-up failed after %v: %v", elapsed.Round(time.Millisecond), err)
+		return
+	}
+	log.Printf("Embedder warmed up in %v", elapsed.Round(time.Millisecond))
+}
+
+// warmupHandler serves POST /warmup, loading the embedding model on demand
+// through the same warm-up path as startup and reporting how long it took.
+// It returns 503 when the embedder fails or the request ends first.
+func warmupHandler(embedder llm.Embedder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		elapsed, err := llm.Warmup(r.Context(), embedder)
+		resp := WarmupResponse{Status: "ok", DurationMS: elapsed.Milliseconds()}
+		if err != nil {
+			log.Printf("Embedder warm-up failed after %v: %v", elapsed.Round(time.Millisecond), err)
+			resp.Status = "unavailable"
+			resp.Error = err.Error()
+		} else {
+			log.Printf("Embedder warmed up in %v", elapsed.Round(time.Millisecond))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(resp)
+	}
+}
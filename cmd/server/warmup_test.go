@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-bot/internal/llm"
+)
+
+// coldOllama serves embeddings, holding warm-up requests until release is
+// closed, like Ollama loading a model.
+func coldOllama(t *testing.T, release <-chan struct{}) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req llm.OllamaRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Prompt == "warm-up" {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		json.NewEncoder(w).Encode(llm.OllamaResponse{Embedding: []float64{1, 0}})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestWarmUpDoesNotHoldProbes(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	embedder := llm.NewOllamaEmbedder(coldOllama(t, release).URL, "", llm.WithEmbedWarmup(time.Minute))
+
+	// As at startup
+	go warmUpEmbedder(context.Background(), embedder)
+	time.Sleep(20 * time.Millisecond)
+
+	tests := []struct {
+		name       string
+		handler    http.Handler
+		method     string
+		path       string
+		timeout    time.Duration
+		wantStatus int
+	}{
+		{
+			name:       "ready",
+			handler:    readyHandler(map[string]func(context.Context) error{"ollama": embedder.Ping}, nil),
+			method:     http.MethodGet,
+			path:       "/ready",
+			timeout:    time.Minute,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "warmup gives up with its request",
+			handler:    warmupHandler(embedder),
+			method:     http.MethodPost,
+			path:       "/warmup",
+			timeout:    50 * time.Millisecond,
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			req := httptest.NewRequest(tt.method, tt.path, nil).WithContext(ctx)
+			rec := httptest.NewRecorder()
+
+			start := time.Now()
+			tt.handler.ServeHTTP(rec, req)
+			if elapsed := time.Since(start); elapsed > readinessTimeout {
+				t.Errorf("took %v during warm-up", elapsed)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
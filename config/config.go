@@ -4,6 +4,8 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -12,10 +14,293 @@ import (
 type Config struct {
 	GroqAPIKey     string
 	QdrantHost     string
-	QdrantPort     int
 	Port           string
 	CollectionName string
 	EmbeddingDim   int
+
+	// GroqModel and Temperature configure chat completions.
+	GroqModel   string
+	Temperature float64
+
+	// DeterministicSeed, when zero or more, samples every LLM call with
+	// this seed at temperature zero for reproducible answers, e.g. for
+	// regression tests and answer snapshots. Negative disables it.
+	DeterministicSeed int
+
+	// GroqBaseURL is the OpenAI-compatible API root chat requests go to,
+	// e.g. an LLM gateway in front of Groq.
+	GroqBaseURL string
+
+	// QdrantHTTPPort is the port of Qdrant's HTTP REST API.
+	QdrantHTTPPort int
+
+	// ModuleCollections maps modules to collections of their own; other
+	// modules stay in CollectionName.
+	ModuleCollections map[string]string
+
+	// QdrantUseTLS and QdrantAPIKey configure access to HTTPS-only,
+	// authenticated deployments such as Qdrant Cloud.
+	QdrantUseTLS bool
+	QdrantAPIKey string
+
+	// HybridSearch stores a sparse keyword vector next to each embedding
+	// and fuses dense and sparse search results. Changing it requires
+	// recreating the collection.
+	HybridSearch bool
+
+	// PayloadIndexes maps payload fields to the Qdrant schema type they
+	// are indexed with, for fast filtered search.
+	PayloadIndexes map[string]string
+
+	// SystemPromptFile, when set, replaces the built-in system prompt.
+	SystemPromptFile string
+
+	// LLMMaxAttempts and LLMRetryBaseDelay control retries of transient
+	// Groq errors.
+	LLMMaxAttempts    int
+	LLMRetryBaseDelay time.Duration
+
+	// LLMMaxTokens is the default answer length; LLMContextWindow is the
+	// model's context size, used to clamp it.
+	LLMMaxTokens     int
+	LLMContextWindow int
+
+	// LLMStopSequences end answers early at any of these strings.
+	LLMStopSequences []string
+
+	// ContextBudgetTokens caps the retrieved context in the prompt; zero
+	// disables the cap.
+	ContextBudgetTokens int
+
+	// LLMMaxContinuations bounds follow-up requests made when an answer is
+	// cut off at max_tokens.
+	LLMMaxContinuations int
+
+	// LLMRequestTimeout caps non-streaming completions; LLMStreamIdleTimeout
+	// cancels a stream that produces no tokens for that long.
+	LLMRequestTimeout    time.Duration
+	LLMStreamIdleTimeout time.Duration
+
+	// QueryRewriting has the LLM rewrite questions before retrieval;
+	// QueryVariants also searches alternative phrasings.
+	QueryRewriting bool
+	QueryVariants  bool
+
+	// MMR re-ranking fetches MMRFetchK candidates and keeps the top K
+	// balancing relevance and diversity by MMRLambda.
+	MMREnabled bool
+	MMRLambda  float64
+	MMRFetchK  int
+
+	// Rerank has the LLM score RerankCandidates retrieved documents for
+	// relevance and keeps the top K.
+	Rerank           bool
+	RerankCandidates int
+
+	// ScoreThreshold drops retrieved documents scoring below it; zero keeps
+	// every result.
+	ScoreThreshold float32
+
+	// VariationMatchThreshold is the word overlap (0-1) with a stored query
+	// variation that guarantees an entry a place in the context; zero
+	// disables it. When set, query_variations gets a "text" payload index
+	// unless PayloadIndexes says otherwise.
+	VariationMatchThreshold float64
+
+	// BroadenRetrieval retries module-scoped searches that find nothing
+	// relevant without the module filter, keeping the role filter.
+	BroadenRetrieval bool
+
+	// Results with scores within TieBreakEpsilon are ordered by
+	// TieBreakKeys (payload fields, or "id").
+	TieBreakEpsilon float32
+	TieBreakKeys    []string
+
+	// RouteTimeouts maps request paths to per-request deadlines, applied as
+	// a context deadline in addition to the server-level timeouts.
+	RouteTimeouts      map[string]time.Duration
+	ServerReadTimeout  time.Duration
+	ServerWriteTimeout time.Duration
+
+	// HTTPMaxIdleConns, HTTPMaxIdleConnsPerHost and HTTPIdleConnTimeout
+	// size the connection pool shared by the Groq, Qdrant and embedding
+	// clients.
+	HTTPMaxIdleConns        int
+	HTTPMaxIdleConnsPerHost int
+	HTTPIdleConnTimeout     time.Duration
+
+	// MaxRequestBytes caps request body size. StrictJSON rejects bodies
+	// with fields the endpoint doesn't know.
+	MaxRequestBytes int64
+	StrictJSON      bool
+
+	// ShutdownTimeout bounds graceful shutdown, including draining
+	// active streams.
+	ShutdownTimeout time.Duration
+
+	// CORSAllowedOrigins lists origins allowed to call the API from a
+	// browser; "*" allows any origin and is meant for local development.
+	CORSAllowedOrigins []string
+	CORSAllowedMethods []string
+	CORSAllowedHeaders []string
+
+	// EmbeddingProvider selects "ollama" or "openai" (any OpenAI-compatible
+	// /embeddings API). Empty URL and model use the provider's defaults.
+	EmbeddingProvider string
+	EmbeddingURL      string
+	EmbeddingAPIKey   string
+	EmbeddingModel    string
+
+	// EmbedNormalize L2-normalizes embeddings before upsert and search.
+	EmbedNormalize bool
+
+	// EmbedTimeout bounds each embedding request.
+	EmbedTimeout time.Duration
+
+	// EmbedMaxAttempts and EmbedRetryBaseDelay configure retries of
+	// failed Ollama embedding requests.
+	EmbedMaxAttempts    int
+	EmbedRetryBaseDelay time.Duration
+
+	// EmbedWarmupTimeout, when set, makes the server and ingest load the
+	// embedding model at startup, bounding the warm-up request; zero
+	// disables warm-up, e.g. in CI/dev.
+	EmbedWarmupTimeout time.Duration
+
+	// QdrantConnectTimeout bounds how long startup waits for Qdrant.
+	QdrantConnectTimeout time.Duration
+
+	// StreamConfidenceThreshold gates streaming answers on retrieval score;
+	// zero disables the gate.
+	StreamConfidenceThreshold float32
+	LowConfidenceMessage      string
+
+	// ConfidenceDisclaimerThreshold prepends ConfidenceDisclaimer (or a
+	// default) to answers whose confidence is below it; zero disables it.
+	ConfidenceDisclaimerThreshold float32
+	ConfidenceDisclaimer          string
+
+	// RetrievalConcurrency bounds parallel embed+search of query variants.
+	RetrievalConcurrency int
+
+	// MetaDetection answers questions about the bot without retrieval.
+	// MetaPatterns overrides the default patterns when set.
+	MetaDetection bool
+	MetaPatterns  []string
+
+	// AnswerCleanupRules names built-in post-processing rules for
+	// non-streaming answers; AnswerCleanupPatterns are extra regular
+	// expressions whose matches are removed. Both empty disables cleanup.
+	AnswerCleanupRules    []string
+	AnswerCleanupPatterns []string
+
+	// StreamMinFlushBytes buffers streamed output until at least this many
+	// bytes are pending; zero flushes every delta immediately.
+	StreamMinFlushBytes int
+
+	// StreamKeepAliveInterval is how often an SSE comment is sent while a
+	// /chat or /v1/chat/completions stream waits for its first token; zero
+	// disables it. Both streams are SSE-framed, so clients skip the comments.
+	StreamKeepAliveInterval time.Duration
+
+	// DebugResponses lets /chat requests ask for the LLM's context and
+	// messages. Keep it off in production: it exposes the system prompt.
+	DebugResponses bool
+
+	// ChatETag adds ETags to /chat responses and honours If-None-Match,
+	// for answers sampled at temperature 0 with a seed, from the request
+	// or DeterministicSeed.
+	ChatETag bool
+
+	// BatchConcurrency bounds queries answered in parallel by /chat/batch.
+	BatchConcurrency int
+
+	// APIKeys are the bearer tokens accepted by the server; authentication
+	// is disabled when empty.
+	APIKeys []string
+
+	// APIKeyRoles maps API keys to the roles whose documents they may read.
+	// When set, every request is filtered by its key's roles, and callers
+	// without a listed key only see documents for all users.
+	APIKeyRoles map[string][]string
+
+	// APIKeyModules maps API keys to the only modules they may read, in
+	// the same format as APIKeyRoles. When set, modules kept in
+	// collections of their own (ModuleCollections) are only served to keys
+	// granted them; unlisted keys read every other module.
+	APIKeyModules map[string][]string
+
+	// Per-client rate limit on /chat, /v1/chat/completions and
+	// /chat/batch, where every query of a batch costs a token; zero
+	// RateLimitRPS disables it.
+	RateLimitRPS   float64
+	RateLimitBurst int
+
+	// Moderation is enabled when ModerationURL is set.
+	ModerationURL     string
+	ModerationAPIKey  string
+	ModerationMessage string
+
+	// StructuredAnswers returns separate overview and steps fields.
+	StructuredAnswers bool
+
+	// Citations labels context documents with [source: module/topic]
+	// markers and asks the LLM to cite them.
+	Citations bool
+
+	// EmbedCacheSize is the number of query embeddings cached; zero
+	// disables the cache.
+	EmbedCacheSize int
+
+	// ResponseCacheSize is the number of answers cached for
+	// ResponseCacheTTL each; zero disables the cache.
+	ResponseCacheSize int
+	ResponseCacheTTL  time.Duration
+
+	// QueryStatsSize bounds how many distinct questions are counted to
+	// find the frequent ones; zero disables tracking. QueryStatsFile, when
+	// set, keeps the counts across restarts, and the CacheWarmupQueries
+	// most frequent questions are embedded into the cache at startup.
+	QueryStatsSize     int
+	QueryStatsFile     string
+	CacheWarmupQueries int
+
+	// EmbedConcurrency bounds parallel embedding requests.
+	EmbedConcurrency int
+
+	// IngestUpsertConcurrency bounds concurrent upserts during ingestion.
+	IngestUpsertConcurrency int
+
+	// Strict grounding refuses answers the knowledge base doesn't support,
+	// either globally or only for the listed modules. Answers sharing less
+	// than StrictGroundingMinOverlap of their words with the context are
+	// refused after generation; zero disables that check.
+	StrictGrounding           bool
+	StrictGroundingModules    []string
+	StrictGroundingMinScore   float32
+	StrictGroundingMinOverlap float32
+
+	// ConversationMaxTurns caps the history kept per conversation; zero
+	// disables conversation memory.
+	ConversationMaxTurns int
+
+	// ConversationAnswerMaxChars truncates the latest prior answer replayed
+	// into the prompt; older answers get half as much as the one after
+	// them. Zero replays answers in full.
+	ConversationAnswerMaxChars int
+
+	// ConversationMax caps how many conversations are kept; the least
+	// recently used are forgotten first. Zero keeps every conversation.
+	ConversationMax int
+
+	// ConversationStore is "memory" (default) or "file", which persists
+	// history as JSONL in ConversationFile.
+	ConversationStore string
+	ConversationFile  string
+
+	// LogFormat is "text" or "json"; LogLevel is debug, info, warn or error.
+	LogFormat string
+	LogLevel  string
 }
 
 // Load reads configuration from environment variables.
@@ -24,16 +309,213 @@ func Load() *Config {
 		log.Println("No .env file found, reading from environment")
 	}
 
-	qdrantPort, _ := strconv.Atoi(getEnv("QDRANT_PORT", "6334"))
+	qdrantHTTPPort, _ := strconv.Atoi(getEnv("QDRANT_HTTP_PORT", "6333"))
+	if os.Getenv("QDRANT_HTTP_PORT") == "" && os.Getenv("QDRANT_PORT") != "" {
+		// Deprecated: QDRANT_PORT held the gRPC port, and the HTTP port
+		// was assumed to be the one below it.
+		grpcPort, _ := strconv.Atoi(os.Getenv("QDRANT_PORT"))
+		qdrantHTTPPort = grpcPort - 1
+		log.Printf("QDRANT_PORT is deprecated; set QDRANT_HTTP_PORT=%d instead", qdrantHTTPPort)
+	}
 	embeddingDim, _ := strconv.Atoi(getEnv("EMBEDDING_DIM", "384"))
+	retrievalConcurrency, _ := strconv.Atoi(getEnv("RETRIEVAL_CONCURRENCY", "4"))
+	temperature, err := strconv.ParseFloat(getEnv("TEMPERATURE", "0.7"), 64)
+	if err != nil || temperature < 0 || temperature > 2 {
+		log.Printf("Warning: TEMPERATURE must be between 0 and 2, using 0.7")
+		temperature = 0.7
+	}
+	deterministicSeed, err := strconv.Atoi(getEnv("DETERMINISTIC_SEED", "-1"))
+	if err != nil {
+		deterministicSeed = -1
+	}
+	llmMaxAttempts, _ := strconv.Atoi(getEnv("LLM_MAX_ATTEMPTS", "3"))
+	llmMaxTokens, _ := strconv.Atoi(getEnv("LLM_MAX_TOKENS", "1024"))
+	llmContextWindow, _ := strconv.Atoi(getEnv("LLM_CONTEXT_WINDOW", "131072"))
+	contextBudgetTokens, _ := strconv.Atoi(getEnv("CONTEXT_BUDGET_TOKENS", "8000"))
+	llmMaxContinuations, _ := strconv.Atoi(getEnv("LLM_MAX_CONTINUATIONS", "2"))
+	scoreThreshold, _ := strconv.ParseFloat(getEnv("SCORE_THRESHOLD", "0"), 32)
+	variationMatchThreshold, _ := strconv.ParseFloat(getEnv("VARIATION_MATCH_THRESHOLD", "0"), 64)
+	broadenRetrieval, _ := strconv.ParseBool(getEnv("BROADEN_RETRIEVAL", "false"))
+	payloadIndexes := parsePayloadIndexes(getEnv("PAYLOAD_INDEXES", "module,roles"))
+	if _, ok := payloadIndexes["query_variations"]; !ok && variationMatchThreshold > 0 {
+		payloadIndexes["query_variations"] = "text"
+	}
+	queryRewriting, _ := strconv.ParseBool(getEnv("QUERY_REWRITING", "false"))
+	queryVariants, _ := strconv.ParseBool(getEnv("QUERY_VARIANTS", "false"))
+	mmrEnabled, _ := strconv.ParseBool(getEnv("MMR_ENABLED", "false"))
+	mmrLambda, _ := strconv.ParseFloat(getEnv("MMR_LAMBDA", "0.5"), 64)
+	mmrFetchK, _ := strconv.Atoi(getEnv("MMR_FETCH_K", "20"))
+	rerank, _ := strconv.ParseBool(getEnv("RERANK_ENABLED", "false"))
+	rerankCandidates, _ := strconv.Atoi(getEnv("RERANK_CANDIDATES", "20"))
+	tieBreakEpsilon, _ := strconv.ParseFloat(getEnv("TIE_BREAK_EPSILON", "0"), 32)
+	confidenceThreshold, _ := strconv.ParseFloat(getEnv("STREAM_CONFIDENCE_THRESHOLD", "0"), 32)
+	disclaimerThreshold, _ := strconv.ParseFloat(getEnv("CONFIDENCE_DISCLAIMER_THRESHOLD", "0"), 32)
+
+	streamMinFlushBytes, _ := strconv.Atoi(getEnv("STREAM_MIN_FLUSH_BYTES", "0"))
+	chatETag, _ := strconv.ParseBool(getEnv("CHAT_ETAG", "false"))
+	debugResponses, _ := strconv.ParseBool(getEnv("DEBUG_RESPONSES", "false"))
+	structuredAnswers, _ := strconv.ParseBool(getEnv("STRUCTURED_ANSWERS", "false"))
+	citations, _ := strconv.ParseBool(getEnv("CITATIONS", "false"))
+	batchConcurrency, _ := strconv.Atoi(getEnv("BATCH_CONCURRENCY", "4"))
+	rateLimitRPS, _ := strconv.ParseFloat(getEnv("RATE_LIMIT_RPS", "0"), 64)
+	rateLimitBurst, _ := strconv.Atoi(getEnv("RATE_LIMIT_BURST", "5"))
+	qdrantUseTLS, _ := strconv.ParseBool(getEnv("QDRANT_USE_TLS", "false"))
+	hybridSearch, _ := strconv.ParseBool(getEnv("HYBRID_SEARCH", "false"))
+	maxRequestBytes, _ := strconv.ParseInt(getEnv("MAX_REQUEST_BYTES", "1048576"), 10, 64)
+	strictJSON, _ := strconv.ParseBool(getEnv("STRICT_JSON", "false"))
+	embedNormalize, _ := strconv.ParseBool(getEnv("EMBED_NORMALIZE", "true"))
+	embedCacheSize, _ := strconv.Atoi(getEnv("EMBED_CACHE_SIZE", "1000"))
+	responseCacheSize, _ := strconv.Atoi(getEnv("RESPONSE_CACHE_SIZE", "0"))
+	queryStatsSize, _ := strconv.Atoi(getEnv("QUERY_STATS_SIZE", "1000"))
+	cacheWarmupQueries, _ := strconv.Atoi(getEnv("CACHE_WARMUP_QUERIES", "50"))
+	embedConcurrency, _ := strconv.Atoi(getEnv("EMBED_CONCURRENCY", "4"))
+	embedMaxAttempts, _ := strconv.Atoi(getEnv("EMBED_MAX_ATTEMPTS", "3"))
+	httpMaxIdleConns, _ := strconv.Atoi(getEnv("HTTP_MAX_IDLE_CONNS", "100"))
+	httpMaxIdleConnsPerHost, _ := strconv.Atoi(getEnv("HTTP_MAX_IDLE_CONNS_PER_HOST", "32"))
+	ingestUpsertConcurrency, _ := strconv.Atoi(getEnv("INGEST_UPSERT_CONCURRENCY", "1"))
+	strictGrounding, _ := strconv.ParseBool(getEnv("STRICT_GROUNDING", "false"))
+	strictMinScore, _ := strconv.ParseFloat(getEnv("STRICT_GROUNDING_MIN_SCORE", "0.5"), 32)
+	strictMinOverlap, _ := strconv.ParseFloat(getEnv("STRICT_GROUNDING_MIN_OVERLAP", "0.5"), 32)
+	conversationMaxTurns, _ := strconv.Atoi(getEnv("CONVERSATION_MAX_TURNS", "10"))
+	conversationMax, _ := strconv.Atoi(getEnv("CONVERSATION_MAX", "10000"))
+	conversationAnswerMaxChars, _ := strconv.Atoi(getEnv("CONVERSATION_ANSWER_MAX_CHARS", "2000"))
+	metaDetection, _ := strconv.ParseBool(getEnv("META_DETECTION", "true"))
 
 	return &Config{
 		GroqAPIKey:     getEnv("GROQ_API_KEY", ""),
 		QdrantHost:     getEnv("QDRANT_HOST", "localhost"),
-		QdrantPort:     qdrantPort,
 		Port:           getEnv("PORT", "8080"),
 		CollectionName: getEnv("COLLECTION_NAME", "knowledge_base"),
 		EmbeddingDim:   embeddingDim,
+
+		ModuleCollections: parseModuleCollections(getEnv("MODULE_COLLECTIONS", "")),
+
+		GroqModel:   getEnv("GROQ_MODEL", "meta-llama/llama-4-maverick-17b-128e-instruct"),
+		Temperature: temperature,
+		GroqBaseURL: getEnv("GROQ_BASE_URL", "https://api.groq.com/openai/v1"),
+
+		DeterministicSeed: deterministicSeed,
+
+		QdrantHTTPPort: qdrantHTTPPort,
+		QdrantUseTLS:   qdrantUseTLS,
+		QdrantAPIKey:   getEnv("QDRANT_API_KEY", ""),
+		HybridSearch:   hybridSearch,
+		PayloadIndexes: payloadIndexes,
+
+		SystemPromptFile: getEnv("SYSTEM_PROMPT_FILE", ""),
+
+		LLMMaxAttempts:    llmMaxAttempts,
+		LLMRetryBaseDelay: getDuration("LLM_RETRY_BASE_DELAY", 500*time.Millisecond),
+
+		LLMMaxTokens:        llmMaxTokens,
+		LLMContextWindow:    llmContextWindow,
+		LLMStopSequences:    parseStopSequences(getEnv("LLM_STOP_SEQUENCES", "")),
+		ContextBudgetTokens: contextBudgetTokens,
+		LLMMaxContinuations: llmMaxContinuations,
+
+		LLMRequestTimeout:    getDuration("LLM_REQUEST_TIMEOUT", 60*time.Second),
+		LLMStreamIdleTimeout: getDuration("LLM_STREAM_IDLE_TIMEOUT", 30*time.Second),
+
+		QueryRewriting: queryRewriting,
+		QueryVariants:  queryVariants,
+
+		MMREnabled: mmrEnabled,
+		MMRLambda:  mmrLambda,
+		MMRFetchK:  mmrFetchK,
+
+		Rerank:           rerank,
+		RerankCandidates: rerankCandidates,
+
+		ScoreThreshold:          float32(scoreThreshold),
+		VariationMatchThreshold: variationMatchThreshold,
+		BroadenRetrieval:        broadenRetrieval,
+		TieBreakEpsilon:         float32(tieBreakEpsilon),
+		TieBreakKeys:            splitList(getEnv("TIE_BREAK_KEYS", "module,topic,id"), ","),
+
+		RouteTimeouts:        parseRouteTimeouts(getEnv("ROUTE_TIMEOUTS", "/chat=120s,/v1/chat/completions=120s,/chat/batch=600s,/chat/estimate=15s,/chat/diagnose=15s,/health=2s,/ready=5s,/stats=2s,/admin/stats=5s,/metrics=2s,/warmup=120s")),
+		ServerReadTimeout:    getDuration("SERVER_READ_TIMEOUT", 30*time.Second),
+		ServerWriteTimeout:   getDuration("SERVER_WRITE_TIMEOUT", 120*time.Second),
+		ShutdownTimeout:      getDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+		EmbedTimeout:         getDuration("EMBED_TIMEOUT", 120*time.Second),
+		EmbedRetryBaseDelay:  getDuration("EMBED_RETRY_BASE_DELAY", 500*time.Millisecond),
+		EmbedWarmupTimeout:   getDuration("EMBED_WARMUP_TIMEOUT", 120*time.Second),
+		QdrantConnectTimeout: getDuration("QDRANT_CONNECT_TIMEOUT", 30*time.Second),
+
+		HTTPMaxIdleConns:        httpMaxIdleConns,
+		HTTPMaxIdleConnsPerHost: httpMaxIdleConnsPerHost,
+		HTTPIdleConnTimeout:     getDuration("HTTP_IDLE_CONN_TIMEOUT", 90*time.Second),
+
+		MaxRequestBytes: maxRequestBytes,
+		StrictJSON:      strictJSON,
+
+		CORSAllowedOrigins: splitList(getEnv("CORS_ALLOWED_ORIGINS", ""), ","),
+		CORSAllowedMethods: splitList(getEnv("CORS_ALLOWED_METHODS", "GET,POST,DELETE,OPTIONS"), ","),
+		CORSAllowedHeaders: splitList(getEnv("CORS_ALLOWED_HEADERS", "Content-Type,Authorization"), ","),
+
+		EmbeddingProvider: getEnv("EMBEDDING_PROVIDER", "ollama"),
+		EmbeddingURL:      getEnv("EMBEDDING_URL", ""),
+		EmbeddingAPIKey:   getEnv("EMBEDDING_API_KEY", ""),
+		EmbeddingModel:    getEnv("EMBEDDING_MODEL", ""),
+		EmbedNormalize:    embedNormalize,
+		EmbedMaxAttempts:  embedMaxAttempts,
+
+		StreamConfidenceThreshold: float32(confidenceThreshold),
+		LowConfidenceMessage:      getEnv("LOW_CONFIDENCE_MESSAGE", ""),
+
+		ConfidenceDisclaimerThreshold: float32(disclaimerThreshold),
+		ConfidenceDisclaimer:          getEnv("CONFIDENCE_DISCLAIMER", ""),
+
+		RetrievalConcurrency: retrievalConcurrency,
+
+		MetaDetection: metaDetection,
+		MetaPatterns:  splitList(getEnv("META_PATTERNS", ""), ";"),
+
+		AnswerCleanupRules:    splitList(getEnv("ANSWER_CLEANUP_RULES", ""), ","),
+		AnswerCleanupPatterns: splitList(getEnv("ANSWER_CLEANUP_PATTERNS", ""), ";"),
+
+		StreamMinFlushBytes:     streamMinFlushBytes,
+		StreamKeepAliveInterval: getDuration("STREAM_KEEPALIVE_INTERVAL", 15*time.Second),
+		ChatETag:                chatETag,
+		DebugResponses:          debugResponses,
+
+		BatchConcurrency: batchConcurrency,
+
+		APIKeys:       splitList(getEnv("API_KEYS", ""), ","),
+		APIKeyRoles:   parseKeyLists(getEnv("API_KEY_ROLES", "")),
+		APIKeyModules: parseKeyLists(getEnv("API_KEY_MODULES", "")),
+
+		RateLimitRPS:   rateLimitRPS,
+		RateLimitBurst: rateLimitBurst,
+
+		ModerationURL:     getEnv("MODERATION_URL", ""),
+		ModerationAPIKey:  getEnv("MODERATION_API_KEY", ""),
+		ModerationMessage: getEnv("MODERATION_MESSAGE", ""),
+
+		StructuredAnswers: structuredAnswers,
+		Citations:         citations,
+
+		EmbedCacheSize:          embedCacheSize,
+		ResponseCacheSize:       responseCacheSize,
+		ResponseCacheTTL:        getDuration("RESPONSE_CACHE_TTL", 10*time.Minute),
+		QueryStatsSize:          queryStatsSize,
+		QueryStatsFile:          getEnv("QUERY_STATS_FILE", ""),
+		CacheWarmupQueries:      cacheWarmupQueries,
+		EmbedConcurrency:        embedConcurrency,
+		IngestUpsertConcurrency: ingestUpsertConcurrency,
+
+		StrictGrounding:           strictGrounding,
+		StrictGroundingModules:    splitList(getEnv("STRICT_GROUNDING_MODULES", ""), ","),
+		StrictGroundingMinScore:   float32(strictMinScore),
+		StrictGroundingMinOverlap: float32(strictMinOverlap),
+
+		ConversationMaxTurns:       conversationMaxTurns,
+		ConversationMax:            conversationMax,
+		ConversationAnswerMaxChars: conversationAnswerMaxChars,
+		ConversationStore:          getEnv("CONVERSATION_STORE", "memory"),
+		ConversationFile:           getEnv("CONVERSATION_FILE", "conversations.jsonl"),
+
+		LogFormat: getEnv("LOG_FORMAT", "text"),
+		LogLevel:  getEnv("LOG_LEVEL", "info"),
 	}
 }
 
@@ -43,3 +525,112 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// getDuration parses a duration env value, logging and using fallback when
+// it is invalid.
+func getDuration(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using %v", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+// parseRouteTimeouts parses "path=duration" pairs separated by commas.
+func parseRouteTimeouts(value string) map[string]time.Duration {
+	timeouts := make(map[string]time.Duration)
+	for _, item := range splitList(value, ",") {
+		path, raw, ok := strings.Cut(item, "=")
+		if !ok {
+			log.Printf("Ignoring invalid route timeout %q", item)
+			continue
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil {
+			log.Printf("Ignoring invalid route timeout %q: %v", item, err)
+			continue
+		}
+		timeouts[strings.TrimSpace(path)] = d
+	}
+	return timeouts
+}
+
+// parseModuleCollections parses "module=collection" pairs separated by
+// commas.
+func parseModuleCollections(value string) map[string]string {
+	collections := make(map[string]string)
+	for _, item := range splitList(value, ",") {
+		module, collection, ok := strings.Cut(item, "=")
+		module, collection = strings.TrimSpace(module), strings.TrimSpace(collection)
+		if !ok || module == "" || collection == "" {
+			log.Printf("Ignoring invalid module collection %q", item)
+			continue
+		}
+		collections[module] = collection
+	}
+	return collections
+}
+
+// parseKeyLists parses "key=a|b" pairs separated by commas into a map of
+// lists.
+func parseKeyLists(value string) map[string][]string {
+	lists := make(map[string][]string)
+	for _, item := range splitList(value, ",") {
+		key, raw, ok := strings.Cut(item, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			// Don't log the item; it may hold an API key
+			log.Printf("Ignoring a key list entry without key=values")
+			continue
+		}
+		lists[key] = splitList(raw, "|")
+	}
+	return lists
+}
+
+// parsePayloadIndexes parses "field[:schema]" items separated by commas;
+// the schema defaults to keyword.
+func parsePayloadIndexes(value string) map[string]string {
+	indexes := make(map[string]string)
+	for _, item := range splitList(value, ",") {
+		field, schema, ok := strings.Cut(item, ":")
+		if !ok {
+			schema = "keyword"
+		}
+		indexes[strings.TrimSpace(field)] = strings.TrimSpace(schema)
+	}
+	return indexes
+}
+
+// parseStopSequences parses stop sequences separated by "|". Each sequence
+// is unescaped like a Go string literal, so "\n---" stops at a newline
+// followed by three dashes; sequences that don't unescape are kept as is.
+func parseStopSequences(value string) []string {
+	var stop []string
+	for _, item := range strings.Split(value, "|") {
+		if item == "" {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(`"` + item + `"`); err == nil {
+			item = unquoted
+		}
+		stop = append(stop, item)
+	}
+	return stop
+}
+
+// splitList splits a separated env value, dropping empty items.
+func splitList(value, sep string) []string {
+	var items []string
+	for _, item := range strings.Split(value, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// entryOverheadBytes approximates the bookkeeping cost of one cache entry
+// (list element, map bucket and slice headers).
+const entryOverheadBytes = 96
+
+// EmbeddingCache is a thread-safe LRU cache of embeddings keyed by text.
+type EmbeddingCache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+	bytes    int64
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+type embeddingEntry struct {
+	key    string
+	vector []float32
+}
+
+// NewEmbeddingCache creates an LRU cache holding up to capacity embeddings.
+func NewEmbeddingCache(capacity int) *EmbeddingCache {
+	return &EmbeddingCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get returns the cached embedding for key, marking it recently used.
+func (c *EmbeddingCache) Get(key string) ([]float32, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	c.ll.MoveToFront(el)
+	return el.Value.(*embeddingEntry).vector, true
+}
+
+// Contains reports whether key is cached without counting a hit or miss or
+// marking it recently used.
+func (c *EmbeddingCache) Contains(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.items[key]
+	return ok
+}
+
+// Put stores an embedding, evicting the least recently used entry when full.
+func (c *EmbeddingCache) Put(key string, vector []float32) {
+	if c.capacity <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*embeddingEntry)
+		c.bytes += entrySize(key, vector) - entrySize(key, entry.vector)
+		entry.vector = vector
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&embeddingEntry{key: key, vector: vector})
+	c.bytes += entrySize(key, vector)
+
+	for c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		entry := oldest.Value.(*embeddingEntry)
+		c.ll.Remove(oldest)
+		delete(c.items, entry.key)
+		c.bytes -= entrySize(entry.key, entry.vector)
+	}
+}
+
+// CacheStats reports the cache's entry count, approximate footprint and hit
+// counters.
+func (c *EmbeddingCache) CacheStats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{
+		Name:        "embedding",
+		Entries:     c.ll.Len(),
+		ApproxBytes: c.bytes,
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+	}
+}
+
+func entrySize(key string, vector []float32) int64 {
+	return int64(len(key)) + int64(len(vector))*4 + entryOverheadBytes
+}
@@ -0,0 +1,74 @@
+package cache
+
+import "testing"
+
+func TestEmbeddingCacheFootprint(t *testing.T) {
+	tests := []struct {
+		name        string
+		capacity    int
+		puts        []string
+		wantEntries int
+	}{
+		{name: "below capacity", capacity: 3, puts: []string{"a", "b"}, wantEntries: 2},
+		{name: "at capacity", capacity: 3, puts: []string{"a", "b", "c"}, wantEntries: 3},
+		{name: "over capacity evicts", capacity: 2, puts: []string{"a", "b", "c", "d"}, wantEntries: 2},
+		{name: "overwrite keeps one entry", capacity: 2, puts: []string{"a", "a"}, wantEntries: 1},
+		{name: "disabled", capacity: 0, puts: []string{"a"}, wantEntries: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewEmbeddingCache(tt.capacity)
+			for _, key := range tt.puts {
+				c.Put(key, make([]float32, 8))
+			}
+			st := c.CacheStats()
+			if st.Entries != tt.wantEntries {
+				t.Errorf("Entries = %d, want %d", st.Entries, tt.wantEntries)
+			}
+			if want := int64(tt.wantEntries) * entrySize("a", make([]float32, 8)); st.ApproxBytes != want {
+				t.Errorf("ApproxBytes = %d, want %d", st.ApproxBytes, want)
+			}
+		})
+	}
+}
+
+func TestEmbeddingCacheEvictionShrinksFootprint(t *testing.T) {
+	c := NewEmbeddingCache(2)
+	c.Put("small", make([]float32, 4))
+	c.Put("large", make([]float32, 1024))
+	full := c.CacheStats().ApproxBytes
+
+	// Evicts small, then large, leaving two small vectors
+	c.Put("next", make([]float32, 4))
+	c.Put("last", make([]float32, 4))
+	after := c.CacheStats()
+	if after.Entries != 2 {
+		t.Errorf("Entries = %d, want 2", after.Entries)
+	}
+	if after.ApproxBytes >= full {
+		t.Errorf("footprint %d after evicting the large vector, want below %d", after.ApproxBytes, full)
+	}
+}
+
+func TestRegistrySummary(t *testing.T) {
+	embeddings := NewEmbeddingCache(10)
+	embeddings.Put("a", make([]float32, 8))
+	embeddings.Put("b", make([]float32, 8))
+	responses := NewResponseCache(10, 0)
+	responses.Put("q", []byte("answer"))
+
+	r := NewRegistry()
+	r.Register(embeddings)
+	r.Register(responses)
+	sum := r.Summary()
+
+	if len(sum.Caches) != 2 {
+		t.Fatalf("%d caches reported, want 2", len(sum.Caches))
+	}
+	if sum.TotalEntries != 3 {
+		t.Errorf("TotalEntries = %d, want 3", sum.TotalEntries)
+	}
+	if want := embeddings.CacheStats().ApproxBytes + responses.CacheStats().ApproxBytes; sum.TotalBytes != want {
+		t.Errorf("TotalBytes = %d, want %d", sum.TotalBytes, want)
+	}
+}
@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// QueryCount is a tracked query and how often it was asked.
+type QueryCount struct {
+	Query string `json:"query"`
+	Count uint64 `json:"count"`
+}
+
+// QueryTracker counts how often queries are asked, keeping at most
+// capacity of them, so the most frequent ones can warm the caches. When
+// full, a new query replaces the least frequent one.
+type QueryTracker struct {
+	mu       sync.Mutex
+	capacity int
+	counts   map[string]uint64
+}
+
+// NewQueryTracker creates a tracker of up to capacity queries.
+func NewQueryTracker(capacity int) *QueryTracker {
+	return &QueryTracker{
+		capacity: capacity,
+		counts:   make(map[string]uint64),
+	}
+}
+
+// Record counts one occurrence of query.
+func (t *QueryTracker) Record(query string) {
+	if t.capacity <= 0 || query == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.counts[query]; !ok && len(t.counts) >= t.capacity {
+		t.evictLeastFrequent()
+	}
+	t.counts[query]++
+}
+
+// Top returns the n most frequent queries, most frequent first. Ties are
+// ordered by query so the result is deterministic.
+func (t *QueryTracker) Top(n int) []QueryCount {
+	t.mu.Lock()
+	all := make([]QueryCount, 0, len(t.counts))
+	for q, c := range t.counts {
+		all = append(all, QueryCount{Query: q, Count: c})
+	}
+	t.mu.Unlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Count != all[j].Count {
+			return all[i].Count > all[j].Count
+		}
+		return all[i].Query < all[j].Query
+	})
+	if n >= 0 && len(all) > n {
+		all = all[:n]
+	}
+	return all
+}
+
+// Save writes the tracked queries to path as JSON, replacing it atomically.
+func (t *QueryTracker) Save(path string) error {
+	data, err := json.Marshal(t.Top(-1))
+	if err != nil {
+		return fmt.Errorf("encode query stats: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("save query stats: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("save query stats: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("save query stats: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("save query stats: %w", err)
+	}
+	return nil
+}
+
+// Load adds the queries saved at path to the tracker, keeping the most
+// frequent ones when they exceed its capacity. A missing file is not an
+// error.
+func (t *QueryTracker) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("load query stats: %w", err)
+	}
+	var saved []QueryCount
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("decode query stats: %w", err)
+	}
+	sort.SliceStable(saved, func(i, j int) bool { return saved[i].Count > saved[j].Count })
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, qc := range saved {
+		if qc.Query == "" {
+			continue
+		}
+		if _, ok := t.counts[qc.Query]; !ok && len(t.counts) >= t.capacity {
+			break
+		}
+		t.counts[qc.Query] += qc.Count
+	}
+	return nil
+}
+
+// CacheStats reports the number of tracked queries.
+func (t *QueryTracker) CacheStats() Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var bytes int64
+	for q := range t.counts {
+		bytes += int64(len(q)) + entryOverheadBytes
+	}
+	return Stats{Name: "query_stats", Entries: len(t.counts), ApproxBytes: bytes}
+}
+
+func (t *QueryTracker) evictLeastFrequent() {
+	var victim string
+	var lowest uint64
+	for q, c := range t.counts {
+		if victim == "" || c < lowest || (c == lowest && q > victim) {
+			victim, lowest = q, c
+		}
+	}
+	delete(t.counts, victim)
+}
@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestQueryTrackerTop(t *testing.T) {
+	tr := NewQueryTracker(10)
+	for query, n := range map[string]int{"payroll": 5, "leave": 3, "holidays": 3, "login": 1} {
+		for i := 0; i < n; i++ {
+			tr.Record(query)
+		}
+	}
+
+	tests := []struct {
+		n    int
+		want []string
+	}{
+		{n: 1, want: []string{"payroll"}},
+		{n: 3, want: []string{"payroll", "holidays", "leave"}},
+		{n: 10, want: []string{"payroll", "holidays", "leave", "login"}},
+		{n: 0, want: []string{}},
+	}
+	for _, tt := range tests {
+		got := []string{}
+		for _, qc := range tr.Top(tt.n) {
+			got = append(got, qc.Query)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Top(%d) = %v, want %v", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestQueryTrackerBounded(t *testing.T) {
+	tr := NewQueryTracker(2)
+	tr.Record("payroll")
+	tr.Record("payroll")
+	tr.Record("leave")
+	tr.Record("login") // replaces leave, the least frequent
+
+	want := []QueryCount{{Query: "payroll", Count: 2}, {Query: "login", Count: 1}}
+	if got := tr.Top(-1); !reflect.DeepEqual(got, want) {
+		t.Errorf("Top = %v, want %v", got, want)
+	}
+	if st := tr.CacheStats(); st.Entries != 2 {
+		t.Errorf("Entries = %d, want 2", st.Entries)
+	}
+}
+
+func TestQueryTrackerSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "query-stats.json")
+
+	saved := NewQueryTracker(10)
+	for query, n := range map[string]int{"payroll": 3, "leave": 2, "login": 1} {
+		for i := 0; i < n; i++ {
+			saved.Record(query)
+		}
+	}
+	if err := saved.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		capacity int
+		want     []QueryCount
+	}{
+		{
+			name:     "round trip",
+			capacity: 10,
+			want:     []QueryCount{{"payroll", 3}, {"leave", 2}, {"login", 1}},
+		},
+		{
+			name:     "smaller capacity keeps the most frequent",
+			capacity: 2,
+			want:     []QueryCount{{"payroll", 3}, {"leave", 2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loaded := NewQueryTracker(tt.capacity)
+			if err := loaded.Load(path); err != nil {
+				t.Fatal(err)
+			}
+			if got := loaded.Top(-1); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Top = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if err := NewQueryTracker(10).Load(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("Load of a missing file = %v, want nil", err)
+	}
+}
@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ResponseCache is a thread-safe LRU cache of encoded answers keyed by an
+// opaque request key. Entries expire after a TTL so answers pick up
+// knowledge base changes.
+type ResponseCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	ll       *list.List
+	items    map[string]*list.Element
+	bytes    int64
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+
+	now func() time.Time
+}
+
+type responseEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewResponseCache creates an LRU cache holding up to capacity answers for
+// ttl each. A zero ttl keeps answers until they are evicted.
+func NewResponseCache(capacity int, ttl time.Duration) *ResponseCache {
+	return &ResponseCache{
+		capacity: capacity,
+		ttl:      ttl,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+		now:      time.Now,
+	}
+}
+
+// Get returns the cached value for key, marking it recently used. Expired
+// entries are dropped and count as misses.
+func (c *ResponseCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if ok && c.expired(el.Value.(*responseEntry)) {
+		c.remove(el)
+		ok = false
+	}
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	c.ll.MoveToFront(el)
+	return el.Value.(*responseEntry).value, true
+}
+
+// Put stores a value, evicting the least recently used entry when full.
+func (c *ResponseCache) Put(key string, value []byte) {
+	if c.capacity <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expires time.Time
+	if c.ttl > 0 {
+		expires = c.now().Add(c.ttl)
+	}
+
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*responseEntry)
+		c.bytes += int64(len(value)) - int64(len(entry.value))
+		entry.value, entry.expires = value, expires
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&responseEntry{key: key, value: value, expires: expires})
+	c.bytes += responseEntrySize(key, value)
+
+	for c.ll.Len() > c.capacity {
+		c.remove(c.ll.Back())
+	}
+}
+
+// CacheStats reports the cache's entry count, approximate footprint and hit
+// counters.
+func (c *ResponseCache) CacheStats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{
+		Name:        "response",
+		Entries:     c.ll.Len(),
+		ApproxBytes: c.bytes,
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+	}
+}
+
+func (c *ResponseCache) expired(entry *responseEntry) bool {
+	return !entry.expires.IsZero() && c.now().After(entry.expires)
+}
+
+func (c *ResponseCache) remove(el *list.Element) {
+	entry := el.Value.(*responseEntry)
+	c.ll.Remove(el)
+	delete(c.items, entry.key)
+	c.bytes -= responseEntrySize(entry.key, entry.value)
+}
+
+func responseEntrySize(key string, value []byte) int64 {
+	return int64(len(key)) + int64(len(value)) + entryOverheadBytes
+}
@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResponseCache(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := NewResponseCache(2, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Put("a", []byte("answer a"))
+	c.Put("b", []byte("answer b"))
+	c.Get("a") // a is now the most recently used
+	c.Put("c", []byte("answer c"))
+
+	tests := []struct {
+		key  string
+		want string
+		ok   bool
+	}{
+		{key: "a", want: "answer a", ok: true},
+		{key: "b", ok: false},
+		{key: "c", want: "answer c", ok: true},
+	}
+	for _, tt := range tests {
+		got, ok := c.Get(tt.key)
+		if ok != tt.ok || string(got) != tt.want {
+			t.Errorf("Get(%q) = %q, %v; want %q, %v", tt.key, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Error("expired entry was returned")
+	}
+	if st := c.CacheStats(); st.Entries != 1 {
+		t.Errorf("Entries = %d after expiry, want 1", st.Entries)
+	}
+}
@@ -0,0 +1,57 @@
+package cache
+
+import "sync"
+
+// Stats describes the approximate footprint of a single cache.
+type Stats struct {
+	Name        string `json:"name"`
+	Entries     int    `json:"entries"`
+	ApproxBytes int64  `json:"approx_bytes"`
+	Hits        uint64 `json:"hits"`
+	Misses      uint64 `json:"misses"`
+}
+
+// Reporter is implemented by caches that can report their footprint.
+type Reporter interface {
+	CacheStats() Stats
+}
+
+// Summary aggregates the stats of all registered caches.
+type Summary struct {
+	Caches       []Stats `json:"caches"`
+	TotalEntries int     `json:"total_entries"`
+	TotalBytes   int64   `json:"total_approx_bytes"`
+}
+
+// Registry collects cache reporters so their combined memory use is visible.
+type Registry struct {
+	mu        sync.RWMutex
+	reporters []Reporter
+}
+
+// NewRegistry creates an empty cache registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a cache to the registry.
+func (r *Registry) Register(rep Reporter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reporters = append(r.reporters, rep)
+}
+
+// Summary returns a snapshot of every registered cache.
+func (r *Registry) Summary() Summary {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sum := Summary{Caches: make([]Stats, 0, len(r.reporters))}
+	for _, rep := range r.reporters {
+		st := rep.CacheStats()
+		sum.Caches = append(sum.Caches, st)
+		sum.TotalEntries += st.Entries
+		sum.TotalBytes += st.ApproxBytes
+	}
+	return sum
+}
@@ -0,0 +1,167 @@
+package ingest
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// CSVColumns maps KnowledgeEntry fields to CSV header names. Headers match
+// case-insensitively.
+type CSVColumns struct {
+	ID              string
+	Module          string
+	Topic           string
+	Roles           string
+	QueryVariations string
+	Answer          string
+
+	// ListSeparator splits the roles and query variations cells.
+	ListSeparator string
+}
+
+// DefaultCSVColumns reads the columns named after the JSON fields, with
+// list cells separated by semicolons.
+var DefaultCSVColumns = CSVColumns{
+	ID:              "id",
+	Module:          "module",
+	Topic:           "topic",
+	Roles:           "roles",
+	QueryVariations: "query_variations",
+	Answer:          "answer",
+	ListSeparator:   ";",
+}
+
+// WithCSVColumns sets the column mapping used by IngestCSVFile. Empty
+// fields keep their defaults.
+func WithCSVColumns(columns CSVColumns) Option {
+	return func(s *Service) {
+		set := func(dst *string, v string) {
+			if v != "" {
+				*dst = v
+			}
+		}
+		set(&s.csvColumns.ID, columns.ID)
+		set(&s.csvColumns.Module, columns.Module)
+		set(&s.csvColumns.Topic, columns.Topic)
+		set(&s.csvColumns.Roles, columns.Roles)
+		set(&s.csvColumns.QueryVariations, columns.QueryVariations)
+		set(&s.csvColumns.Answer, columns.Answer)
+		set(&s.csvColumns.ListSeparator, columns.ListSeparator)
+	}
+}
+
+// IngestCSVFile parses and ingests a CSV file with a header row, mapping
+// columns to entry fields with the configured CSVColumns. The id, module,
+// topic and answer columns are required; roles and query variations are
+// optional. It returns the number of entries ingested.
+func (s *Service) IngestCSVFile(ctx context.Context, filePath string) (int, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return 0, fmt.Errorf("open file: %w", err)
+	}
+	defer f.Close()
+
+	entries, err := s.parseCSV(f)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s: %w", filePath, err)
+	}
+
+	log.Printf("Loaded %d entries from %s", len(entries), filePath)
+
+	return s.ingestEntries(ctx, SourceKindCSV, entries)
+}
+
+// parseCSV reads entries from CSV, checking the header has every required
+// column before reading any rows.
+func (s *Service) parseCSV(r io.Reader) ([]KnowledgeEntry, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("missing header row")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		// Spreadsheet exports often start with a byte order mark
+		name = strings.TrimPrefix(name, "\ufeff")
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	column := func(name string) int {
+		if i, ok := index[strings.ToLower(name)]; ok {
+			return i
+		}
+		return -1
+	}
+
+	cols := s.csvColumns
+	var missing []string
+	for _, name := range []string{cols.ID, cols.Module, cols.Topic, cols.Answer} {
+		if column(name) < 0 {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing required columns %s (header has %s)",
+			strings.Join(missing, ", "), strings.Join(header, ", "))
+	}
+
+	idCol, moduleCol, topicCol, answerCol := column(cols.ID), column(cols.Module), column(cols.Topic), column(cols.Answer)
+	rolesCol, variationsCol := column(cols.Roles), column(cols.QueryVariations)
+
+	var entries []KnowledgeEntry
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		cell := func(i int) string {
+			if i < 0 {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		entry := KnowledgeEntry{
+			ID:              cell(idCol),
+			Module:          cell(moduleCol),
+			Topic:           cell(topicCol),
+			Roles:           splitCell(cell(rolesCol), cols.ListSeparator),
+			QueryVariations: splitCell(cell(variationsCol), cols.ListSeparator),
+			Answer:          cell(answerCol),
+		}
+		if entry.ID == "" {
+			return nil, fmt.Errorf("line %d: empty %s", line, cols.ID)
+		}
+		if entry.Answer == "" {
+			return nil, fmt.Errorf("line %d: entry %s has an empty %s", line, entry.ID, cols.Answer)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// splitCell splits a list cell, dropping empty items.
+func splitCell(value, sep string) []string {
+	var items []string
+	for _, item := range strings.Split(value, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
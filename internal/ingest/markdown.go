@@ -0,0 +1,159 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"go-bot/internal/rag"
+)
+
+// DefaultDocumentRoles are the roles of chunked documents when none are
+// set: visible to everyone, so role-filtered queries still find them.
+var DefaultDocumentRoles = []string{rag.AllUsersRole}
+
+// WithChunking sets the chunk size and overlap, in characters, used when
+// ingesting markdown and plain-text files.
+func WithChunking(size, overlap int) Option {
+	return func(s *Service) {
+		if size > 0 {
+			s.chunkSize = size
+		}
+		if overlap >= 0 && overlap < s.chunkSize {
+			s.chunkOverlap = overlap
+		}
+	}
+}
+
+// WithDocumentRoles sets the roles every markdown and plain-text chunk is
+// visible to. An empty list keeps DefaultDocumentRoles.
+func WithDocumentRoles(roles []string) Option {
+	return func(s *Service) {
+		if len(roles) > 0 {
+			s.documentRoles = roles
+		}
+	}
+}
+
+// IngestMarkdownDir walks dir and ingests every .md and .txt file, split into
+// overlapping chunks. The module is the top-level directory under dir (or
+// the first heading for files at the root) and the topic is the file's first
+// heading, falling back to its name. Chunks get the configured document
+// roles. It returns the number of chunks
+// ingested.
+func (s *Service) IngestMarkdownDir(ctx context.Context, dir string) (int, error) {
+	var entries []KnowledgeEntry
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if ext != ".md" && ext != ".txt" {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", path, err)
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return fmt.Errorf("relative path for %s: %w", path, err)
+		}
+
+		fileEntries := s.documentEntries(filepath.ToSlash(rel), string(data))
+		log.Printf("Split %s into %d chunks", rel, len(fileEntries))
+		entries = append(entries, fileEntries...)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("walk %s: %w", dir, err)
+	}
+
+	log.Printf("Loaded %d chunks from %s", len(entries), dir)
+
+	return s.ingestEntries(ctx, SourceKindMarkdown, entries)
+}
+
+// documentEntries turns a document into one entry per chunk.
+func (s *Service) documentEntries(relPath, content string) []KnowledgeEntry {
+	heading := firstHeading(content)
+	name := strings.TrimSuffix(filepath.Base(relPath), filepath.Ext(relPath))
+
+	topic := heading
+	if topic == "" {
+		topic = name
+	}
+	module := topic
+	if i := strings.Index(relPath, "/"); i > 0 {
+		module = relPath[:i]
+	}
+
+	chunks := chunkText(content, s.chunkSize, s.chunkOverlap)
+	entries := make([]KnowledgeEntry, len(chunks))
+	for i, chunk := range chunks {
+		entries[i] = KnowledgeEntry{
+			ID:     fmt.Sprintf("%s#%d", relPath, i),
+			Module: module,
+			Topic:  topic,
+			Roles:  s.documentRoles,
+			Answer: chunk,
+			Source: relPath,
+		}
+	}
+	return entries
+}
+
+// firstHeading returns the text of the first markdown heading, if any.
+func firstHeading(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#") {
+			return strings.TrimSpace(strings.TrimLeft(line, "#"))
+		}
+	}
+	return ""
+}
+
+// chunkText splits text into chunks of at most size characters, each
+// overlapping the previous by overlap characters. Chunks end on whitespace
+// where possible so words aren't split.
+func chunkText(text string, size, overlap int) []string {
+	runes := []rune(strings.TrimSpace(text))
+	if len(runes) == 0 {
+		return nil
+	}
+
+	var chunks []string
+	for start := 0; start < len(runes); {
+		end := start + size
+		if end >= len(runes) {
+			chunks = append(chunks, strings.TrimSpace(string(runes[start:])))
+			break
+		}
+
+		// Back up to the last whitespace in the second half of the window
+		for i := end; i > start+size/2; i-- {
+			if unicode.IsSpace(runes[i]) {
+				end = i
+				break
+			}
+		}
+		chunks = append(chunks, strings.TrimSpace(string(runes[start:end])))
+
+		next := end - overlap
+		if next <= start {
+			next = end
+		}
+		start = next
+	}
+	return chunks
+}
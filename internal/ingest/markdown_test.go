@@ -0,0 +1,32 @@
+package ingest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDocumentEntriesRoles(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{name: "default", want: DefaultDocumentRoles},
+		{name: "configured", opts: []Option{WithDocumentRoles([]string{"HR Manager"})}, want: []string{"HR Manager"}},
+		{name: "empty keeps default", opts: []Option{WithDocumentRoles(nil)}, want: DefaultDocumentRoles},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewService(nil, nil, tt.opts...)
+			entries := s.documentEntries("payroll/run.md", "# Running payroll\n\nOpen the Payroll dashboard.")
+			if len(entries) == 0 {
+				t.Fatal("no entries")
+			}
+			for _, e := range entries {
+				if !reflect.DeepEqual(e.Roles, tt.want) {
+					t.Errorf("%s roles = %v, want %v", e.ID, e.Roles, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -1,18 +1,25 @@
 package ingest
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"go-bot/internal/llm"
 	"go-bot/internal/vector"
 )
 
-// KnowledgeEntry represents a single entry from Knowledgebase.json.
+// KnowledgeEntry represents a single entry from Knowledgebase.json or a row
+// of a CSV file.
 type KnowledgeEntry struct {
 	ID              string   `json:"id"`
 	Module          string   `json:"module"`
@@ -20,56 +27,494 @@ type KnowledgeEntry struct {
 	Roles           []string `json:"roles"`
 	QueryVariations []string `json:"query_variations"`
 	Answer          string   `json:"answer"`
+
+	// Source is the file a chunked document came from, if any.
+	Source string `json:"source,omitempty"`
+
+	// kind is the kind of source the entry was ingested from.
+	kind string
 }
 
+// Kinds of source, stored on every point as source_kind so Prune only
+// touches the kinds a run ingested.
+const (
+	SourceKindJSON     = "json"
+	SourceKindCSV      = "csv"
+	SourceKindMarkdown = "markdown"
+)
+
+// sourceKinds lists every kind of source.
+var sourceKinds = []string{SourceKindJSON, SourceKindCSV, SourceKindMarkdown}
+
 // Service handles document ingestion.
 type Service struct {
-	embedder     *llm.Embedder
-	vectorClient *vector.Client
+	embedder     llm.Embedder
+	vectorClient vector.VectorStore
+
+	batchSize         int
+	upsertConcurrency int
+
+	// Chunking of markdown and plain-text documents, in characters.
+	chunkSize    int
+	chunkOverlap int
+
+	// Roles of markdown and plain-text chunks.
+	documentRoles []string
+
+	// Column mapping of CSV files.
+	csvColumns CSVColumns
+
+	// Skip entries whose text duplicates one already ingested this run.
+	dedup bool
+
+	// Embed entries but never write to or delete from Qdrant.
+	dryRun bool
+
+	// Stores holding modules kept apart from the default store.
+	moduleStores map[string]vector.VectorStore
+
+	// IDs ingested during this run by numeric point ID, used for pruning
+	// stale points and catching hash collisions, the module of each entry
+	// ID, the kinds of source ingested, and content hashes used for
+	// deduplication.
+	mu          sync.Mutex
+	seenIDs     map[uint64]string
+	seenModules map[string]string
+	seenKinds   map[string]bool
+	seenHashes  map[string]string
+}
+
+// Option configures optional Service behaviour.
+type Option func(*Service)
+
+// WithUpsertConcurrency bounds how many batches are upserted to Qdrant at
+// once. Embedding still happens one batch at a time.
+func WithUpsertConcurrency(n int) Option {
+	return func(s *Service) {
+		if n > 0 {
+			s.upsertConcurrency = n
+		}
+	}
+}
+
+// WithDedup controls whether entries with the same generated text as an
+// earlier entry in this run are skipped. It is on by default.
+func WithDedup(enabled bool) Option {
+	return func(s *Service) {
+		s.dedup = enabled
+	}
+}
+
+// WithDryRun makes the service embed entries without upserting them, and
+// makes Prune only count stale points.
+func WithDryRun(enabled bool) Option {
+	return func(s *Service) {
+		s.dryRun = enabled
+	}
+}
+
+// WithModuleStores upserts entries of the given modules into stores of
+// their own, such as one Qdrant collection per module, instead of the
+// default store. Prune then cleans each store separately.
+func WithModuleStores(stores map[string]vector.VectorStore) Option {
+	return func(s *Service) {
+		s.moduleStores = stores
+	}
 }
 
 // NewService creates a new ingestion service.
-func NewService(embedder *llm.Embedder, vectorClient *vector.Client) *Service {
-	return &Service{
-		embedder:     embedder,
-		vectorClient: vectorClient,
+func NewService(embedder llm.Embedder, vectorClient vector.VectorStore, opts ...Option) *Service {
+	s := &Service{
+		embedder:          embedder,
+		vectorClient:      vectorClient,
+		batchSize:         10,
+		upsertConcurrency: 1,
+		chunkSize:         1000,
+		chunkOverlap:      200,
+		documentRoles:     DefaultDocumentRoles,
+		csvColumns:        DefaultCSVColumns,
+		dedup:             true,
+		seenIDs:           make(map[uint64]string),
+		seenModules:       make(map[string]string),
+		seenKinds:         make(map[string]bool),
+		seenHashes:        make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
-// IngestJSONFile parses and ingests a knowledge base JSON file.
-func (s *Service) IngestJSONFile(ctx context.Context, filePath string) error {
+// IngestJSONFile parses and ingests a knowledge base JSON file. It returns
+// the number of entries ingested.
+func (s *Service) IngestJSONFile(ctx context.Context, filePath string) (int, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
-		return fmt.Errorf("read file: %w", err)
+		return 0, fmt.Errorf("read file: %w", err)
 	}
 
-	var entries []KnowledgeEntry
-	if err := json.Unmarshal(data, &entries); err != nil {
-		return fmt.Errorf("unmarshal json: %w", err)
+	entries, err := parseEntries(data)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s: %w", filePath, err)
 	}
 
 	log.Printf("Loaded %d entries from %s", len(entries), filePath)
 
-	// Process in batches
-	batchSize := 10
-	for i := 0; i < len(entries); i += batchSize {
-		end := i + batchSize
+	return s.ingestEntries(ctx, SourceKindJSON, entries)
+}
+
+// parseEntries decodes a knowledge base file, which is either an array of
+// entries or an object wrapping them as {"entries": [...]}.
+func parseEntries(data []byte) ([]KnowledgeEntry, error) {
+	const shapes = `supported shapes are [{...}, ...] and {"entries": [{...}, ...]}`
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("empty file; %s", shapes)
+	}
+
+	var entries []KnowledgeEntry
+	switch trimmed[0] {
+	case '[':
+		if err := json.Unmarshal(trimmed, &entries); err != nil {
+			return nil, fmt.Errorf("unmarshal entry array: %w", err)
+		}
+	case '{':
+		var wrapper struct {
+			Entries *[]KnowledgeEntry `json:"entries"`
+		}
+		if err := json.Unmarshal(trimmed, &wrapper); err != nil {
+			return nil, fmt.Errorf("unmarshal object: %w", err)
+		}
+		if wrapper.Entries == nil {
+			return nil, fmt.Errorf(`object has no "entries" key; %s`, shapes)
+		}
+		entries = *wrapper.Entries
+	default:
+		return nil, fmt.Errorf("top level is neither an array nor an object; %s", shapes)
+	}
+	return entries, nil
+}
+
+// ingestEntries deduplicates entries of the given source kind, records
+// their IDs for pruning and upserts them. It returns the number of entries
+// ingested.
+func (s *Service) ingestEntries(ctx context.Context, kind string, entries []KnowledgeEntry) (int, error) {
+	for i := range entries {
+		entries[i].kind = kind
+	}
+	s.mu.Lock()
+	s.seenKinds[kind] = true
+	s.mu.Unlock()
+
+	if s.dedup {
+		entries = s.dedupe(entries)
+	}
+
+	if err := s.recordIDs(entries); err != nil {
+		return 0, err
+	}
+
+	if s.dryRun {
+		if err := s.checkEntries(ctx, entries); err != nil {
+			return 0, err
+		}
+		return len(entries), nil
+	}
+	for _, group := range s.groupByStore(entries) {
+		if err := s.upsertEntries(ctx, group.store, group.entries); err != nil {
+			return 0, err
+		}
+	}
+	return len(entries), nil
+}
+
+// storeGroup is a run of entries that belong in the same store.
+type storeGroup struct {
+	store   vector.VectorStore
+	entries []KnowledgeEntry
+}
+
+// storeFor returns the store entries of module are kept in.
+func (s *Service) storeFor(module string) vector.VectorStore {
+	if store, ok := s.moduleStores[module]; ok {
+		return store
+	}
+	return s.vectorClient
+}
+
+// groupByStore splits entries by the store they belong in, keeping the
+// order in which stores first appear.
+func (s *Service) groupByStore(entries []KnowledgeEntry) []storeGroup {
+	if len(s.moduleStores) == 0 {
+		return []storeGroup{{store: s.vectorClient, entries: entries}}
+	}
+
+	var groups []storeGroup
+	index := make(map[vector.VectorStore]int)
+	for _, entry := range entries {
+		store := s.storeFor(entry.Module)
+		i, ok := index[store]
+		if !ok {
+			i = len(groups)
+			index[store] = i
+			groups = append(groups, storeGroup{store: store})
+		}
+		groups[i].entries = append(groups[i].entries, entry)
+	}
+	return groups
+}
+
+// checkEntries embeds every batch without upserting, logging each failing
+// batch and carrying on so a dry run reports all problems at once.
+func (s *Service) checkEntries(ctx context.Context, entries []KnowledgeEntry) error {
+	totalBatches := (len(entries) + s.batchSize - 1) / s.batchSize
+	failed := 0
+	for i := 0; i < len(entries); i += s.batchSize {
+		end := min(i+s.batchSize, len(entries))
+		batchNum := i / s.batchSize
+
+		if _, err := s.buildPoints(ctx, entries[i:end]); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("Dry run: batch %d (entries %s..%s) failed: %v", batchNum, entries[i].ID, entries[end-1].ID, err)
+			failed++
+			continue
+		}
+		log.Printf("Dry run: checked batch %d/%d", batchNum+1, totalBatches)
+	}
+	if failed > 0 {
+		return fmt.Errorf("dry run: %d of %d batches failed to embed", failed, totalBatches)
+	}
+	return nil
+}
+
+// upsertEntries embeds entries batch by batch and upserts the batches with
+// bounded concurrency. Upsert order doesn't matter since point IDs are
+// derived from entry IDs.
+func (s *Service) upsertEntries(ctx context.Context, store vector.VectorStore, entries []KnowledgeEntry) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg        sync.WaitGroup
+		errOnce   sync.Once
+		upsertErr error
+	)
+	sem := make(chan struct{}, s.upsertConcurrency)
+	totalBatches := (len(entries) + s.batchSize - 1) / s.batchSize
+	progress := newProgress(len(entries))
+
+	for i := 0; i < len(entries); i += s.batchSize {
+		end := i + s.batchSize
 		if end > len(entries) {
 			end = len(entries)
 		}
+		batchNum := i / s.batchSize
 
-		batch := entries[i:end]
-		if err := s.processBatch(ctx, batch); err != nil {
-			return fmt.Errorf("process batch %d: %w", i/batchSize, err)
+		embedStart := time.Now()
+		points, err := s.buildPoints(ctx, entries[i:end])
+		embedTime := time.Since(embedStart)
+		if err != nil {
+			cancel()
+			wg.Wait()
+			if upsertErr != nil {
+				return upsertErr
+			}
+			return fmt.Errorf("process batch %d: %w", batchNum, err)
 		}
 
-		log.Printf("Processed batch %d/%d", (i/batchSize)+1, (len(entries)+batchSize-1)/batchSize)
+		select {
+		case sem <- struct{}{}: // Acquire
+		case <-ctx.Done():
+			wg.Wait()
+			if upsertErr != nil {
+				return upsertErr
+			}
+			return ctx.Err()
+		}
+
+		wg.Add(1)
+		go func(batchNum int, points []vector.Point, embedTime time.Duration) {
+			defer wg.Done()
+			defer func() { <-sem }() // Release
+
+			upsertStart := time.Now()
+			if err := store.UpsertPoints(ctx, points); err != nil {
+				errOnce.Do(func() {
+					upsertErr = fmt.Errorf("process batch %d: upsert points: %w", batchNum, err)
+					cancel()
+				})
+				return
+			}
+			upsertTime := time.Since(upsertStart)
+			done, rate, eta := progress.add(len(points))
+			log.Printf("Processed batch %d/%d in %v (embed %v, upsert %v): %d/%d points, %.1f embeddings/s, ETA %v",
+				batchNum+1, totalBatches, (embedTime + upsertTime).Round(time.Millisecond),
+				embedTime.Round(time.Millisecond), upsertTime.Round(time.Millisecond),
+				done, len(entries), rate, eta.Round(time.Second))
+		}(batchNum, points, embedTime)
+	}
+
+	wg.Wait()
+	if upsertErr == nil {
+		elapsed := time.Since(progress.start)
+		log.Printf("Upserted %d points in %v (%.1f embeddings/s)",
+			len(entries), elapsed.Round(time.Millisecond), float64(len(entries))/elapsed.Seconds())
+	}
+	return upsertErr
+}
+
+// progress tracks upserted points to report throughput and time remaining.
+type progress struct {
+	start time.Time
+	total int
+	done  atomic.Int64
+}
+
+func newProgress(total int) *progress {
+	return &progress{start: time.Now(), total: total}
+}
+
+// add records n more points and returns the running count, the rate in
+// points per second and the estimated time remaining.
+func (p *progress) add(n int) (int, float64, time.Duration) {
+	done := int(p.done.Add(int64(n)))
+	rate := float64(done) / time.Since(p.start).Seconds()
+	var eta time.Duration
+	if rate > 0 {
+		eta = time.Duration(float64(p.total-done) / rate * float64(time.Second))
 	}
+	return done, rate, eta
+}
+
+// recordIDs records the point IDs of entries, failing if two distinct entry
+// IDs hash to the same point ID, since one would silently overwrite the
+// other in Qdrant.
+func (s *Service) recordIDs(entries []KnowledgeEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
+	var collisions []string
+	for _, entry := range entries {
+		pointID := vector.NumericID(entry.ID)
+		if other, ok := s.seenIDs[pointID]; ok && other != entry.ID {
+			collisions = append(collisions, fmt.Sprintf("%s and %s (point %d)", other, entry.ID, pointID))
+			continue
+		}
+		s.seenIDs[pointID] = entry.ID
+		s.seenModules[entry.ID] = entry.Module
+	}
+	if len(collisions) > 0 {
+		for _, c := range collisions {
+			log.Printf("Point ID collision: %s", c)
+		}
+		return fmt.Errorf("%d entry ID collision(s), rename one entry of each: %s", len(collisions), strings.Join(collisions, "; "))
+	}
 	return nil
 }
 
-func (s *Service) processBatch(ctx context.Context, entries []KnowledgeEntry) error {
+// dedupe drops entries whose normalized text hashes the same as an entry
+// seen earlier in this run.
+func (s *Service) dedupe(entries []KnowledgeEntry) []KnowledgeEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := entries[:0:0]
+	skipped := 0
+	for _, entry := range entries {
+		hash := contentHash(s.entryToText(entry))
+		if firstID, ok := s.seenHashes[hash]; ok && firstID != entry.ID {
+			log.Printf("Skipping entry %s: duplicate of %s", entry.ID, firstID)
+			skipped++
+			continue
+		}
+		s.seenHashes[hash] = entry.ID
+		kept = append(kept, entry)
+	}
+	if skipped > 0 {
+		log.Printf("Skipped %d duplicate entries", skipped)
+	}
+	return kept
+}
+
+// contentHash hashes text after lowercasing and collapsing whitespace, so
+// entries differing only in formatting count as duplicates.
+func contentHash(text string) string {
+	normalized := strings.ToLower(strings.Join(strings.Fields(text), " "))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// Prune deletes every point whose ID wasn't ingested by this Service, so
+// entries removed from the source files disappear from the collection. Only
+// points from the kinds of source ingested this run are considered, so a
+// JSON-only run leaves markdown and CSV points alone; points predating
+// source_kind are only pruned by runs ingesting every kind. With module
+// stores, each store keeps only the entries routed to it, and stores that
+// received no entries this run are left alone. It returns the number of
+// points removed, or that would be removed in a dry run.
+func (s *Service) Prune(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	seen := make([]KnowledgeEntry, 0, len(s.seenIDs))
+	for _, id := range s.seenIDs {
+		seen = append(seen, KnowledgeEntry{ID: id, Module: s.seenModules[id]})
+	}
+	var kinds []string
+	for _, kind := range sourceKinds {
+		if s.seenKinds[kind] {
+			kinds = append(kinds, kind)
+		}
+	}
+	s.mu.Unlock()
+
+	if len(seen) == 0 {
+		return 0, fmt.Errorf("prune: no entries ingested, refusing to delete everything")
+	}
+	if len(kinds) == len(sourceKinds) {
+		kinds = nil
+	}
+
+	total := 0
+	for _, group := range s.groupByStore(seen) {
+		count, err := s.pruneStore(ctx, group.store, group.entries, kinds)
+		if err != nil {
+			return total, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// pruneStore deletes the points in store whose IDs aren't among entries,
+// limited to the given source kinds unless kinds is nil.
+func (s *Service) pruneStore(ctx context.Context, store vector.VectorStore, entries []KnowledgeEntry, kinds []string) (int, error) {
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.ID
+	}
+
+	stale := vector.MustNotFilter(vector.MatchAny("id", ids))
+	if kinds != nil {
+		stale["must"] = []interface{}{vector.MatchAny("source_kind", kinds)}
+	}
+	count, err := store.Count(ctx, stale)
+	if err != nil {
+		return 0, fmt.Errorf("count stale points: %w", err)
+	}
+	if count == 0 || s.dryRun {
+		return count, nil
+	}
+
+	if err := store.DeleteByFilter(ctx, stale); err != nil {
+		return 0, fmt.Errorf("delete stale points: %w", err)
+	}
+	return count, nil
+}
+
+// buildPoints embeds a batch of entries and turns them into vector points.
+func (s *Service) buildPoints(ctx context.Context, entries []KnowledgeEntry) ([]vector.Point, error) {
 	// Generate text for embedding
 	texts := make([]string, len(entries))
 	for i, entry := range entries {
@@ -79,7 +524,12 @@ func (s *Service) processBatch(ctx context.Context, entries []KnowledgeEntry) er
 	// Get embeddings
 	embeddings, err := s.embedder.Embed(ctx, texts)
 	if err != nil {
-		return fmt.Errorf("embed texts: %w", err)
+		return nil, fmt.Errorf("embed texts: %w", err)
+	}
+	for _, embedding := range embeddings {
+		if dim := s.vectorClient.Dimension(); len(embedding) != dim {
+			return nil, fmt.Errorf("embedder produced %d-dimensional vectors but EMBEDDING_DIM is %d", len(embedding), dim)
+		}
 	}
 
 	// Create points
@@ -92,31 +542,39 @@ func (s *Service) processBatch(ctx context.Context, entries []KnowledgeEntry) er
 				"id":               entry.ID,
 				"module":           entry.Module,
 				"topic":            entry.Topic,
-				"roles":            entry.Roles,
+				"roles":            vector.NormalizeRoles(entry.Roles),
 				"query_variations": entry.QueryVariations,
 				"answer":           entry.Answer,
 				"text":             texts[i],
 			},
 		}
+		if entry.Source != "" {
+			points[i].Payload["source"] = entry.Source
+		}
+		if entry.kind != "" {
+			points[i].Payload["source_kind"] = entry.kind
+		}
+		if s.vectorClient.Hybrid() {
+			sparse := vector.EncodeSparse(texts[i])
+			points[i].Sparse = &sparse
+		}
 	}
 
-	// Upsert to Qdrant
-	if err := s.vectorClient.UpsertPoints(ctx, points); err != nil {
-		return fmt.Errorf("upsert points: %w", err)
-	}
-
-	return nil
+	return points, nil
 }
 
 func (s *Service) entryToText(entry KnowledgeEntry) string {
-	var sb strings.Builder
-	sb.WriteString("Module: ")
-	sb.WriteString(entry.Module)
-	sb.WriteString("\nTopic: ")
-	sb.WriteString(entry.Topic)
-	sb.WriteString("\nQuestions: ")
-	sb.WriteString(strings.Join(entry.QueryVariations, "; "))
-	sb.WriteString("\nAnswer: ")
-	sb.WriteString(entry.Answer)
-	return sb.String()
+	// Skip empty sections so dangling labels don't end up in the embedding.
+	var sections []string
+	if entry.Module != "" {
+		sections = append(sections, "Module: "+entry.Module)
+	}
+	if entry.Topic != "" {
+		sections = append(sections, "Topic: "+entry.Topic)
+	}
+	if len(entry.QueryVariations) > 0 {
+		sections = append(sections, "Questions: "+strings.Join(entry.QueryVariations, "; "))
+	}
+	sections = append(sections, "Answer: "+entry.Answer)
+	return strings.Join(sections, "\n")
 }
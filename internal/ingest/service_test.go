@@ -0,0 +1,158 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-bot/internal/vector"
+)
+
+func TestEntryToText(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry KnowledgeEntry
+		want  string
+	}{
+		{
+			name: "all sections",
+			entry: KnowledgeEntry{
+				Module:          "Payroll",
+				Topic:           "Running payroll",
+				QueryVariations: []string{"How do I run payroll?", "Start a pay run"},
+				Answer:          "Open the Payroll dashboard.",
+			},
+			want: "Module: Payroll\nTopic: Running payroll\nQuestions: How do I run payroll?; Start a pay run\nAnswer: Open the Payroll dashboard.",
+		},
+		{
+			name:  "no query variations",
+			entry: KnowledgeEntry{Module: "Payroll", Topic: "Running payroll", Answer: "Open the Payroll dashboard."},
+			want:  "Module: Payroll\nTopic: Running payroll\nAnswer: Open the Payroll dashboard.",
+		},
+		{
+			name:  "empty query variations",
+			entry: KnowledgeEntry{Module: "Payroll", Topic: "Running payroll", QueryVariations: []string{}, Answer: "Open the Payroll dashboard."},
+			want:  "Module: Payroll\nTopic: Running payroll\nAnswer: Open the Payroll dashboard.",
+		},
+		{
+			name:  "no module or topic",
+			entry: KnowledgeEntry{QueryVariations: []string{"How do I run payroll?"}, Answer: "Open the Payroll dashboard."},
+			want:  "Questions: How do I run payroll?\nAnswer: Open the Payroll dashboard.",
+		},
+		{
+			name:  "answer only",
+			entry: KnowledgeEntry{Answer: "Open the Payroll dashboard."},
+			want:  "Answer: Open the Payroll dashboard.",
+		},
+	}
+
+	s := NewService(nil, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := s.entryToText(tt.entry)
+			if got != tt.want {
+				t.Errorf("entryToText = %q, want %q", got, tt.want)
+			}
+			for _, line := range strings.Split(got, "\n") {
+				if strings.HasSuffix(strings.TrimSpace(line), ":") {
+					t.Errorf("empty section %q in %q", line, got)
+				}
+			}
+		})
+	}
+}
+
+// fixedEmbedder embeds every text to the same unit vector.
+type fixedEmbedder struct{}
+
+func (fixedEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i := range texts {
+		out[i] = []float32{1, 0, 0}
+	}
+	return out, nil
+}
+
+func (fixedEmbedder) EmbedSingle(ctx context.Context, text string) ([]float32, error) {
+	return []float32{1, 0, 0}, nil
+}
+
+func (fixedEmbedder) Ping(ctx context.Context) error { return nil }
+
+// slowStore is a MemoryStore whose upserts take a while, recording the most
+// that ran at once.
+type slowStore struct {
+	*vector.MemoryStore
+	active  atomic.Int32
+	maxSeen atomic.Int32
+}
+
+func (s *slowStore) UpsertPoints(ctx context.Context, points []vector.Point) error {
+	n := s.active.Add(1)
+	defer s.active.Add(-1)
+	for {
+		seen := s.maxSeen.Load()
+		if n <= seen || s.maxSeen.CompareAndSwap(seen, n) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	return s.MemoryStore.UpsertPoints(ctx, points)
+}
+
+func TestUpsertConcurrency(t *testing.T) {
+	const entries = 95 // ten batches, the last one partial
+
+	var kb []KnowledgeEntry
+	for i := 0; i < entries; i++ {
+		kb = append(kb, KnowledgeEntry{
+			ID:     fmt.Sprintf("entry-%d", i),
+			Module: "Payroll",
+			Answer: fmt.Sprintf("Answer %d.", i),
+		})
+	}
+	data, err := json.Marshal(kb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "kb.json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		concurrency int
+		wantMax     int32
+	}{
+		{name: "sequential", concurrency: 1, wantMax: 1},
+		{name: "bounded", concurrency: 3, wantMax: 3},
+		{name: "more workers than batches", concurrency: 20, wantMax: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &slowStore{MemoryStore: vector.NewMemoryStore(3)}
+			s := NewService(fixedEmbedder{}, store, WithUpsertConcurrency(tt.concurrency))
+
+			n, err := s.IngestJSONFile(context.Background(), path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != entries {
+				t.Errorf("ingested %d entries, want %d", n, entries)
+			}
+			if got, err := store.Count(context.Background(), nil); err != nil || got != entries {
+				t.Errorf("store holds %d points (%v), want %d", got, err, entries)
+			}
+			if got := store.maxSeen.Load(); got > tt.wantMax {
+				t.Errorf("%d concurrent upserts, want at most %d", got, tt.wantMax)
+			}
+		})
+	}
+}
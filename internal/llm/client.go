@@ -2,23 +2,55 @@ package llm
 
 import (
 	"bufio"
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
-const groqAPIURL = "https://api.groq.com/openai/v1/chat/completions"
+// DefaultBaseURL is Groq's OpenAI-compatible API root.
+const DefaultBaseURL = "https://api.groq.com/openai/v1"
+
+// ErrIncompleteStream is returned when a stream ends without the [DONE]
+// marker, meaning the answer may have been cut off.
+var ErrIncompleteStream = errors.New("incomplete stream: [DONE] not received")
+
+// ErrStreamIdle is returned when a stream produces no data within the idle
+// timeout.
+var ErrStreamIdle = errors.New("stream stalled: no data within idle timeout")
+
+// ChatCompleter generates chat completions. Client implements it against
+// Groq; FakeCompleter returns canned answers for tests.
+type ChatCompleter interface {
+	// CreateChatCompletion returns a complete answer.
+	CreateChatCompletion(ctx context.Context, messages []Message, maxTokens int, opts CompletionOptions) (*ChatResponse, error)
+	// StreamChatCompletion writes the answer to writer as it is generated.
+	StreamChatCompletion(ctx context.Context, messages []Message, maxTokens int, opts CompletionOptions, writer io.Writer) (*StreamResult, error)
+}
+
+var _ ChatCompleter = (*Client)(nil)
 
 // Client is a Groq LLM client.
 type Client struct {
-	apiKey     string
-	httpClient *http.Client
-	model      string
+	apiKey      string
+	httpClient  *http.Client
+	endpoint    string
+	model       string
+	temperature float64
+
+	maxAttempts int
+	baseDelay   time.Duration
+
+	// requestTimeout caps non-streaming calls; streamIdleTimeout cancels a
+	// stream only when it stops producing data.
+	requestTimeout    time.Duration
+	streamIdleTimeout time.Duration
 }
 
 // Message represents a chat message.
@@ -32,17 +64,48 @@ type ChatRequest struct {
 	Model       string    `json:"model"`
 	Messages    []Message `json:"messages"`
 	MaxTokens   int       `json:"max_tokens,omitempty"`
-	Temperature float64   `json:"temperature,omitempty"`
+	Temperature float64   `json:"temperature"`
 	Stream      bool      `json:"stream"`
+	Stop        []string  `json:"stop,omitempty"`
+	Seed        *int      `json:"seed,omitempty"`
+
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+}
+
+// CompletionOptions are per-call settings. The zero value uses the client's
+// defaults.
+type CompletionOptions struct {
+	// Stop ends the answer at any of these sequences.
+	Stop []string
+	// Seed makes sampling reproducible, together with a zero Temperature.
+	Seed *int
+	// Temperature overrides the client's sampling temperature.
+	Temperature *float64
+}
+
+// StreamOptions controls extra data sent on streaming responses.
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// Usage reports token counts for a completion.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
 }
 
 // ChatResponse is the response payload from chat completions.
 type ChatResponse struct {
-	ID      string `json:"id"`
-	Choices []struct {
-		Message      Message `json:"message"`
-		FinishReason string  `json:"finish_reason"`
-	} `json:"choices"`
+	ID      string   `json:"id"`
+	Choices []Choice `json:"choices"`
+	Usage   Usage    `json:"usage"`
+}
+
+// Choice is one completion in a ChatResponse.
+type Choice struct {
+	Message      Message `json:"message"`
+	FinishReason string  `json:"finish_reason"`
 }
 
 // StreamDelta represents a streaming chunk.
@@ -54,27 +117,107 @@ type StreamDelta struct {
 		} `json:"delta"`
 		FinishReason string `json:"finish_reason"`
 	} `json:"choices"`
+	// Usage is sent on the final chunk when include_usage is set. Groq
+	// reports it under x_groq instead.
+	Usage *Usage `json:"usage"`
+	XGroq *struct {
+		Usage *Usage `json:"usage"`
+	} `json:"x_groq"`
+}
+
+// FinishReasonLength is the finish reason for output cut off at max_tokens.
+const FinishReasonLength = "length"
+
+// StreamResult summarises a completed stream.
+type StreamResult struct {
+	Usage        *Usage
+	FinishReason string
 }
 
+// DefaultModel is the Groq model used when none is configured.
+const DefaultModel = "meta-llama/llama-4-maverick-17b-128e-instruct"
+
+// DefaultTemperature is the sampling temperature used when none is configured.
+const DefaultTemperature = 0.7
+
 // NewClient creates a new Groq client.
-func NewClient(apiKey string) *Client {
-	return &Client{
+func NewClient(apiKey, model string, temperature float64, opts ...ClientOption) *Client {
+	if model == "" {
+		model = DefaultModel
+	}
+	c := &Client{
 		apiKey: apiKey,
-		httpClient: &http.Client{
-			Timeout: 60 * time.Second,
-		},
-		model: "meta-llama/llama-4-maverick-17b-128e-instruct",
+		// Deadlines are applied per call so streams aren't cut off by a
+		// total timeout.
+		httpClient:        &http.Client{},
+		endpoint:          chatEndpoint(DefaultBaseURL),
+		model:             model,
+		temperature:       temperature,
+		maxAttempts:       3,
+		baseDelay:         500 * time.Millisecond,
+		requestTimeout:    60 * time.Second,
+		streamIdleTimeout: 30 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
+}
+
+// ValidateBaseURL checks that raw is an absolute http or https URL.
+func ValidateBaseURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid base URL %q: %w", raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid base URL %q: scheme must be http or https", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid base URL %q: missing host", raw)
+	}
+	return nil
+}
+
+// temperatureFor resolves the sampling temperature of a call.
+func (c *Client) temperatureFor(opts CompletionOptions) float64 {
+	if opts.Temperature != nil {
+		return *opts.Temperature
+	}
+	return c.temperature
+}
+
+// MaxStopSequences is the most stop sequences Groq accepts per request.
+const MaxStopSequences = 4
+
+// ValidateStop checks stop sequences against Groq's limits.
+func ValidateStop(stop []string) error {
+	if len(stop) > MaxStopSequences {
+		return fmt.Errorf("at most %d stop sequences are allowed, got %d", MaxStopSequences, len(stop))
+	}
+	for _, s := range stop {
+		if s == "" {
+			return fmt.Errorf("stop sequences must not be empty")
+		}
+	}
+	return nil
+}
+
+// chatEndpoint returns the chat completions URL under baseURL.
+func chatEndpoint(baseURL string) string {
+	return strings.TrimRight(baseURL, "/") + "/chat/completions"
 }
 
 // CreateChatCompletion sends a non-streaming chat request.
-func (c *Client) CreateChatCompletion(ctx context.Context, messages []Message, maxTokens int) (*ChatResponse, error) {
+func (c *Client) CreateChatCompletion(ctx context.Context, messages []Message, maxTokens int, opts CompletionOptions) (*ChatResponse, error) {
 	reqBody := ChatRequest{
 		Model:       c.model,
 		Messages:    messages,
 		MaxTokens:   maxTokens,
-		Temperature: 0.7,
+		Temperature: c.temperatureFor(opts),
 		Stream:      false,
+		Stop:        opts.Stop,
+		Seed:        opts.Seed,
 	}
 
 	body, err := json.Marshal(reqBody)
@@ -82,24 +225,15 @@ func (c *Client) CreateChatCompletion(ctx context.Context, messages []Message, m
 		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, groqAPIURL, bytes.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	ctx, cancel := context.WithTimeout(ctx, c.requestTimeout)
+	defer cancel()
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.post(ctx, body)
 	if err != nil {
-		return nil, fmt.Errorf("do request: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("groq error: status %d, body: %s", resp.StatusCode, string(respBody))
-	}
-
 	var chatResp ChatResponse
 	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
 		return nil, fmt.Errorf("decode response: %w", err)
@@ -109,46 +243,53 @@ func (c *Client) CreateChatCompletion(ctx context.Context, messages []Message, m
 }
 
 // StreamChatCompletion sends a streaming chat request and streams content to the provided writer.
-func (c *Client) StreamChatCompletion(ctx context.Context, messages []Message, maxTokens int, writer io.Writer) error {
+func (c *Client) StreamChatCompletion(ctx context.Context, messages []Message, maxTokens int, opts CompletionOptions, writer io.Writer) (*StreamResult, error) {
 	reqBody := ChatRequest{
-		Model:       c.model,
-		Messages:    messages,
-		MaxTokens:   maxTokens,
-		Temperature: 0.7,
-		Stream:      true,
+		Model:         c.model,
+		Messages:      messages,
+		MaxTokens:     maxTokens,
+		Temperature:   c.temperatureFor(opts),
+		Stream:        true,
+		Stop:          opts.Stop,
+		Seed:          opts.Seed,
+		StreamOptions: &StreamOptions{IncludeUsage: true},
 	}
 
 	body, err := json.Marshal(reqBody)
 	if err != nil {
-		return fmt.Errorf("marshal request: %w", err)
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, groqAPIURL, bytes.NewReader(body))
-	if err != nil {
-		return fmt.Errorf("create request: %w", err)
+		return nil, fmt.Errorf("marshal request: %w", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
 
-	resp, err := c.httpClient.Do(req)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	resp, err := c.post(ctx, body)
 	if err != nil {
-		return fmt.Errorf("do request: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("groq error: status %d, body: %s", resp.StatusCode, string(respBody))
-	}
+	// Cancel only if the stream stalls once it has started, so retries and
+	// backoff inside post don't count; each received line resets the
+	// timer.
+	var stalled atomic.Bool
+	idle := time.AfterFunc(c.streamIdleTimeout, func() {
+		stalled.Store(true)
+		cancel()
+	})
+	defer idle.Stop()
 
+	result := &StreamResult{}
+	done := false
 	scanner := bufio.NewScanner(resp.Body)
 	for scanner.Scan() {
+		idle.Reset(c.streamIdleTimeout)
 		line := scanner.Text()
 		if !strings.HasPrefix(line, "data: ") {
 			continue
 		}
 		data := strings.TrimPrefix(line, "data: ")
 		if data == "[DONE]" {
+			done = true
 			break
 		}
 
@@ -157,14 +298,32 @@ func (c *Client) StreamChatCompletion(ctx context.Context, messages []Message, m
 			continue
 		}
 
+		if delta.Usage != nil {
+			result.Usage = delta.Usage
+		} else if delta.XGroq != nil && delta.XGroq.Usage != nil {
+			result.Usage = delta.XGroq.Usage
+		}
+
 		for _, choice := range delta.Choices {
+			if choice.FinishReason != "" {
+				result.FinishReason = choice.FinishReason
+			}
 			if choice.Delta.Content != "" {
 				if _, err := writer.Write([]byte(choice.Delta.Content)); err != nil {
-					return fmt.Errorf("write stream: %w", err)
+					return result, fmt.Errorf("write stream: %w", err)
 				}
 			}
 		}
 	}
 
-	return scanner.Err()
+	if err := scanner.Err(); err != nil {
+		if stalled.Load() {
+			return result, ErrStreamIdle
+		}
+		return result, fmt.Errorf("read stream: %w", err)
+	}
+	if !done {
+		return result, ErrIncompleteStream
+	}
+	return result, nil
 }
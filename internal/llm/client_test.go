@@ -0,0 +1,129 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// streamServer replies to chat requests with the given SSE lines.
+func streamServer(t *testing.T, lines ...string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, line := range lines {
+			fmt.Fprintf(w, "%s\n", line)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func delta(content string) string {
+	return fmt.Sprintf(`data: {"choices":[{"delta":{"content":%q}}]}`, content)
+}
+
+func TestStreamChatCompletionIncomplete(t *testing.T) {
+	tests := []struct {
+		name       string
+		lines      []string
+		wantAnswer string
+		wantErr    error
+	}{
+		{
+			name:       "complete",
+			lines:      []string{delta("Run "), delta("payroll."), "", "data: [DONE]"},
+			wantAnswer: "Run payroll.",
+		},
+		{
+			name:       "ends without done",
+			lines:      []string{delta("Run "), delta("payroll.")},
+			wantAnswer: "Run payroll.",
+			wantErr:    ErrIncompleteStream,
+		},
+		{
+			name:       "cut mid-line",
+			lines:      []string{delta("Run "), `data: {"choices":[{"delta":{"cont`},
+			wantAnswer: "Run ",
+			wantErr:    ErrIncompleteStream,
+		},
+		{
+			name:    "empty body",
+			wantErr: ErrIncompleteStream,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := streamServer(t, tt.lines...)
+			client := NewClient("key", "", 0, WithBaseURL(srv.URL))
+
+			var sb strings.Builder
+			_, err := client.StreamChatCompletion(context.Background(), []Message{{Role: "user", Content: "payroll?"}}, 100, CompletionOptions{}, &sb)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			if sb.String() != tt.wantAnswer {
+				t.Errorf("answer = %q, want %q", sb.String(), tt.wantAnswer)
+			}
+		})
+	}
+}
+
+func TestStreamIdleTimeout(t *testing.T) {
+	const idle = 100 * time.Millisecond
+
+	tests := []struct {
+		name    string
+		gaps    []time.Duration // pause before each delta
+		wantErr error
+	}{
+		{
+			name: "steady stream longer than the idle timeout",
+			gaps: []time.Duration{40 * time.Millisecond, 40 * time.Millisecond, 40 * time.Millisecond, 40 * time.Millisecond, 40 * time.Millisecond},
+		},
+		{
+			name:    "stalls after the first delta",
+			gaps:    []time.Duration{0, time.Second},
+			wantErr: ErrStreamIdle,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				for i, gap := range tt.gaps {
+					select {
+					case <-time.After(gap):
+					case <-r.Context().Done():
+						return
+					}
+					fmt.Fprintf(w, "%s\n\n", delta(fmt.Sprintf("word%d ", i)))
+					w.(http.Flusher).Flush()
+				}
+				fmt.Fprint(w, "data: [DONE]\n\n")
+			}))
+			defer srv.Close()
+			client := NewClient("key", "", 0, WithBaseURL(srv.URL), WithTimeouts(time.Minute, idle))
+
+			start := time.Now()
+			var sb strings.Builder
+			_, err := client.StreamChatCompletion(context.Background(), []Message{{Role: "user", Content: "payroll?"}}, 100, CompletionOptions{}, &sb)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && sb.String() != "word0 word1 word2 word3 word4 " {
+				t.Errorf("answer = %q, want every delta", sb.String())
+			}
+			if tt.wantErr != nil && time.Since(start) > 500*time.Millisecond {
+				t.Errorf("stalled stream took %v to time out", time.Since(start))
+			}
+		})
+	}
+}
@@ -1,117 +1,266 @@
 package llm
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
-// Using Ollama local embeddings
-const ollamaEmbeddingURL = "http://localhost:11434/api/embeddings"
+// maxEmbedTokens is the approximate token budget for a single embedding input.
+const maxEmbedTokens = 2048
 
-// Embedder generates embeddings using Ollama locally.
-type Embedder struct {
-	httpClient *http.Client
-	model      string
-}
+// embedCharsPerToken approximates characters per token for budgeting.
+const embedCharsPerToken = 4
 
-// OllamaRequest is the request format for Ollama embeddings.
-type OllamaRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
-}
+// maxEmbedRetryDelay caps the backoff between embedding retries.
+const maxEmbedRetryDelay = 10 * time.Second
+
+// Embedding providers accepted by NewEmbedder.
+const (
+	ProviderOllama = "ollama"
+	ProviderOpenAI = "openai"
+)
 
-// OllamaResponse is the response format from Ollama embeddings.
-type OllamaResponse struct {
-	Embedding []float64 `json:"embedding"`
+// Embedder turns text into embedding vectors.
+type Embedder interface {
+	// Embed embeds texts, returning vectors in input order.
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	// EmbedSingle embeds one text.
+	EmbedSingle(ctx context.Context, text string) ([]float32, error)
+	// Ping checks that the embedding backend is reachable.
+	Ping(ctx context.Context) error
 }
 
-// NewEmbedder creates a new embedder using Ollama.
-func NewEmbedder(_ string) *Embedder {
-	return &Embedder{
-		httpClient: &http.Client{
-			Timeout: 120 * time.Second,
-		},
-		model: "nomic-embed-text:latest",
-	}
+// embedderSettings holds the options shared by every Embedder.
+type embedderSettings struct {
+	httpClient  *http.Client
+	concurrency int
+
+	maxAttempts   int
+	retryDelay    time.Duration
+	warmupTimeout time.Duration
 }
 
-// Embed generates embeddings for the given texts.
-func (e *Embedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
-	embeddings := make([][]float32, len(texts))
+// EmbedderOption configures optional Embedder behaviour.
+type EmbedderOption func(*embedderSettings)
 
-	for i, text := range texts {
-		emb, err := e.embedSingle(ctx, text)
-		if err != nil {
-			return nil, fmt.Errorf("embed text %d: %w", i, err)
+// WithEmbedConcurrency bounds how many texts Embed sends at once.
+func WithEmbedConcurrency(n int) EmbedderOption {
+	return func(s *embedderSettings) {
+		if n > 0 {
+			s.concurrency = n
 		}
-		embeddings[i] = emb
+	}
+}
 
-		if (i+1)%10 == 0 {
-			log.Printf("Embedded %d/%d texts", i+1, len(texts))
+// WithEmbedTimeout sets the HTTP timeout for each embedding request.
+func WithEmbedTimeout(d time.Duration) EmbedderOption {
+	return func(s *embedderSettings) {
+		if d > 0 {
+			s.httpClient.Timeout = d
 		}
 	}
+}
 
-	return embeddings, nil
+// WithEmbedTransport sends embedding requests through t, so the embedder
+// can share a connection pool with the other clients.
+func WithEmbedTransport(t http.RoundTripper) EmbedderOption {
+	return func(s *embedderSettings) {
+		if t != nil {
+			s.httpClient.Transport = t
+		}
+	}
 }
 
-func (e *Embedder) embedSingle(ctx context.Context, text string) ([]float32, error) {
-	// Truncate if too long
-	if len(text) > 8000 {
-		text = text[:8000]
+// WithEmbedRetry sets how many attempts the Ollama embedder makes on
+// connection errors and 5xx responses, and the base delay for exponential
+// backoff between them.
+func WithEmbedRetry(maxAttempts int, baseDelay time.Duration) EmbedderOption {
+	return func(s *embedderSettings) {
+		if maxAttempts > 0 {
+			s.maxAttempts = maxAttempts
+		}
+		if baseDelay > 0 {
+			s.retryDelay = baseDelay
+		}
 	}
+}
 
-	reqBody := OllamaRequest{
-		Model:  e.model,
-		Prompt: text,
+// WithEmbedWarmup makes the Ollama embedder send one warm-up request with
+// the given timeout before its first embedding, so a cold model load
+// doesn't count against the regular timeout, and bounds explicit warm-ups
+// with it. Zero disables the warm-up before the first embedding.
+func WithEmbedWarmup(timeout time.Duration) EmbedderOption {
+	return func(s *embedderSettings) {
+		s.warmupTimeout = timeout
 	}
+}
 
-	body, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("marshal request: %w", err)
+func newEmbedderSettings(opts []EmbedderOption) embedderSettings {
+	s := embedderSettings{
+		httpClient: &http.Client{
+			Timeout: 120 * time.Second,
+		},
+		concurrency: 4,
+		maxAttempts: 3,
+		retryDelay:  500 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(&s)
 	}
+	return s
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ollamaEmbeddingURL, bytes.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
+// NewEmbedder creates the Embedder for provider. Empty baseURL and model
+// select the provider's defaults; apiKey is only used by OpenAI-compatible
+// providers.
+func NewEmbedder(provider, baseURL, apiKey, model string, opts ...EmbedderOption) (Embedder, error) {
+	switch provider {
+	case ProviderOllama, "":
+		return NewOllamaEmbedder(baseURL, model, opts...), nil
+	case ProviderOpenAI:
+		return NewOpenAIEmbedder(baseURL, apiKey, model, opts...), nil
+	default:
+		return nil, fmt.Errorf("unknown embedding provider %q", provider)
 	}
-	req.Header.Set("Content-Type", "application/json")
+}
+
+// warmer is implemented by embedders with their own warm-up path, such as
+// OllamaEmbedder.
+type warmer interface {
+	WarmUp(ctx context.Context) error
+}
 
-	resp, err := e.httpClient.Do(req)
+// Warmup makes the backend load its model before real traffic arrives,
+// using the embedder's own warm-up when it has one and a trivial embedding
+// request otherwise, and returns how long it took.
+func Warmup(ctx context.Context, e Embedder) (time.Duration, error) {
+	start := time.Now()
+	var err error
+	if w, ok := e.(warmer); ok {
+		err = w.WarmUp(ctx)
+	} else {
+		_, err = e.EmbedSingle(ctx, "warm-up")
+	}
 	if err != nil {
-		return nil, fmt.Errorf("do request: %w", err)
+		return time.Since(start), fmt.Errorf("warm-up: %w", err)
 	}
-	defer resp.Body.Close()
+	return time.Since(start), nil
+}
 
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("read response: %w", err)
+// retry calls embed until it succeeds, fails with a non-retryable error or
+// runs out of attempts, backing off exponentially between attempts.
+func (s *embedderSettings) retry(ctx context.Context, name string,
+	embed func() (embedding []float32, retryable bool, err error)) ([]float32, error) {
+	delay := s.retryDelay
+	for attempt := 1; ; attempt++ {
+		embedding, retryable, err := embed()
+		if err == nil || !retryable || attempt >= s.maxAttempts {
+			return embedding, err
+		}
+
+		log.Printf("%s embedding failed (attempt %d/%d), retrying in %v: %v", name, attempt, s.maxAttempts, delay, err)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w (gave up retrying: %v)", err, ctx.Err())
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxEmbedRetryDelay)
 	}
+}
+
+// embedConcurrently embeds texts with embed using a bounded worker pool.
+// Results keep the input order; the first error cancels remaining work.
+func embedConcurrently(ctx context.Context, texts []string, concurrency int,
+	embed func(context.Context, string) ([]float32, error)) ([][]float32, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("ollama error: status %d, body: %s", resp.StatusCode, string(respBody))
+	embeddings := make([][]float32, len(texts))
+	jobs := make(chan int)
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		done     atomic.Int64
+	)
+
+	workers := min(concurrency, len(texts))
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				emb, err := embed(ctx, texts[i])
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = fmt.Errorf("embed text %d: %w", i, err)
+						cancel()
+					})
+					continue
+				}
+				embeddings[i] = emb
+
+				if n := done.Add(1); n%10 == 0 {
+					log.Printf("Embedded %d/%d texts", n, len(texts))
+				}
+			}
+		}()
 	}
 
-	var ollamaResp OllamaResponse
-	if err := json.Unmarshal(respBody, &ollamaResp); err != nil {
-		return nil, fmt.Errorf("decode response: %w", err)
+feed:
+	for i := range texts {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
 	}
+	close(jobs)
+	wg.Wait()
 
-	if len(ollamaResp.Embedding) == 0 {
-		return nil, fmt.Errorf("empty embedding returned")
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
+	return embeddings, nil
+}
 
-	return float64ToFloat32(ollamaResp.Embedding), nil
+// prepareEmbedInput truncates text to the embedding token budget.
+func prepareEmbedInput(text string) string {
+	if truncated, ok := truncateToTokens(text, maxEmbedTokens); ok {
+		log.Printf("Truncated embedding input from %d to %d characters", utf8.RuneCountInString(text), utf8.RuneCountInString(truncated))
+		return truncated
+	}
+	return text
 }
 
-// EmbedSingle generates an embedding for a single text.
-func (e *Embedder) EmbedSingle(ctx context.Context, text string) ([]float32, error) {
-	return e.embedSingle(ctx, text)
+// truncateToTokens cuts text to roughly maxTokens tokens without splitting a
+// UTF-8 character, preferring to end on whitespace. It reports whether the
+// text was truncated.
+func truncateToTokens(text string, maxTokens int) (string, bool) {
+	maxRunes := maxTokens * embedCharsPerToken
+	if utf8.RuneCountInString(text) <= maxRunes {
+		return text, false
+	}
+
+	runes := []rune(text)[:maxRunes]
+	for i := len(runes) - 1; i > maxRunes*3/4; i-- {
+		if unicode.IsSpace(runes[i]) {
+			runes = runes[:i]
+			break
+		}
+	}
+	return string(runes), true
 }
 
 func float64ToFloat32(in []float64) []float32 {
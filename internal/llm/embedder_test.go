@@ -0,0 +1,53 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateToTokens(t *testing.T) {
+	// Four tokens are 16 characters
+	const maxTokens = 4
+
+	tests := []struct {
+		name          string
+		text          string
+		want          string
+		wantTruncated bool
+	}{
+		{name: "fits", text: "Run payroll.", want: "Run payroll."},
+		{name: "exactly at the limit", text: strings.Repeat("界", 16), want: strings.Repeat("界", 16)},
+		{name: "CJK over the limit", text: strings.Repeat("界", 17), want: strings.Repeat("界", 16), wantTruncated: true},
+		{name: "emoji over the limit", text: strings.Repeat("😀", 20), want: strings.Repeat("😀", 16), wantTruncated: true},
+		{name: "emoji straddling the boundary", text: strings.Repeat("a", 15) + "👩‍💻" + "b", want: strings.Repeat("a", 15) + "👩", wantTruncated: true},
+		{name: "mixed at the boundary", text: "payroll給与😀😀😀😀😀😀😀😀😀", want: "payroll給与😀😀😀😀😀😀😀", wantTruncated: true},
+		{name: "prefers whitespace", text: strings.Repeat("界", 13) + " 😀😀😀😀", want: strings.Repeat("界", 13), wantTruncated: true},
+		{name: "ignores whitespace too far back", text: "界 " + strings.Repeat("😀", 20), want: "界 " + strings.Repeat("😀", 14), wantTruncated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated := truncateToTokens(tt.text, maxTokens)
+			if !utf8.ValidString(got) {
+				t.Fatalf("truncated to invalid UTF-8 %q", got)
+			}
+			if got != tt.want || truncated != tt.wantTruncated {
+				t.Errorf("truncateToTokens = %q, %v; want %q, %v", got, truncated, tt.want, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestPrepareEmbedInput(t *testing.T) {
+	text := strings.Repeat("給与計算😀 ", maxEmbedTokens)
+	got := prepareEmbedInput(text)
+	if !utf8.ValidString(got) {
+		t.Fatal("embedding input is invalid UTF-8")
+	}
+	if n := utf8.RuneCountInString(got); n > maxEmbedTokens*embedCharsPerToken || n == 0 {
+		t.Errorf("embedding input is %d characters, want at most %d", n, maxEmbedTokens*embedCharsPerToken)
+	}
+	if !strings.HasPrefix(text, got) {
+		t.Error("embedding input isn't a prefix of the text")
+	}
+}
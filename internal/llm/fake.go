@@ -0,0 +1,104 @@
+package llm
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+)
+
+// FakeCompleter is a ChatCompleter that returns canned answers and records
+// the messages it receives, for testing code that builds prompts.
+type FakeCompleter struct {
+	// Answers are returned in order; the last one repeats once they run
+	// out. With no answers, the reply is empty.
+	Answers []string
+	// FinishReason is reported with every answer; empty means "stop".
+	FinishReason string
+	// Err, when set, is returned instead of an answer.
+	Err error
+
+	mu    sync.Mutex
+	calls [][]Message
+}
+
+// CreateChatCompletion returns the next canned answer, cut at the first stop
+// sequence.
+func (f *FakeCompleter) CreateChatCompletion(ctx context.Context, messages []Message, maxTokens int, opts CompletionOptions) (*ChatResponse, error) {
+	answer, err := f.next(messages, opts.Stop)
+	if err != nil {
+		return nil, err
+	}
+	return &ChatResponse{
+		ID: "fake",
+		Choices: []Choice{{
+			Message:      Message{Role: "assistant", Content: answer},
+			FinishReason: f.finishReason(),
+		}},
+		Usage: fakeUsage(messages, answer),
+	}, nil
+}
+
+// StreamChatCompletion writes the next canned answer word by word, cut at
+// the first stop sequence.
+func (f *FakeCompleter) StreamChatCompletion(ctx context.Context, messages []Message, maxTokens int, opts CompletionOptions, writer io.Writer) (*StreamResult, error) {
+	answer, err := f.next(messages, opts.Stop)
+	if err != nil {
+		return nil, err
+	}
+	for _, chunk := range strings.SplitAfter(answer, " ") {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(writer, chunk); err != nil {
+			return nil, err
+		}
+	}
+	usage := fakeUsage(messages, answer)
+	return &StreamResult{Usage: &usage, FinishReason: f.finishReason()}, nil
+}
+
+// Calls returns the messages of every request received so far.
+func (f *FakeCompleter) Calls() [][]Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	calls := make([][]Message, len(f.calls))
+	copy(calls, f.calls)
+	return calls
+}
+
+func (f *FakeCompleter) next(messages []Message, stop []string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, append([]Message(nil), messages...))
+	if f.Err != nil {
+		return "", f.Err
+	}
+	if len(f.Answers) == 0 {
+		return "", nil
+	}
+	answer := f.Answers[min(len(f.calls), len(f.Answers))-1]
+	for _, s := range stop {
+		if i := strings.Index(answer, s); i >= 0 {
+			answer = answer[:i]
+		}
+	}
+	return answer, nil
+}
+
+func (f *FakeCompleter) finishReason() string {
+	if f.FinishReason == "" {
+		return "stop"
+	}
+	return f.FinishReason
+}
+
+// fakeUsage approximates token counts at four characters per token.
+func fakeUsage(messages []Message, answer string) Usage {
+	prompt := 0
+	for _, m := range messages {
+		prompt += len(m.Content) / 4
+	}
+	completion := len(answer) / 4
+	return Usage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion}
+}
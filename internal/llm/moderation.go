@@ -0,0 +1,88 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ModerationClient calls an OpenAI-compatible moderation endpoint.
+type ModerationClient struct {
+	url        string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// ModerationResponse is the response payload from a moderation endpoint.
+type ModerationResponse struct {
+	Results []struct {
+		Flagged    bool            `json:"flagged"`
+		Categories map[string]bool `json:"categories"`
+	} `json:"results"`
+}
+
+// NewModerationClient creates a new moderation client.
+func NewModerationClient(url, apiKey string) *ModerationClient {
+	return &ModerationClient{
+		url:    url,
+		apiKey: apiKey,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// Moderate reports whether text is allowed and, if not, which categories
+// flagged it.
+func (m *ModerationClient) Moderate(ctx context.Context, text string) (bool, string, error) {
+	body, err := json.Marshal(map[string]string{"input": text})
+	if err != nil {
+		return false, "", fmt.Errorf("marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.url, bytes.NewReader(body))
+	if err != nil {
+		return false, "", fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if m.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+m.apiKey)
+	}
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return false, "", fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return false, "", fmt.Errorf("moderation error: status %d, body: %s", resp.StatusCode, string(respBody))
+	}
+
+	var modResp ModerationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&modResp); err != nil {
+		return false, "", fmt.Errorf("decode response: %w", err)
+	}
+
+	for _, r := range modResp.Results {
+		if !r.Flagged {
+			continue
+		}
+		var categories []string
+		for name, flagged := range r.Categories {
+			if flagged {
+				categories = append(categories, name)
+			}
+		}
+		sort.Strings(categories)
+		return false, strings.Join(categories, ", "), nil
+	}
+	return true, "", nil
+}
@@ -0,0 +1,60 @@
+package llm
+
+import (
+	"context"
+	"math"
+)
+
+// normalizingEmbedder L2-normalizes every vector from the wrapped Embedder.
+type normalizingEmbedder struct {
+	Embedder
+}
+
+// NewNormalizingEmbedder wraps e so every embedding it returns has unit length.
+// Use the same setting for ingestion and querying so scores stay comparable.
+func NewNormalizingEmbedder(e Embedder) Embedder {
+	return normalizingEmbedder{Embedder: e}
+}
+
+// Embed embeds and normalizes texts.
+func (n normalizingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings, err := n.Embedder.Embed(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range embeddings {
+		Normalize(v)
+	}
+	return embeddings, nil
+}
+
+// EmbedSingle embeds and normalizes text.
+func (n normalizingEmbedder) EmbedSingle(ctx context.Context, text string) ([]float32, error) {
+	v, err := n.Embedder.EmbedSingle(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	Normalize(v)
+	return v, nil
+}
+
+// WarmUp warms up the wrapped embedder.
+func (n normalizingEmbedder) WarmUp(ctx context.Context) error {
+	_, err := Warmup(ctx, n.Embedder)
+	return err
+}
+
+// Normalize scales v in place to unit L2 norm. Zero vectors are left as is.
+func Normalize(v []float32) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return
+	}
+	inv := 1 / math.Sqrt(sum)
+	for i := range v {
+		v[i] = float32(float64(v[i]) * inv)
+	}
+}
@@ -0,0 +1,94 @@
+package llm
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+// stubEmbedder returns copies of fixed vectors.
+type stubEmbedder struct {
+	vectors [][]float32
+}
+
+func (s stubEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i := range texts {
+		out[i] = append([]float32(nil), s.vectors[i%len(s.vectors)]...)
+	}
+	return out, nil
+}
+
+func (s stubEmbedder) EmbedSingle(ctx context.Context, text string) ([]float32, error) {
+	return append([]float32(nil), s.vectors[0]...), nil
+}
+
+func (s stubEmbedder) Ping(ctx context.Context) error { return nil }
+
+func norm(v []float32) float64 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	return math.Sqrt(sum)
+}
+
+func TestNormalizeUnitLength(t *testing.T) {
+	tests := map[string][]float32{
+		"3-4":      {3, 4},
+		"negative": {-1, 2, -2},
+		"small":    {1e-20, 1e-20},
+		"large":    {1e19, -1e19, 1e19},
+		"unit":     {0, 1, 0},
+	}
+	for name, v := range tests {
+		t.Run(name, func(t *testing.T) {
+			Normalize(v)
+			if got := norm(v); math.Abs(got-1) > 1e-6 {
+				t.Errorf("norm = %v, want 1", got)
+			}
+		})
+	}
+}
+
+func TestNormalizeKeepsDirection(t *testing.T) {
+	v := []float32{3, 4}
+	Normalize(v)
+	if math.Abs(float64(v[0])-0.6) > 1e-6 || math.Abs(float64(v[1])-0.8) > 1e-6 {
+		t.Errorf("Normalize([3 4]) = %v, want [0.6 0.8]", v)
+	}
+}
+
+func TestNormalizeZeroVector(t *testing.T) {
+	v := []float32{0, 0, 0}
+	Normalize(v)
+	for i, x := range v {
+		if x != 0 || math.IsNaN(float64(x)) {
+			t.Fatalf("v[%d] = %v, want 0", i, x)
+		}
+	}
+}
+
+func TestNormalizingEmbedder(t *testing.T) {
+	e := NewNormalizingEmbedder(stubEmbedder{vectors: [][]float32{{3, 4}, {0, 0}}})
+	ctx := context.Background()
+
+	got, err := e.Embed(ctx, []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := norm(got[0]); math.Abs(n-1) > 1e-6 {
+		t.Errorf("Embed: norm = %v, want 1", n)
+	}
+	if n := norm(got[1]); n != 0 {
+		t.Errorf("Embed: zero vector norm = %v, want 0", n)
+	}
+
+	single, err := e.EmbedSingle(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := norm(single); math.Abs(n-1) > 1e-6 {
+		t.Errorf("EmbedSingle: norm = %v, want 1", n)
+	}
+}
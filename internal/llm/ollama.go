@@ -0,0 +1,197 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Ollama defaults.
+const (
+	DefaultOllamaURL   = "http://localhost:11434"
+	DefaultOllamaModel = "nomic-embed-text:latest"
+)
+
+// OllamaEmbedder generates embeddings using a local Ollama server.
+type OllamaEmbedder struct {
+	embedderSettings
+	url   string
+	model string
+
+	// The first warm-up runs once in the background; warmupDone is closed
+	// when it finishes, with its error in warmupErr.
+	warmupOnce sync.Once
+	warmupDone chan struct{}
+	warmupErr  error
+}
+
+// OllamaRequest is the request format for Ollama embeddings.
+type OllamaRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+}
+
+// OllamaResponse is the response format from Ollama embeddings.
+type OllamaResponse struct {
+	Embedding []float64 `json:"embedding"`
+}
+
+// NewOllamaEmbedder creates an embedder for the Ollama server at baseURL.
+func NewOllamaEmbedder(baseURL, model string, opts ...EmbedderOption) *OllamaEmbedder {
+	if baseURL == "" {
+		baseURL = DefaultOllamaURL
+	}
+	if model == "" {
+		model = DefaultOllamaModel
+	}
+	return &OllamaEmbedder{
+		embedderSettings: newEmbedderSettings(opts),
+		url:              strings.TrimRight(baseURL, "/") + "/api/embeddings",
+		model:            model,
+		warmupDone:       make(chan struct{}),
+	}
+}
+
+// Embed generates embeddings for the given texts.
+func (e *OllamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return embedConcurrently(ctx, texts, e.concurrency, e.EmbedSingle)
+}
+
+// EmbedSingle generates an embedding for a single text, retrying connection
+// errors and 5xx responses such as those Ollama returns while loading a
+// model.
+func (e *OllamaEmbedder) EmbedSingle(ctx context.Context, text string) ([]float32, error) {
+	if e.warmupTimeout > 0 {
+		// Concurrent first calls wait for the warm-up rather than all
+		// hitting a cold model, but no longer than their own context allows.
+		// A failed warm-up is logged and the embedding tried anyway.
+		e.startWarmUp(ctx)
+		if err := e.awaitWarmUp(ctx); err != nil && ctx.Err() != nil {
+			return nil, err
+		}
+	}
+	return e.retry(ctx, "Ollama", func() ([]float32, bool, error) {
+		return e.embedOnce(ctx, e.httpClient, text)
+	})
+}
+
+// WarmUp loads the model with a single request. The first warm-up, whether
+// from here or from the first embedding, runs once while concurrent
+// embeddings wait for it; later calls, e.g. after Ollama restarted, send a
+// new request. It returns early with ctx's error if ctx ends first.
+func (e *OllamaEmbedder) WarmUp(ctx context.Context) error {
+	if !e.startWarmUp(ctx) {
+		select {
+		case <-e.warmupDone:
+			return e.warmUp(ctx)
+		default:
+		}
+	}
+	return e.awaitWarmUp(ctx)
+}
+
+// startWarmUp starts the first warm-up in the background unless it already
+// started, reporting whether this call started it.
+func (e *OllamaEmbedder) startWarmUp(ctx context.Context) bool {
+	started := false
+	e.warmupOnce.Do(func() {
+		started = true
+		go func() {
+			defer close(e.warmupDone)
+			if e.warmupErr = e.warmUp(ctx); e.warmupErr != nil {
+				log.Printf("Ollama warm-up failed: %v", e.warmupErr)
+			}
+		}()
+	})
+	return started
+}
+
+// awaitWarmUp waits for the first warm-up to finish or ctx to end.
+func (e *OllamaEmbedder) awaitWarmUp(ctx context.Context) error {
+	select {
+	case <-e.warmupDone:
+		return e.warmupErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// warmUp sends one request. It is detached from ctx's cancellation: the
+// caller that happens to trigger it may give up, but the embeddings waiting
+// on the load shouldn't fail with it. It is bounded by the warm-up timeout,
+// or the HTTP client's timeout without one.
+func (e *OllamaEmbedder) warmUp(ctx context.Context) error {
+	ctx = context.WithoutCancel(ctx)
+	client := e.httpClient
+	if e.warmupTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.warmupTimeout)
+		defer cancel()
+		// The warm-up timeout replaces the regular one
+		client = &http.Client{Transport: e.httpClient.Transport}
+	}
+	_, _, err := e.embedOnce(ctx, client, "warm-up")
+	return err
+}
+
+// embedOnce sends one embedding request. It reports whether a failure is
+// worth retrying.
+func (e *OllamaEmbedder) embedOnce(ctx context.Context, client *http.Client, text string) ([]float32, bool, error) {
+	reqBody := OllamaRequest{
+		Model:  e.model,
+		Prompt: prepareEmbedInput(text),
+	}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, false, fmt.Errorf("marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// Connection resets are retryable; our own cancellation is not
+		return nil, ctx.Err() == nil, fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, ctx.Err() == nil, fmt.Errorf("read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode >= http.StatusInternalServerError,
+			fmt.Errorf("ollama error: status %d, body: %s", resp.StatusCode, string(respBody))
+	}
+
+	var ollamaResp OllamaResponse
+	if err := json.Unmarshal(respBody, &ollamaResp); err != nil {
+		return nil, false, fmt.Errorf("decode response: %w", err)
+	}
+
+	if len(ollamaResp.Embedding) == 0 {
+		return nil, false, fmt.Errorf("empty embedding returned")
+	}
+
+	return float64ToFloat32(ollamaResp.Embedding), false, nil
+}
+
+// Ping checks that Ollama can produce embeddings with a single request
+// bounded by ctx. It doesn't wait for the warm-up or retry, so readiness
+// probes answer within their own deadline even while the model loads.
+func (e *OllamaEmbedder) Ping(ctx context.Context) error {
+	_, _, err := e.embedOnce(ctx, e.httpClient, "ping")
+	return err
+}
@@ -0,0 +1,103 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// coldOllama serves embeddings, holding warm-up requests until release is
+// closed, like Ollama loading a model.
+func coldOllama(t *testing.T, release <-chan struct{}) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Prompt == "warm-up" {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		json.NewEncoder(w).Encode(OllamaResponse{Embedding: []float64{1, 0}})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestOllamaWaitersHonorContextDuringWarmUp(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	e := NewOllamaEmbedder(coldOllama(t, release).URL, "", WithEmbedWarmup(time.Minute))
+
+	tests := []struct {
+		name    string
+		call    func(context.Context) error
+		wantErr error
+	}{
+		{
+			name: "EmbedSingle",
+			call: func(ctx context.Context) error {
+				_, err := e.EmbedSingle(ctx, "hello")
+				return err
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name:    "WarmUp",
+			call:    e.WarmUp,
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name: "Ping",
+			call: e.Ping,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			err := tt.call(ctx)
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("took %v, want it bounded by the 50ms context", elapsed)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOllamaEmbedsAfterWarmUp(t *testing.T) {
+	release := make(chan struct{})
+	e := NewOllamaEmbedder(coldOllama(t, release).URL, "", WithEmbedWarmup(time.Minute))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := e.EmbedSingle(context.Background(), "hello")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("EmbedSingle returned before the warm-up finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("EmbedSingle didn't return after the warm-up finished")
+	}
+}
@@ -0,0 +1,136 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// OpenAI-compatible embedding defaults.
+const (
+	DefaultOpenAIEmbeddingURL   = "https://api.openai.com/v1"
+	DefaultOpenAIEmbeddingModel = "text-embedding-3-small"
+)
+
+// OpenAIEmbedder generates embeddings from an OpenAI-compatible
+// /embeddings endpoint, such as OpenAI or Groq.
+type OpenAIEmbedder struct {
+	embedderSettings
+	baseURL string
+	url     string
+	apiKey  string
+	model   string
+}
+
+// OpenAIEmbeddingRequest is the request format for /embeddings.
+type OpenAIEmbeddingRequest struct {
+	Model string `json:"model"`
+	Input string `json:"input"`
+}
+
+// OpenAIEmbeddingResponse is the response format from /embeddings.
+type OpenAIEmbeddingResponse struct {
+	Data []struct {
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+}
+
+// NewOpenAIEmbedder creates an embedder for the API at baseURL, e.g.
+// "https://api.openai.com/v1".
+func NewOpenAIEmbedder(baseURL, apiKey, model string, opts ...EmbedderOption) *OpenAIEmbedder {
+	if baseURL == "" {
+		baseURL = DefaultOpenAIEmbeddingURL
+	}
+	if model == "" {
+		model = DefaultOpenAIEmbeddingModel
+	}
+	baseURL = strings.TrimRight(baseURL, "/")
+	return &OpenAIEmbedder{
+		embedderSettings: newEmbedderSettings(opts),
+		baseURL:          baseURL,
+		url:              baseURL + "/embeddings",
+		apiKey:           apiKey,
+		model:            model,
+	}
+}
+
+// Embed generates embeddings for the given texts.
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return embedConcurrently(ctx, texts, e.concurrency, e.EmbedSingle)
+}
+
+// EmbedSingle generates an embedding for a single text.
+func (e *OpenAIEmbedder) EmbedSingle(ctx context.Context, text string) ([]float32, error) {
+	reqBody := OpenAIEmbeddingRequest{
+		Model: e.model,
+		Input: prepareEmbedInput(text),
+	}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embedding API error: status %d, body: %s", resp.StatusCode, string(respBody))
+	}
+
+	var embResp OpenAIEmbeddingResponse
+	if err := json.Unmarshal(respBody, &embResp); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	if len(embResp.Data) == 0 || len(embResp.Data[0].Embedding) == 0 {
+		return nil, fmt.Errorf("empty embedding returned")
+	}
+
+	return float64ToFloat32(embResp.Data[0].Embedding), nil
+}
+
+// Ping checks that the API is reachable and accepts the API key by listing
+// models, which unlike an embedding request isn't billed.
+func (e *OpenAIEmbedder) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.baseURL+"/models", nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("embedding API error: status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAIEmbedderPingListsModels(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "ok", status: http.StatusOK},
+		{name: "unauthorized", status: http.StatusUnauthorized, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var paths []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				paths = append(paths, r.Method+" "+r.URL.Path)
+				if got := r.Header.Get("Authorization"); got != "Bearer key" {
+					t.Errorf("Authorization = %q", got)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(`{"data":[]}`))
+			}))
+			defer srv.Close()
+
+			err := NewOpenAIEmbedder(srv.URL+"/v1/", "key", "").Ping(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Ping error = %v, want error %v", err, tt.wantErr)
+			}
+			if len(paths) != 1 || paths[0] != "GET /v1/models" {
+				t.Errorf("requests = %v, want one GET /v1/models and no embedding", paths)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxRetryDelay caps the delay between retries, including Retry-After.
+const maxRetryDelay = 30 * time.Second
+
+// ClientOption configures optional Client behaviour.
+type ClientOption func(*Client)
+
+// WithRetry sets how many attempts are made for retryable Groq responses
+// (429, 500, 502, 503) and the base delay for exponential backoff.
+func WithRetry(maxAttempts int, baseDelay time.Duration) ClientOption {
+	return func(c *Client) {
+		if maxAttempts > 0 {
+			c.maxAttempts = maxAttempts
+		}
+		if baseDelay > 0 {
+			c.baseDelay = baseDelay
+		}
+	}
+}
+
+// WithBaseURL sends requests to an OpenAI-compatible API root other than
+// Groq's, such as an LLM gateway or a mock server. An empty URL keeps the
+// default.
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) {
+		if baseURL != "" {
+			c.endpoint = chatEndpoint(baseURL)
+		}
+	}
+}
+
+// WithTransport sends requests through t, so the client can share a
+// connection pool with the other clients.
+func WithTransport(t http.RoundTripper) ClientOption {
+	return func(c *Client) {
+		if t != nil {
+			c.httpClient.Transport = t
+		}
+	}
+}
+
+// WithTimeouts sets the total deadline for non-streaming calls and the idle
+// timeout after which a stalled stream is cancelled.
+func WithTimeouts(request, streamIdle time.Duration) ClientOption {
+	return func(c *Client) {
+		if request > 0 {
+			c.requestTimeout = request
+		}
+		if streamIdle > 0 {
+			c.streamIdleTimeout = streamIdle
+		}
+	}
+}
+
+// retryableStatus reports whether a Groq status code is worth retrying.
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusInternalServerError,
+		http.StatusBadGateway, http.StatusServiceUnavailable:
+		return true
+	}
+	return false
+}
+
+// post sends a chat request, retrying retryable statuses with exponential
+// backoff and jitter. On success the caller owns the response body.
+func (c *Client) post(ctx context.Context, body []byte) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("do request: %w", err)
+		}
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		groqErr := fmt.Errorf("groq error: status %d, body: %s", resp.StatusCode, string(respBody))
+
+		if !retryableStatus(resp.StatusCode) || attempt >= c.maxAttempts {
+			return nil, groqErr
+		}
+
+		delay := c.backoff(attempt, resp.Header.Get("Retry-After"))
+		log.Printf("Groq returned %d (attempt %d/%d), retrying in %v", resp.StatusCode, attempt, c.maxAttempts, delay)
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w (gave up retrying: %v)", groqErr, ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+}
+
+// backoff returns the delay before the next attempt, preferring the server's
+// Retry-After when present.
+func (c *Client) backoff(attempt int, retryAfter string) time.Duration {
+	if secs, err := strconv.Atoi(retryAfter); err == nil && secs >= 0 {
+		return min(time.Duration(secs)*time.Second, maxRetryDelay)
+	}
+	if t, err := http.ParseTime(retryAfter); err == nil {
+		return min(max(time.Until(t), 0), maxRetryDelay)
+	}
+
+	delay := c.baseDelay << (attempt - 1)
+	jitter := time.Duration(rand.Int63n(int64(delay)/2 + 1))
+	return min(delay+jitter, maxRetryDelay)
+}
@@ -0,0 +1,39 @@
+// Package logging configures the process-wide structured logger.
+package logging
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Setup installs a slog logger writing to stderr as the default logger.
+// format is "json" or "text"; level is "debug", "info", "warn" or "error".
+// Output from the standard log package is routed through it as well.
+func Setup(format, level string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: parseLevel(level)}
+
+	var handler slog.Handler
+	if strings.EqualFold(format, "json") {
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	} else {
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	}
+
+	logger := slog.New(handler)
+	slog.SetDefault(logger)
+	return logger
+}
+
+func parseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
@@ -0,0 +1,44 @@
+package rag
+
+import (
+	"context"
+	"log/slog"
+
+	"go-bot/internal/vector"
+)
+
+// WithBroadenedRetrieval retries a module-scoped search without the module
+// filter when it finds nothing relevant, keeping the role filter. The retry
+// only searches the default store and never runs for requests whose modules
+// are an access restriction (QueryOptions.ModulesRestricted).
+func WithBroadenedRetrieval(enabled bool) Option {
+	return func(s *Service) {
+		s.broadenRetrieval = enabled
+	}
+}
+
+// retrieveBroadening retrieves like retrieve, falling back to the broadened
+// search when enabled and the scoped one has no results above the score
+// threshold. It reports whether the results came from the fallback.
+func (s *Service) retrieveBroadening(ctx context.Context, queries []string, opts QueryOptions) ([]vector.SearchResult, bool, error) {
+	results, err := s.retrieve(ctx, queries, opts)
+	if err != nil || !s.broadenRetrieval || len(opts.Modules) == 0 || opts.ModulesRestricted {
+		return results, false, err
+	}
+	if len(s.aboveThreshold(results, opts)) > 0 {
+		return results, false, nil
+	}
+
+	broad := opts
+	broad.Modules = nil
+	broadened, err := s.retrieve(ctx, queries, broad)
+	if err != nil {
+		slog.Warn("broadened retrieval failed", "modules", opts.Modules, "error", err)
+		return results, false, nil
+	}
+	if len(s.aboveThreshold(broadened, opts)) == 0 {
+		return results, false, nil
+	}
+	slog.Info("no results in requested modules, broadened retrieval", "modules", opts.Modules, "results", len(broadened))
+	return broadened, true, nil
+}
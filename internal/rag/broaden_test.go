@@ -0,0 +1,92 @@
+package rag
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go-bot/internal/llm"
+)
+
+func TestBroadenedRetrieval(t *testing.T) {
+	payroll := testDoc{id: "p", module: "Payroll", text: "Run payroll from the dashboard.", score: 0.9}
+	hrOnly := testDoc{id: "h", module: "Leave", text: "Approve leave from the HR console.", roles: []string{"HR Manager"}, score: 0.9}
+
+	tests := []struct {
+		name         string
+		docs         []testDoc
+		enabled      bool
+		opts         QueryOptions
+		wantIDs      []string
+		wantBroad    bool
+		wantSearches int32
+	}{
+		{
+			name:         "scoped search finds results",
+			docs:         []testDoc{payroll},
+			enabled:      true,
+			opts:         QueryOptions{Modules: []string{"Payroll"}},
+			wantIDs:      []string{"p"},
+			wantSearches: 1,
+		},
+		{
+			name:         "empty scoped search is broadened",
+			docs:         []testDoc{payroll},
+			enabled:      true,
+			opts:         QueryOptions{Modules: []string{"Expenses"}},
+			wantIDs:      []string{"p"},
+			wantBroad:    true,
+			wantSearches: 2,
+		},
+		{
+			name:         "broadened search keeps the role filter",
+			docs:         []testDoc{hrOnly},
+			enabled:      true,
+			opts:         QueryOptions{Modules: []string{"Expenses"}, Roles: []string{"Employee"}},
+			wantSearches: 2,
+		},
+		{
+			name:         "disabled",
+			docs:         []testDoc{payroll},
+			opts:         QueryOptions{Modules: []string{"Expenses"}},
+			wantSearches: 1,
+		},
+		{
+			name:         "unscoped request",
+			enabled:      true,
+			wantSearches: 1,
+		},
+		{
+			name:         "restricted modules are never broadened",
+			docs:         []testDoc{payroll},
+			enabled:      true,
+			opts:         QueryOptions{Modules: []string{"Expenses"}, ModulesRestricted: true},
+			wantSearches: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t, tt.docs...)
+			svc := NewService(&llm.FakeCompleter{}, &fakeEmbedder{}, store, WithBroadenedRetrieval(tt.enabled))
+
+			results, broad, err := svc.retrieveBroadening(context.Background(), []string{"how do I run payroll"}, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, r := range results {
+				ids = append(ids, r.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("results = %v, want %v", ids, tt.wantIDs)
+			}
+			if broad != tt.wantBroad {
+				t.Errorf("broadened = %v, want %v", broad, tt.wantBroad)
+			}
+			if got := store.searches.Load(); got != tt.wantSearches {
+				t.Errorf("%d searches, want %d", got, tt.wantSearches)
+			}
+		})
+	}
+}
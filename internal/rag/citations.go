@@ -0,0 +1,32 @@
+package rag
+
+import (
+	"fmt"
+
+	"go-bot/internal/vector"
+)
+
+// citationInstructions is appended to the system prompt in citation mode.
+const citationInstructions = `
+
+## Citations:
+Each document in the context starts with a marker like [source: Module/Topic]. After each statement or step taken from a document, add that document's marker exactly as written. Never invent markers.`
+
+// WithCitations labels each context document with a [source: module/topic]
+// marker and asks the LLM to cite those markers in its answer.
+func WithCitations(enabled bool) Option {
+	return func(s *Service) {
+		s.citations = enabled
+	}
+}
+
+// citationMarker returns the marker identifying r in the prompt, falling
+// back to its ID when module or topic is missing.
+func citationMarker(r vector.SearchResult) string {
+	module, _ := r.Payload["module"].(string)
+	topic, _ := r.Payload["topic"].(string)
+	if module == "" || topic == "" {
+		return fmt.Sprintf("[source: %s]", r.ID)
+	}
+	return fmt.Sprintf("[source: %s/%s]", module, topic)
+}
@@ -0,0 +1,106 @@
+package rag
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Built-in answer cleanup rules.
+const (
+	// CleanupRolePrefix strips a leading "Assistant:" label.
+	CleanupRolePrefix = "role_prefix"
+	// CleanupSpecialTokens strips leaked chat template tokens like <|eot_id|>.
+	CleanupSpecialTokens = "special_tokens"
+	// CleanupCodeFence unwraps an answer wrapped whole in a code fence.
+	CleanupCodeFence = "code_fence"
+	// CleanupBoilerplate strips trailing sign-offs like "I hope this helps!".
+	CleanupBoilerplate = "boilerplate"
+	// CleanupWhitespace trims the answer, trailing spaces on each line and
+	// runs of blank lines.
+	CleanupWhitespace = "whitespace"
+)
+
+// DefaultCleanupRules lists every built-in rule in the order they run.
+var DefaultCleanupRules = []string{
+	CleanupSpecialTokens,
+	CleanupRolePrefix,
+	CleanupCodeFence,
+	CleanupBoilerplate,
+	CleanupWhitespace,
+}
+
+var (
+	rolePrefix     = regexp.MustCompile(`(?i)^\s*assistant\s*:\s*`)
+	specialToken   = regexp.MustCompile(`<\|[A-Za-z0-9_]+\|>`)
+	wholeCodeFence = regexp.MustCompile("(?s)^\\s*```[A-Za-z]*\\n(.*?)\\n?```\\s*$")
+	boilerplate    = regexp.MustCompile(`(?i)\n*[ \t]*(?:I hope (?:this|that) helps|Let me know if you (?:have|need)|Feel free to (?:ask|reach out)|Is there anything else)[^\n]*\s*$`)
+	trailingSpace  = regexp.MustCompile(`(?m)[ \t]+$`)
+	blankLines     = regexp.MustCompile(`\n{3,}`)
+)
+
+// cleanupFuncs implements the built-in rules.
+var cleanupFuncs = map[string]func(string) string{
+	CleanupRolePrefix: func(s string) string {
+		return rolePrefix.ReplaceAllString(s, "")
+	},
+	CleanupSpecialTokens: func(s string) string {
+		return specialToken.ReplaceAllString(s, "")
+	},
+	CleanupCodeFence: func(s string) string {
+		if m := wholeCodeFence.FindStringSubmatch(s); m != nil {
+			return m[1]
+		}
+		return s
+	},
+	CleanupBoilerplate: func(s string) string {
+		// Sign-offs often come in pairs
+		for {
+			cleaned := boilerplate.ReplaceAllString(s, "")
+			if cleaned == s || strings.TrimSpace(cleaned) == "" {
+				return s
+			}
+			s = cleaned
+		}
+	},
+	CleanupWhitespace: func(s string) string {
+		s = trailingSpace.ReplaceAllString(s, "")
+		s = blankLines.ReplaceAllString(s, "\n\n")
+		return strings.TrimSpace(s)
+	},
+}
+
+// WithAnswerCleanup post-processes non-streaming answers: every match of the
+// custom patterns is removed, then the named built-in rules run in the
+// order given. Unknown rules and invalid patterns are logged and skipped.
+func WithAnswerCleanup(rules, patterns []string) Option {
+	return func(s *Service) {
+		s.cleanupRules = nil
+		for _, p := range patterns {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				slog.Warn("skipping invalid answer cleanup pattern", "pattern", p, "error", err)
+				continue
+			}
+			s.cleanupRules = append(s.cleanupRules, func(answer string) string {
+				return re.ReplaceAllString(answer, "")
+			})
+		}
+		for _, name := range rules {
+			fn, ok := cleanupFuncs[name]
+			if !ok {
+				slog.Warn("skipping unknown answer cleanup rule", "rule", name)
+				continue
+			}
+			s.cleanupRules = append(s.cleanupRules, fn)
+		}
+	}
+}
+
+// cleanAnswer applies the configured cleanup rules to an answer.
+func (s *Service) cleanAnswer(answer string) string {
+	for _, rule := range s.cleanupRules {
+		answer = rule(answer)
+	}
+	return answer
+}
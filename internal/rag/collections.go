@@ -0,0 +1,72 @@
+package rag
+
+import (
+	"context"
+	"sync"
+
+	"go-bot/internal/vector"
+)
+
+// WithModuleStores keeps the given modules in stores of their own, such as
+// one Qdrant collection per module. Requests scoped to modules search only
+// their stores, falling back to the default store for unmapped modules;
+// unscoped requests search only the default store, so a module store is
+// never read unless the caller scoped the request to it. Without it all
+// modules share the default store.
+func WithModuleStores(stores map[string]vector.VectorStore) Option {
+	return func(s *Service) {
+		s.moduleStores = stores
+	}
+}
+
+// storesFor returns the stores a request searches.
+func (s *Service) storesFor(opts QueryOptions) []vector.VectorStore {
+	if len(s.moduleStores) == 0 || len(opts.Modules) == 0 {
+		return []vector.VectorStore{s.vectorClient}
+	}
+
+	var stores []vector.VectorStore
+	seen := make(map[vector.VectorStore]bool)
+	add := func(store vector.VectorStore) {
+		if !seen[store] {
+			seen[store] = true
+			stores = append(stores, store)
+		}
+	}
+	for _, module := range opts.Modules {
+		if store, ok := s.moduleStores[module]; ok {
+			add(store)
+		} else {
+			add(s.vectorClient)
+		}
+	}
+	return stores
+}
+
+// searchStores runs search against each store concurrently and merges the
+// results, keeping the best limit. The first error fails the whole search.
+func searchStores(ctx context.Context, stores []vector.VectorStore, limit int,
+	search func(context.Context, vector.VectorStore) ([]vector.SearchResult, error)) ([]vector.SearchResult, error) {
+	if len(stores) == 1 {
+		return search(ctx, stores[0])
+	}
+
+	sets := make([][]vector.SearchResult, len(stores))
+	errs := make([]error, len(stores))
+	var wg sync.WaitGroup
+	for i, store := range stores {
+		wg.Add(1)
+		go func(i int, store vector.VectorStore) {
+			defer wg.Done()
+			sets[i], errs[i] = search(ctx, store)
+		}(i, store)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return mergeResults(sets, limit), nil
+}
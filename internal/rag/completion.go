@@ -0,0 +1,110 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"go-bot/internal/llm"
+)
+
+// DefaultMaxContinuations is how many follow-up requests complete makes for
+// an answer cut off at max_tokens.
+const DefaultMaxContinuations = 2
+
+// continuePrompt asks the LLM to pick up a truncated answer.
+const continuePrompt = "Continue your previous answer exactly where it stopped. Do not repeat anything you already wrote."
+
+// WithContinuations sets how many follow-up requests are made when a
+// non-streaming answer is truncated at max_tokens. Zero disables them.
+func WithContinuations(n int) Option {
+	return func(s *Service) {
+		if n >= 0 {
+			s.maxContinuations = n
+		}
+	}
+}
+
+// WithStopSequences makes the LLM stop answering at any of stop, e.g. a
+// delimiter an integration parses responses by. Query rewriting and
+// reranking requests don't use them. See llm.ValidateStop for the limits.
+func WithStopSequences(stop []string) Option {
+	return func(s *Service) {
+		s.stopSequences = stop
+	}
+}
+
+// WithDeterministic samples every LLM call with seed at temperature zero,
+// so identical queries against the same collection give stable answers.
+// It is meant for regression tests and answer snapshots.
+func WithDeterministic(seed int) Option {
+	return func(s *Service) {
+		temperature := 0.0
+		s.seed = &seed
+		s.temperature = &temperature
+	}
+}
+
+// samplingFor resolves the seed and temperature of an LLM call, preferring
+// the request's overrides.
+func (s *Service) samplingFor(opts QueryOptions) llm.CompletionOptions {
+	sampling := llm.CompletionOptions{Seed: s.seed, Temperature: s.temperature}
+	if opts.Seed != nil {
+		sampling.Seed = opts.Seed
+	}
+	if opts.Temperature != nil {
+		sampling.Temperature = opts.Temperature
+	}
+	return sampling
+}
+
+// completionOptions returns the LLM call options for an answer.
+func (s *Service) completionOptions(opts QueryOptions) llm.CompletionOptions {
+	callOpts := s.samplingFor(opts)
+	callOpts.Stop = s.stopSequences
+	return callOpts
+}
+
+// complete runs a chat completion, continuing the answer while it stops
+// because of max_tokens, up to the configured number of continuations.
+// Usage is summed across requests.
+func (s *Service) complete(ctx context.Context, messages []llm.Message, opts QueryOptions) (*QueryResult, error) {
+	maxTokens := s.maxTokensFor(opts, messages)
+	var (
+		answer strings.Builder
+		usage  llm.Usage
+		reason string
+	)
+	for attempt := 0; ; attempt++ {
+		resp, err := s.llmClient.CreateChatCompletion(ctx, messages, maxTokens, s.completionOptions(opts))
+		if err != nil {
+			return nil, fmt.Errorf("llm completion: %w", err)
+		}
+		if len(resp.Choices) == 0 {
+			return nil, fmt.Errorf("no response from LLM")
+		}
+
+		content := resp.Choices[0].Message.Content
+		answer.WriteString(content)
+		usage.PromptTokens += resp.Usage.PromptTokens
+		usage.CompletionTokens += resp.Usage.CompletionTokens
+		usage.TotalTokens += resp.Usage.TotalTokens
+		reason = resp.Choices[0].FinishReason
+
+		if reason != llm.FinishReasonLength || attempt >= s.maxContinuations {
+			break
+		}
+		slog.Info("answer truncated, continuing", "max_tokens", maxTokens, "continuation", attempt+1, "max_continuations", s.maxContinuations)
+		messages = append(messages,
+			llm.Message{Role: "assistant", Content: content},
+			llm.Message{Role: "user", Content: continuePrompt},
+		)
+	}
+
+	return &QueryResult{
+		Answer:       s.cleanAnswer(answer.String()),
+		Usage:        &usage,
+		FinishReason: reason,
+	}, nil
+}
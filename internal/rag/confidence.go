@@ -0,0 +1,104 @@
+package rag
+
+import (
+	"io"
+
+	"go-bot/internal/vector"
+)
+
+// Weights of the signals combined into a confidence score. They sum to 1.
+const (
+	confidenceTopWeight     = 0.6
+	confidenceGapWeight     = 0.25
+	confidenceSupportWeight = 0.15
+)
+
+// confidenceSupportCount is how many supporting documents give full
+// support; confidenceSupportScore is the score a document needs to count
+// when the request has no score threshold.
+const (
+	confidenceSupportCount = 3
+	confidenceSupportScore = 0.5
+)
+
+// DefaultLowConfidenceDisclaimer is prepended to low-confidence answers.
+const DefaultLowConfidenceDisclaimer = "I'm not fully certain about this answer, so please double-check it against the SyntraFlow documentation.\n\n"
+
+// WithConfidenceDisclaimer prepends disclaimer to knowledge base answers
+// whose confidence is below threshold. Zero disables it; an empty
+// disclaimer keeps the default.
+func WithConfidenceDisclaimer(threshold float32, disclaimer string) Option {
+	return func(s *Service) {
+		s.disclaimerThreshold = threshold
+		if disclaimer != "" {
+			s.disclaimer = disclaimer
+		}
+	}
+}
+
+// confidence scores how well the documents an answer is based on cover the
+// question, from 0 to 1, using retrieval scores only:
+//
+//   - top (weight 0.6): the best similarity score, clamped to 0-1. The
+//     closer the best document is to the question, the likelier it answers
+//     it.
+//   - gap (weight 0.25): how far the best document is ahead of the
+//     runner-up, as a fraction of the best score; 1 when there is only one
+//     document. A clear winner means the answer doesn't hinge on which of
+//     several near-equal documents the model picked.
+//   - support (weight 0.15): the number of documents scoring at least the
+//     score threshold (or 0.5 without one), up to 3, divided by 3.
+//     Several good documents corroborate each other.
+//
+// No documents means no confidence.
+func confidence(results []vector.SearchResult, threshold float32) float32 {
+	if len(results) == 0 {
+		return 0
+	}
+
+	minSupport := threshold
+	if minSupport <= 0 {
+		minSupport = confidenceSupportScore
+	}
+	var best, second float32
+	supporting := 0
+	for _, r := range results {
+		if r.Score > best {
+			best, second = r.Score, best
+		} else if r.Score > second {
+			second = r.Score
+		}
+		if r.Score >= minSupport {
+			supporting++
+		}
+	}
+
+	top := clamp01(best)
+	gap := float32(1)
+	if len(results) > 1 && best > 0 {
+		gap = clamp01((best - second) / best)
+	}
+	support := float32(min(supporting, confidenceSupportCount)) / confidenceSupportCount
+
+	return confidenceTopWeight*top + confidenceGapWeight*gap + confidenceSupportWeight*support
+}
+
+func clamp01(x float32) float32 {
+	return max(0, min(x, 1))
+}
+
+// needsDisclaimer reports whether the disclaimer is enabled and confidence
+// is below it.
+func (s *Service) needsDisclaimer(confidence float32) bool {
+	return s.disclaimerThreshold > 0 && confidence < s.disclaimerThreshold
+}
+
+// writeDisclaimer writes the disclaimer ahead of a streamed answer when
+// confidence calls for it.
+func (s *Service) writeDisclaimer(writer io.Writer, confidence float32) error {
+	if !s.needsDisclaimer(confidence) {
+		return nil
+	}
+	_, err := io.WriteString(writer, s.disclaimer)
+	return err
+}
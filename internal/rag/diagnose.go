@@ -0,0 +1,201 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"go-bot/internal/vector"
+)
+
+// Miss causes reported by Diagnose.
+const (
+	CauseNone       = "none"
+	CauseFiltering  = "filtering"
+	CauseEmbedding  = "embedding"
+	CauseContentGap = "content_gap"
+)
+
+// lexicalMatchThreshold is the word overlap above which a query variation is
+// considered a lexical match.
+const lexicalMatchThreshold = 0.5
+
+// Candidate is a document considered during retrieval diagnosis.
+type Candidate struct {
+	ID               string
+	Module           string
+	Topic            string
+	Score            float32
+	BelowThreshold   bool
+	NearestVariation string
+	LexicalScore     float64
+
+	excludedByFilter bool
+}
+
+// Diagnosis explains why a query did or didn't retrieve relevant documents.
+// Documents the request's filters exclude are only counted, so a diagnosis
+// never reveals documents the caller can't retrieve.
+type Diagnosis struct {
+	Query            string
+	ScoreThreshold   float32
+	Filtered         bool
+	Candidates       []Candidate
+	ExcludedByFilter int
+	Cause            string
+}
+
+// Diagnose retrieves candidates for userQuery ignoring the score threshold
+// and filters, then reports which were dropped and why, along with the
+// closest query variation for each. Candidates the filters exclude are left
+// out and counted in ExcludedByFilter. Cause is the most likely reason for a
+// miss: filtering, embedding (a variation matches lexically but scores
+// low), content_gap, or none when retrieval succeeded.
+func (s *Service) Diagnose(ctx context.Context, userQuery string, opts QueryOptions) (*Diagnosis, error) {
+	embedding, err := s.embedQuery(ctx, userQuery)
+	if err != nil {
+		return nil, fmt.Errorf("embed query: %w", err)
+	}
+
+	topK := s.topKFor(opts)
+	unfiltered, err := searchStores(ctx, s.storesFor(opts), topK, func(ctx context.Context, store vector.VectorStore) ([]vector.SearchResult, error) {
+		return store.Search(ctx, embedding, topK)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+
+	filter := filterFor(opts)
+	var allowed map[string]bool
+	if filter != nil {
+		filtered, err := searchStores(ctx, s.storesFor(opts), topK, func(ctx context.Context, store vector.VectorStore) ([]vector.SearchResult, error) {
+			return store.SearchWithFilter(ctx, embedding, topK, filter)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("filtered search: %w", err)
+		}
+		allowed = make(map[string]bool, len(filtered))
+		for _, r := range filtered {
+			allowed[r.ID] = true
+		}
+	}
+
+	threshold := s.scoreThresholdFor(opts)
+	diag := &Diagnosis{
+		Query:          userQuery,
+		ScoreThreshold: threshold,
+		Filtered:       filter != nil,
+	}
+
+	queryWords := wordSet(userQuery)
+	candidates := make([]Candidate, len(unfiltered))
+	for i, r := range unfiltered {
+		module, _ := r.Payload["module"].(string)
+		topic, _ := r.Payload["topic"].(string)
+		c := Candidate{
+			ID:               r.ID,
+			Module:           module,
+			Topic:            topic,
+			Score:            r.Score,
+			BelowThreshold:   r.Score < threshold,
+			excludedByFilter: allowed != nil && !allowed[r.ID],
+		}
+		c.NearestVariation, c.LexicalScore = nearestVariation(queryWords, r.Payload)
+		candidates[i] = c
+	}
+
+	diag.Cause = missCause(candidates)
+	for _, c := range candidates {
+		if c.excludedByFilter {
+			diag.ExcludedByFilter++
+			continue
+		}
+		diag.Candidates = append(diag.Candidates, c)
+	}
+	return diag, nil
+}
+
+// missCause picks the most likely reason relevant documents weren't used.
+func missCause(candidates []Candidate) string {
+	var filtered, lexical bool
+	for _, c := range candidates {
+		if !c.BelowThreshold && !c.excludedByFilter {
+			return CauseNone
+		}
+		if !c.BelowThreshold && c.excludedByFilter {
+			filtered = true
+		}
+		if c.LexicalScore >= lexicalMatchThreshold {
+			lexical = true
+		}
+	}
+	switch {
+	case filtered:
+		return CauseFiltering
+	case lexical:
+		return CauseEmbedding
+	default:
+		return CauseContentGap
+	}
+}
+
+// nearestVariation returns the document's query variation with the most
+// word overlap with the query, and that overlap. It returns "" when no
+// variation shares a word with the query.
+func nearestVariation(queryWords map[string]bool, payload map[string]interface{}) (string, float64) {
+	var best string
+	var bestScore float64
+	for _, v := range payloadStrings(payload["query_variations"]) {
+		if score := jaccard(queryWords, wordSet(v)); score > bestScore {
+			best, bestScore = v, score
+		}
+	}
+	return best, bestScore
+}
+
+// payloadStrings converts a JSON-decoded payload array into strings.
+func payloadStrings(v interface{}) []string {
+	// Payloads that didn't round-trip through JSON, e.g. from
+	// vector.MemoryStore, keep their original type
+	if strs, ok := v.([]string); ok {
+		return strs
+	}
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		if str, ok := item.(string); ok {
+			out = append(out, str)
+		}
+	}
+	return out
+}
+
+// wordSet returns the lowercased words of text.
+func wordSet(text string) map[string]bool {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}
+
+// jaccard returns the word overlap between two sets.
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	inter := 0
+	for w := range a {
+		if b[w] {
+			inter++
+		}
+	}
+	return float64(inter) / float64(len(a)+len(b)-inter)
+}
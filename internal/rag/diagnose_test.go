@@ -0,0 +1,96 @@
+package rag
+
+import (
+	"context"
+	"testing"
+
+	"go-bot/internal/llm"
+)
+
+func TestDiagnoseCauses(t *testing.T) {
+	tests := []struct {
+		name         string
+		docs         []testDoc
+		opts         QueryOptions
+		wantCause    string
+		wantVisible  int
+		wantExcluded int
+	}{
+		{
+			name: "relevant document",
+			docs: []testDoc{
+				{id: "a", module: "Payroll", score: 0.9},
+			},
+			wantCause:   CauseNone,
+			wantVisible: 1,
+		},
+		{
+			name: "below threshold with a lexical match",
+			docs: []testDoc{
+				{id: "a", module: "Payroll", score: 0.3, variations: []string{"how do I run payroll"}},
+			},
+			wantCause:   CauseEmbedding,
+			wantVisible: 1,
+		},
+		{
+			name: "excluded by filter",
+			docs: []testDoc{
+				{id: "hidden", module: "Payroll", topic: "Salaries", score: 0.9, roles: []string{"Admin"}},
+				{id: "weak", module: "Payroll", score: 0.2},
+			},
+			opts:         QueryOptions{Roles: []string{"Employee"}},
+			wantCause:    CauseFiltering,
+			wantVisible:  1,
+			wantExcluded: 1,
+		},
+		{
+			name: "content gap",
+			docs: []testDoc{
+				{id: "a", module: "Leave", score: 0.3, variations: []string{"apply for annual leave"}},
+			},
+			wantCause:   CauseContentGap,
+			wantVisible: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t, tt.docs...)
+			svc := NewService(&llm.FakeCompleter{}, &fakeEmbedder{}, store, WithScoreThreshold(0.5))
+
+			diag, err := svc.Diagnose(context.Background(), "how do I run payroll", tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diag.Cause != tt.wantCause {
+				t.Errorf("Cause = %q, want %q", diag.Cause, tt.wantCause)
+			}
+			if len(diag.Candidates) != tt.wantVisible {
+				t.Errorf("got %d candidates, want %d", len(diag.Candidates), tt.wantVisible)
+			}
+			if diag.ExcludedByFilter != tt.wantExcluded {
+				t.Errorf("ExcludedByFilter = %d, want %d", diag.ExcludedByFilter, tt.wantExcluded)
+			}
+		})
+	}
+}
+
+func TestDiagnoseHidesExcludedDocuments(t *testing.T) {
+	store := newTestStore(t,
+		testDoc{id: "secret", module: "Payroll", topic: "Executive pay", score: 0.9, roles: []string{"Admin"}, variations: []string{"executive pay"}},
+	)
+	svc := NewService(&llm.FakeCompleter{}, &fakeEmbedder{}, store, WithScoreThreshold(0.5))
+
+	diag, err := svc.Diagnose(context.Background(), "executive pay", QueryOptions{Roles: []string{"Employee"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range diag.Candidates {
+		if c.ID == "secret" || c.Topic == "Executive pay" || c.NearestVariation == "executive pay" {
+			t.Errorf("diagnosis reveals excluded document: %+v", c)
+		}
+	}
+	if diag.ExcludedByFilter != 1 {
+		t.Errorf("ExcludedByFilter = %d, want 1", diag.ExcludedByFilter)
+	}
+}
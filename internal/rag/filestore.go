@@ -0,0 +1,207 @@
+package rag
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"go-bot/internal/llm"
+)
+
+// FileStore keeps conversations in memory and appends every change to a
+// JSONL file, so history survives restarts. The file is compacted when the
+// store is opened and whenever most of its records are no longer retained,
+// e.g. after messages or conversations were evicted.
+type FileStore struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	records int // lines in the file
+	cache   *MemoryStore
+}
+
+// fileStoreCompactMin is the number of records below which the file is
+// never compacted while open.
+const fileStoreCompactMin = 1000
+
+// conversationRecord is one line of the JSONL file: a message, or a marker
+// that the conversation was cleared.
+type conversationRecord struct {
+	ConversationID string `json:"conversation_id"`
+	Role           string `json:"role,omitempty"`
+	Content        string `json:"content,omitempty"`
+	Cleared        bool   `json:"cleared,omitempty"`
+}
+
+// NewFileStore opens or creates the JSONL file at path, keeping at most
+// maxMessages per conversation and at most maxConversations conversations;
+// zero keeps everything.
+func NewFileStore(path string, maxMessages, maxConversations int) (*FileStore, error) {
+	fs := &FileStore{path: path, cache: NewMemoryStore(maxMessages, maxConversations)}
+	if err := fs.load(); err != nil {
+		return nil, err
+	}
+	if err := fs.compact(); err != nil {
+		return nil, err
+	}
+	if err := fs.open(); err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+func (fs *FileStore) open() error {
+	f, err := os.OpenFile(fs.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open conversation file: %w", err)
+	}
+	fs.file = f
+	return nil
+}
+
+// load replays the existing file into the in-memory cache.
+func (fs *FileStore) load() error {
+	f, err := os.Open(fs.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open conversation file: %w", err)
+	}
+	defer f.Close()
+
+	ctx := context.Background()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var rec conversationRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return fmt.Errorf("parse conversation file line %d: %w", line, err)
+		}
+		if rec.Cleared {
+			fs.cache.Clear(ctx, rec.ConversationID)
+			continue
+		}
+		fs.cache.Append(ctx, rec.ConversationID, llm.Message{Role: rec.Role, Content: rec.Content})
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read conversation file: %w", err)
+	}
+	return nil
+}
+
+// compact rewrites the file with only the retained messages, least
+// recently used conversations first so reloading keeps their recency.
+func (fs *FileStore) compact() error {
+	tmp := fs.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("create conversation file: %w", err)
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	records := 0
+	err = fs.cache.each(func(id string, msgs []llm.Message) error {
+		for _, msg := range msgs {
+			if err := enc.Encode(conversationRecord{ConversationID: id, Role: msg.Role, Content: msg.Content}); err != nil {
+				return err
+			}
+			records++
+		}
+		return nil
+	})
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("write conversation file: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("write conversation file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("write conversation file: %w", err)
+	}
+	if err := os.Rename(tmp, fs.path); err != nil {
+		return fmt.Errorf("replace conversation file: %w", err)
+	}
+	fs.records = records
+	return nil
+}
+
+// Append records messages and writes them to the file.
+func (fs *FileStore) Append(ctx context.Context, id string, msgs ...llm.Message) error {
+	records := make([]conversationRecord, len(msgs))
+	for i, msg := range msgs {
+		records[i] = conversationRecord{ConversationID: id, Role: msg.Role, Content: msg.Content}
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := fs.write(records...); err != nil {
+		return err
+	}
+	fs.cache.Append(ctx, id, msgs...)
+	return fs.maybeCompact()
+}
+
+// Get returns the stored messages for a conversation.
+func (fs *FileStore) Get(ctx context.Context, id string) ([]llm.Message, error) {
+	return fs.cache.Get(ctx, id)
+}
+
+// Clear forgets a conversation and records that in the file.
+func (fs *FileStore) Clear(ctx context.Context, id string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := fs.write(conversationRecord{ConversationID: id, Cleared: true}); err != nil {
+		return err
+	}
+	fs.cache.Clear(ctx, id)
+	return fs.maybeCompact()
+}
+
+// maybeCompact compacts the file once it holds more than twice the retained
+// messages. fs.mu must be held.
+func (fs *FileStore) maybeCompact() error {
+	if fs.records < fileStoreCompactMin || fs.records <= 2*fs.cache.len() {
+		return nil
+	}
+	if err := fs.file.Close(); err != nil {
+		return fmt.Errorf("close conversation file: %w", err)
+	}
+	compactErr := fs.compact()
+	if err := fs.open(); err != nil {
+		return err
+	}
+	return compactErr
+}
+
+// Close closes the underlying file.
+func (fs *FileStore) Close() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.file.Close()
+}
+
+// write appends records to the file. fs.mu must be held.
+func (fs *FileStore) write(records ...conversationRecord) error {
+	var buf []byte
+	for _, rec := range records {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Errorf("encode conversation record: %w", err)
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+
+	if _, err := fs.file.Write(buf); err != nil {
+		return fmt.Errorf("write conversation file: %w", err)
+	}
+	fs.records += len(records)
+	return nil
+}
@@ -0,0 +1,112 @@
+package rag
+
+import (
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"go-bot/internal/vector"
+)
+
+// DefaultRefusalMessage is returned under strict grounding when the
+// knowledge base doesn't support an answer.
+const DefaultRefusalMessage = "I don't have information about that in the SyntraFlow knowledge base, so I can't answer it reliably. Please contact your administrator or ask about another feature."
+
+// strictGroundingInstructions is appended to the system prompt under strict
+// grounding.
+const strictGroundingInstructions = `
+
+## Strict Grounding:
+- Answer ONLY from the provided knowledge base context; do not use general knowledge
+- If the context does not fully answer the question, reply exactly with: ` + DefaultRefusalMessage
+
+// WithStrictGrounding refuses to answer unless retrieval supports the answer.
+// It applies to every query when global is set, otherwise only when a
+// retrieved document belongs to one of modules. Under strict grounding the
+// LLM is not called when no result scores at least minScore.
+func WithStrictGrounding(global bool, modules []string, minScore float32) Option {
+	return func(s *Service) {
+		s.strictGlobal = global
+		s.strictModules = make(map[string]bool, len(modules))
+		for _, m := range modules {
+			s.strictModules[strings.ToLower(m)] = true
+		}
+		s.strictMinScore = minScore
+	}
+}
+
+// groundingMinWordRunes is the shortest word the grounding check counts;
+// shorter ones are mostly function words that say nothing about the source.
+const groundingMinWordRunes = 4
+
+// WithGroundingCheck verifies strict-grounding answers after generation,
+// replacing an answer with the refusal when less than minOverlap of its
+// words appear in the context it was given. Zero disables the check.
+func WithGroundingCheck(minOverlap float32) Option {
+	return func(s *Service) {
+		s.groundingMinOverlap = minOverlap
+	}
+}
+
+// grounded reports whether answer is supported by contextText: whether at
+// least the minimum fraction of the answer's words occur in the context.
+// Answers without such words, like a bare refusal, pass.
+func (s *Service) grounded(answer, contextText string) bool {
+	if s.groundingMinOverlap <= 0 {
+		return true
+	}
+	source := wordSet(contextText)
+	total, found := 0, 0
+	for w := range wordSet(answer) {
+		if utf8.RuneCountInString(w) < groundingMinWordRunes {
+			continue
+		}
+		total++
+		if source[w] {
+			found++
+		}
+	}
+	if total == 0 {
+		return true
+	}
+	overlap := float32(found) / float32(total)
+	if overlap < s.groundingMinOverlap {
+		slog.Info("ungrounded answer refused", "overlap", overlap, "min_overlap", s.groundingMinOverlap)
+		return false
+	}
+	return true
+}
+
+// strictFor reports whether strict grounding applies to a request: when
+// it is global, when the request is scoped to a strict module, or when a
+// retrieved document belongs to one. Checking the requested modules keeps a
+// strict module's questions strict even when retrieval finds nothing there.
+func (s *Service) strictFor(opts QueryOptions, results []vector.SearchResult) bool {
+	if s.strictGlobal {
+		return true
+	}
+	for _, m := range opts.Modules {
+		if s.strictModules[strings.ToLower(m)] {
+			return true
+		}
+	}
+	for _, r := range results {
+		module, _ := r.Payload["module"].(string)
+		if s.strictModules[strings.ToLower(module)] {
+			return true
+		}
+	}
+	return false
+}
+
+// unsupported reports whether no result is relevant enough to ground an
+// answer. Results have already been cut to the request's score threshold,
+// so an empty set is always unsupported.
+func (s *Service) unsupported(results []vector.SearchResult) bool {
+	for _, r := range results {
+		if r.Score >= s.strictMinScore {
+			return false
+		}
+	}
+	return true
+}
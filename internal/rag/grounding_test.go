@@ -0,0 +1,154 @@
+package rag
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go-bot/internal/llm"
+)
+
+func TestStrictGroundingRefusal(t *testing.T) {
+	payroll := testDoc{id: "p", module: "Payroll", text: "Run payroll from the Payroll dashboard.", score: 0.9}
+	weakPayroll := testDoc{id: "wp", module: "Payroll", text: "Payroll settings.", score: 0.2}
+	leave := testDoc{id: "l", module: "Leave", text: "Apply for leave from the Leave page.", score: 0.9}
+
+	tests := []struct {
+		name        string
+		docs        []testDoc
+		grounding   Option
+		opts        QueryOptions
+		wantRefusal bool
+	}{
+		{
+			name:        "global with empty retrieval",
+			grounding:   WithStrictGrounding(true, nil, 0.5),
+			wantRefusal: true,
+		},
+		{
+			name:        "global with irrelevant retrieval",
+			docs:        []testDoc{weakPayroll},
+			grounding:   WithStrictGrounding(true, nil, 0.5),
+			wantRefusal: true,
+		},
+		{
+			name:      "global with relevant retrieval",
+			docs:      []testDoc{payroll},
+			grounding: WithStrictGrounding(true, nil, 0.5),
+		},
+		{
+			name:        "strict module with irrelevant retrieval",
+			docs:        []testDoc{weakPayroll, leave},
+			grounding:   WithStrictGrounding(false, []string{"payroll"}, 0.5),
+			opts:        QueryOptions{Modules: []string{"Payroll"}},
+			wantRefusal: true,
+		},
+		{
+			name:        "request scoped to a strict module without results there",
+			docs:        []testDoc{leave},
+			grounding:   WithStrictGrounding(false, []string{"payroll"}, 0.5),
+			opts:        QueryOptions{Modules: []string{"Payroll"}},
+			wantRefusal: true,
+		},
+		{
+			name:      "non-strict module",
+			docs:      []testDoc{weakPayroll, leave},
+			grounding: WithStrictGrounding(false, []string{"payroll"}, 0.5),
+			opts:      QueryOptions{Modules: []string{"Leave"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, stream := range []bool{false, true} {
+				completer := &llm.FakeCompleter{Answers: []string{"Apply for leave from the Leave page."}}
+				svc := NewService(completer, &fakeEmbedder{}, newTestStore(t, tt.docs...), tt.grounding)
+
+				var answer string
+				if stream {
+					var sb strings.Builder
+					if _, err := svc.StreamQuery(context.Background(), "how do I run payroll", tt.opts, &sb); err != nil {
+						t.Fatal(err)
+					}
+					answer = sb.String()
+				} else {
+					result, err := svc.Query(context.Background(), "how do I run payroll", tt.opts)
+					if err != nil {
+						t.Fatal(err)
+					}
+					answer = result.Answer
+				}
+
+				if got := answer == DefaultRefusalMessage; got != tt.wantRefusal {
+					t.Errorf("stream=%v: answer %q, want refusal %v", stream, answer, tt.wantRefusal)
+				}
+				if tt.wantRefusal && len(completer.Calls()) > 0 {
+					t.Errorf("stream=%v: refusal called the LLM", stream)
+				}
+			}
+		})
+	}
+}
+
+func TestStrictGroundingPrompt(t *testing.T) {
+	completer := &llm.FakeCompleter{Answers: []string{"Run payroll from the Payroll dashboard."}}
+	store := newTestStore(t, testDoc{id: "p", module: "Payroll", text: "Run payroll from the Payroll dashboard.", score: 0.9})
+	svc := NewService(completer, &fakeEmbedder{}, store, WithStrictGrounding(false, []string{"Payroll"}, 0.5))
+
+	if _, err := svc.Query(context.Background(), "how do I run payroll", QueryOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	calls := completer.Calls()
+	if len(calls) != 1 {
+		t.Fatalf("got %d LLM calls, want 1", len(calls))
+	}
+	if !strings.Contains(calls[0][0].Content, strictGroundingInstructions) {
+		t.Error("system prompt is missing the strict grounding instructions")
+	}
+}
+
+func TestGroundingCheck(t *testing.T) {
+	source := "Run payroll from the Payroll dashboard, then approve the payslips."
+	tests := []struct {
+		name        string
+		answer      string
+		wantRefusal bool
+	}{
+		{name: "grounded", answer: "Open the Payroll dashboard, run payroll, then approve the payslips."},
+		{name: "ungrounded", answer: "Contact your bank manager to transfer salaries manually every Friday.", wantRefusal: true},
+		{name: "refusal", answer: DefaultRefusalMessage, wantRefusal: true},
+		{name: "no content words", answer: "Yes."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, stream := range []bool{false, true} {
+				completer := &llm.FakeCompleter{Answers: []string{tt.answer}}
+				store := newTestStore(t, testDoc{id: "p", module: "Payroll", text: source, score: 0.9})
+				svc := NewService(completer, &fakeEmbedder{}, store,
+					WithStrictGrounding(true, nil, 0.5),
+					WithGroundingCheck(0.5),
+				)
+
+				var answer string
+				if stream {
+					var sb strings.Builder
+					if _, err := svc.StreamQuery(context.Background(), "how do I run payroll", QueryOptions{}, &sb); err != nil {
+						t.Fatal(err)
+					}
+					answer = sb.String()
+				} else {
+					result, err := svc.Query(context.Background(), "how do I run payroll", QueryOptions{})
+					if err != nil {
+						t.Fatal(err)
+					}
+					answer = result.Answer
+				}
+
+				if got := answer == DefaultRefusalMessage; got != tt.wantRefusal {
+					t.Errorf("stream=%v: answer %q, want refusal %v", stream, answer, tt.wantRefusal)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package rag
+
+import (
+	"context"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-bot/internal/vector"
+)
+
+// testDim is the vector size of the test stores. Queries embed to
+// queryVector unless registered otherwise, so a document's score is the
+// first component of its vector.
+const testDim = 3
+
+var queryVector = []float32{1, 0, 0}
+
+// scored returns a unit vector whose cosine similarity with queryVector is
+// score.
+func scored(score float32) []float32 {
+	return []float32{score, float32(math.Sqrt(float64(1 - score*score))), 0}
+}
+
+// fakeEmbedder embeds registered texts to their vectors and anything else
+// to queryVector, after an optional delay.
+type fakeEmbedder struct {
+	vectors map[string][]float32
+	delay   time.Duration
+	calls   atomic.Int32
+}
+
+func (f *fakeEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		v, err := f.EmbedSingle(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func (f *fakeEmbedder) EmbedSingle(ctx context.Context, text string) ([]float32, error) {
+	f.calls.Add(1)
+	if f.delay > 0 {
+		select {
+		case <-time.After(f.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if v, ok := f.vectors[text]; ok {
+		return v, nil
+	}
+	return queryVector, nil
+}
+
+func (f *fakeEmbedder) Ping(ctx context.Context) error { return nil }
+
+// countingStore is a MemoryStore that counts searches.
+type countingStore struct {
+	*vector.MemoryStore
+	searches atomic.Int32
+}
+
+func (c *countingStore) Search(ctx context.Context, v []float32, topK int) ([]vector.SearchResult, error) {
+	c.searches.Add(1)
+	return c.MemoryStore.Search(ctx, v, topK)
+}
+
+func (c *countingStore) SearchWithFilter(ctx context.Context, v []float32, topK int, filter map[string]interface{}) ([]vector.SearchResult, error) {
+	c.searches.Add(1)
+	return c.MemoryStore.SearchWithFilter(ctx, v, topK, filter)
+}
+
+func (c *countingStore) SearchWithVectors(ctx context.Context, v []float32, topK int, filter map[string]interface{}) ([]vector.SearchResult, error) {
+	c.searches.Add(1)
+	return c.MemoryStore.SearchWithVectors(ctx, v, topK, filter)
+}
+
+// testDoc is a knowledge base entry stored at a fixed score.
+type testDoc struct {
+	id         string
+	module     string
+	topic      string
+	text       string
+	roles      []string
+	variations []string
+	score      float32
+}
+
+// newTestStore returns a counting memory store holding docs. Documents
+// without roles are visible to all users.
+func newTestStore(t *testing.T, docs ...testDoc) *countingStore {
+	t.Helper()
+	store := &countingStore{MemoryStore: vector.NewMemoryStore(testDim)}
+	points := make([]vector.Point, len(docs))
+	for i, d := range docs {
+		roles := d.roles
+		if roles == nil {
+			roles = []string{AllUsersRole}
+		}
+		points[i] = vector.Point{
+			ID:     d.id,
+			Vector: scored(d.score),
+			Payload: map[string]interface{}{
+				"id":               d.id,
+				"module":           d.module,
+				"topic":            d.topic,
+				"text":             d.text,
+				"roles":            vector.NormalizeRoles(roles),
+				"query_variations": d.variations,
+			},
+		}
+	}
+	if err := store.UpsertPoints(context.Background(), points); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func float32Ptr(f float32) *float32 { return &f }
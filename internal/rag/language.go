@@ -0,0 +1,51 @@
+package rag
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultLanguage is the answer language when a request doesn't set one.
+const DefaultLanguage = "English"
+
+// AutoLanguage asks the LLM to answer in the language of the question.
+const AutoLanguage = "auto"
+
+// languageNames maps common ISO 639-1 codes to names the LLM follows more
+// reliably than bare codes.
+var languageNames = map[string]string{
+	"ar": "Arabic",
+	"de": "German",
+	"en": "English",
+	"es": "Spanish",
+	"fr": "French",
+	"hi": "Hindi",
+	"it": "Italian",
+	"ja": "Japanese",
+	"pt": "Portuguese",
+	"ur": "Urdu",
+	"zh": "Chinese",
+}
+
+// languageInstructions returns the system prompt section selecting the
+// answer language. Retrieval always uses the original question; only the
+// answer is affected.
+func languageInstructions(language string) string {
+	language = strings.TrimSpace(language)
+	if language == "" {
+		language = DefaultLanguage
+	}
+	if strings.EqualFold(language, AutoLanguage) {
+		return `
+
+## Language:
+Answer in the same language as the user's question, even though the context is in English.`
+	}
+	if name, ok := languageNames[strings.ToLower(language)]; ok {
+		language = name
+	}
+	return fmt.Sprintf(`
+
+## Language:
+Answer in %s, whatever the language of the context or question. Keep feature, menu and button names as they appear in the context.`, language)
+}
@@ -0,0 +1,235 @@
+package rag
+
+import (
+	"container/list"
+	"context"
+	"log/slog"
+	"sync"
+
+	"go-bot/internal/llm"
+)
+
+// DefaultMaxHistoryTurns is the number of prior user/assistant exchanges kept
+// per conversation.
+const DefaultMaxHistoryTurns = 10
+
+// DefaultMaxConversations is the number of conversations the default store
+// keeps before forgetting the least recently used.
+const DefaultMaxConversations = 10000
+
+// ConversationStore persists the messages of each conversation.
+type ConversationStore interface {
+	// Append adds messages to the end of a conversation.
+	Append(ctx context.Context, id string, msgs ...llm.Message) error
+	// Get returns a conversation's messages, oldest first.
+	Get(ctx context.Context, id string) ([]llm.Message, error)
+	// Clear forgets a conversation.
+	Clear(ctx context.Context, id string) error
+}
+
+// MemoryStore keeps conversations in process memory, forgetting the least
+// recently used once it holds too many.
+type MemoryStore struct {
+	mu               sync.Mutex
+	maxMessages      int
+	maxConversations int
+	convs            map[string]*list.Element
+	lru              *list.List // of *conversation, most recently used first
+	messages         int
+}
+
+type conversation struct {
+	id   string
+	msgs []llm.Message
+}
+
+// NewMemoryStore creates an in-memory store keeping at most maxMessages per
+// conversation and at most maxConversations conversations; zero keeps
+// everything.
+func NewMemoryStore(maxMessages, maxConversations int) *MemoryStore {
+	return &MemoryStore{
+		maxMessages:      maxMessages,
+		maxConversations: maxConversations,
+		convs:            make(map[string]*list.Element),
+		lru:              list.New(),
+	}
+}
+
+// Append records messages, evicting the oldest beyond the cap and the least
+// recently used conversations beyond the store's capacity.
+func (m *MemoryStore) Append(_ context.Context, id string, msgs ...llm.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.convs[id]
+	if ok {
+		m.lru.MoveToFront(el)
+	} else {
+		el = m.lru.PushFront(&conversation{id: id})
+		m.convs[id] = el
+	}
+	conv := el.Value.(*conversation)
+	m.messages -= len(conv.msgs)
+	conv.msgs = capMessages(append(conv.msgs, msgs...), m.maxMessages)
+	m.messages += len(conv.msgs)
+
+	for m.maxConversations > 0 && m.lru.Len() > m.maxConversations {
+		m.remove(m.lru.Back())
+	}
+	return nil
+}
+
+// Get returns a copy of the stored messages for a conversation.
+func (m *MemoryStore) Get(_ context.Context, id string) ([]llm.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.convs[id]
+	if !ok {
+		return nil, nil
+	}
+	m.lru.MoveToFront(el)
+	return append([]llm.Message(nil), el.Value.(*conversation).msgs...), nil
+}
+
+// Clear forgets a conversation.
+func (m *MemoryStore) Clear(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.convs[id]; ok {
+		m.remove(el)
+	}
+	return nil
+}
+
+func (m *MemoryStore) remove(el *list.Element) {
+	conv := m.lru.Remove(el).(*conversation)
+	delete(m.convs, conv.id)
+	m.messages -= len(conv.msgs)
+}
+
+// len returns the number of messages held across all conversations.
+func (m *MemoryStore) len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.messages
+}
+
+// each calls fn for every conversation, least recently used first, so
+// replaying them in order restores the same recency.
+func (m *MemoryStore) each(fn func(id string, msgs []llm.Message) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for el := m.lru.Back(); el != nil; el = el.Prev() {
+		conv := el.Value.(*conversation)
+		if err := fn(conv.id, conv.msgs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// capMessages keeps the last max messages; max <= 0 keeps everything.
+func capMessages(msgs []llm.Message, max int) []llm.Message {
+	if max > 0 && len(msgs) > max {
+		return append([]llm.Message(nil), msgs[len(msgs)-max:]...)
+	}
+	return msgs
+}
+
+// WithMaxHistory sets how many prior turns are replayed for a conversation.
+// Zero disables conversation memory.
+func WithMaxHistory(turns int) Option {
+	return func(s *Service) {
+		s.maxHistoryTurns = turns
+	}
+}
+
+// WithHistoryAnswerLimit truncates prior answers replayed from a
+// conversation so long answers don't dominate the prompt: the latest answer
+// keeps up to maxRunes runes and each older one half as many as the answer
+// after it, down to historyAnswerMinRunes. Zero replays answers in full.
+func WithHistoryAnswerLimit(maxRunes int) Option {
+	return func(s *Service) {
+		s.historyAnswerRunes = maxRunes
+	}
+}
+
+// historyAnswerMinRunes is the least an older answer is truncated to.
+const historyAnswerMinRunes = 200
+
+// truncateAnswers applies the history answer limit to history, newest
+// answer first.
+func (s *Service) truncateAnswers(history []llm.Message) []llm.Message {
+	if s.historyAnswerRunes <= 0 {
+		return history
+	}
+	limit := s.historyAnswerRunes
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role != "assistant" {
+			continue
+		}
+		if truncated := truncateRunes(history[i].Content, limit); truncated != history[i].Content {
+			history[i].Content = truncated + "…"
+		}
+		limit = max(limit/2, min(historyAnswerMinRunes, s.historyAnswerRunes))
+	}
+	return history
+}
+
+// WithMaxConversations caps how many conversations the default in-memory
+// store keeps; the least recently used are forgotten first. Zero keeps
+// every conversation. It has no effect with WithConversationStore.
+func WithMaxConversations(n int) Option {
+	return func(s *Service) {
+		s.maxConversations = n
+	}
+}
+
+// WithConversationStore sets where conversation history is kept. The
+// default is an in-memory store.
+func WithConversationStore(store ConversationStore) Option {
+	return func(s *Service) {
+		s.conversations = store
+	}
+}
+
+// ClearConversation forgets the stored history of a conversation.
+func (s *Service) ClearConversation(ctx context.Context, id string) error {
+	return s.conversations.Clear(ctx, id)
+}
+
+// remember records a completed turn. Failures are logged rather than
+// returned since the answer has already been produced.
+func (s *Service) remember(ctx context.Context, id, userQuery, answer string) {
+	if id == "" || s.maxHistoryTurns <= 0 {
+		return
+	}
+	err := s.conversations.Append(ctx, id,
+		llm.Message{Role: "user", Content: userQuery},
+		llm.Message{Role: "assistant", Content: answer},
+	)
+	if err != nil {
+		slog.Error("failed to record conversation", "conversation_id", id, "error", err)
+	}
+}
+
+// withHistory inserts a conversation's prior turns between the system prompt
+// and the new user message.
+func (s *Service) withHistory(ctx context.Context, messages []llm.Message, conversationID string) []llm.Message {
+	if conversationID == "" || s.maxHistoryTurns <= 0 {
+		return messages
+	}
+	history, err := s.conversations.Get(ctx, conversationID)
+	if err != nil {
+		slog.Error("failed to load conversation", "conversation_id", conversationID, "error", err)
+		return messages
+	}
+	history = s.truncateAnswers(capMessages(history, s.maxHistoryTurns*2))
+	if len(history) == 0 {
+		return messages
+	}
+	out := make([]llm.Message, 0, len(messages)+len(history))
+	out = append(out, messages[0])
+	out = append(out, history...)
+	return append(out, messages[1:]...)
+}
@@ -0,0 +1,56 @@
+package rag
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"go-bot/internal/llm"
+)
+
+func TestTruncateAnswers(t *testing.T) {
+	history := func(answers ...string) []llm.Message {
+		var msgs []llm.Message
+		for _, a := range answers {
+			msgs = append(msgs, llm.Message{Role: "user", Content: "question"}, llm.Message{Role: "assistant", Content: a})
+		}
+		return msgs
+	}
+	long := strings.Repeat("é", 2000)
+
+	tests := []struct {
+		name  string
+		limit int
+		// answers oldest first, and their expected lengths in runes
+		// without the ellipsis
+		answers []string
+		want    []int
+	}{
+		{name: "disabled", limit: 0, answers: []string{long, long}, want: []int{2000, 2000}},
+		{name: "older answers get less", limit: 1000, answers: []string{long, long, long, long, long}, want: []int{200, 200, 250, 500, 1000}},
+		{name: "short answers are kept", limit: 1000, answers: []string{"ok", long, "fine"}, want: []int{2, 500, 4}},
+		{name: "limit below the floor", limit: 100, answers: []string{long, long, long}, want: []int{100, 100, 100}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewService(&llm.FakeCompleter{}, &fakeEmbedder{}, newTestStore(t), WithHistoryAnswerLimit(tt.limit))
+			got := svc.truncateAnswers(history(tt.answers...))
+
+			var lengths []int
+			for _, m := range got {
+				if m.Role == "user" {
+					if m.Content != "question" {
+						t.Errorf("user turn changed to %q", m.Content)
+					}
+					continue
+				}
+				lengths = append(lengths, utf8.RuneCountInString(strings.TrimSuffix(m.Content, "…")))
+			}
+			if !reflect.DeepEqual(lengths, tt.want) {
+				t.Errorf("answer lengths = %v, want %v", lengths, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package rag
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"go-bot/internal/llm"
+)
+
+// DefaultMetaPatterns match questions about the assistant itself, which are
+// answered from the About section without retrieval. They are anchored to
+// the whole question so that e.g. "what is SyntraFlow's refund policy?"
+// still goes to the knowledge base.
+var DefaultMetaPatterns = []string{
+	`^(hi|hello|hey)[!. ]*$`,
+	`^who are you\s*[?.!]*$`,
+	`^what are you\s*[?.!]*$`,
+	`^what can you (do|help( me)? with)\s*[?.!]*$`,
+	`^how can you help( me)?\s*[?.!]*$`,
+	`^what is syntraflow\s*[?.!]*$`,
+	`^what does syntraflow do\s*[?.!]*$`,
+}
+
+// WithMetaPatterns sets the case-insensitive regular expressions used to
+// detect meta questions. Invalid patterns are logged and skipped; an empty
+// slice disables meta detection.
+func WithMetaPatterns(patterns []string) Option {
+	return func(s *Service) {
+		s.metaPatterns = compileMetaPatterns(patterns)
+	}
+}
+
+func compileMetaPatterns(patterns []string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile("(?i)" + p)
+		if err != nil {
+			slog.Warn("skipping invalid meta pattern", "pattern", p, "error", err)
+			continue
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled
+}
+
+// isMetaQuestion reports whether the query is about the assistant itself.
+func (s *Service) isMetaQuestion(userQuery string) bool {
+	q := strings.TrimSpace(userQuery)
+	for _, re := range s.metaPatterns {
+		if re.MatchString(q) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildMetaMessages builds the chat messages for a meta question.
+func (s *Service) buildMetaMessages(userQuery string) []llm.Message {
+	return []llm.Message{
+		{
+			Role:    "system",
+			Content: metaSystemPrompt,
+		},
+		{
+			Role:    "user",
+			Content: userQuery,
+		},
+	}
+}
@@ -0,0 +1,55 @@
+package rag
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go-bot/internal/llm"
+)
+
+func TestMetaQuestionsSkipRetrieval(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		patterns     []string
+		wantSearches bool
+	}{
+		{name: "who are you", query: "Who are you?"},
+		{name: "what can you do", query: "  what can you help me with  "},
+		{name: "greeting", query: "Hello!"},
+		{name: "feature question", query: "How do I run payroll?", wantSearches: true},
+		{name: "feature question mentioning the product", query: "What is SyntraFlow's refund policy?", wantSearches: true},
+		{name: "custom pattern", query: "Are you a bot?", patterns: []string{`^are you a bot\?$`}},
+		{name: "custom patterns replace the defaults", query: "Who are you?", patterns: []string{`^are you a bot\?$`}, wantSearches: true},
+		{name: "detection disabled", query: "Who are you?", patterns: []string{}, wantSearches: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, stream := range []bool{false, true} {
+				store := newTestStore(t, testDoc{id: "p", module: "Payroll", text: "Run payroll from the dashboard.", score: 0.9})
+				embedder := &fakeEmbedder{}
+				opts := []Option{}
+				if tt.patterns != nil {
+					opts = append(opts, WithMetaPatterns(tt.patterns))
+				}
+				svc := NewService(&llm.FakeCompleter{Answers: []string{"I'm the SyntraFlow assistant."}}, embedder, store, opts...)
+
+				if stream {
+					var sb strings.Builder
+					if _, err := svc.StreamQuery(context.Background(), tt.query, QueryOptions{}, &sb); err != nil {
+						t.Fatal(err)
+					}
+				} else if _, err := svc.Query(context.Background(), tt.query, QueryOptions{}); err != nil {
+					t.Fatal(err)
+				}
+
+				searched := store.searches.Load() > 0 || embedder.calls.Load() > 0
+				if searched != tt.wantSearches {
+					t.Errorf("stream=%v: searched = %v, want %v", stream, searched, tt.wantSearches)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package rag
+
+import (
+	"math"
+
+	"go-bot/internal/vector"
+)
+
+// DefaultMMRLambda weighs relevance and diversity equally.
+const DefaultMMRLambda = 0.5
+
+// WithMMR enables Maximal Marginal Relevance re-ranking. Retrieval fetches
+// fetchK candidates and keeps topK of them; lambda of 1 ranks purely by
+// relevance, 0 purely by diversity.
+func WithMMR(lambda float64, fetchK int) Option {
+	return func(s *Service) {
+		if lambda < 0 || lambda > 1 {
+			lambda = DefaultMMRLambda
+		}
+		s.mmrEnabled = true
+		s.mmrLambda = lambda
+		s.mmrFetchK = fetchK
+	}
+}
+
+// fetchLimit is how many results to request from Qdrant for topK.
+func (s *Service) fetchLimit(topK int) int {
+	limit := topK
+	if s.mmrEnabled && s.mmrFetchK > limit {
+		limit = s.mmrFetchK
+	}
+	if s.rerankCandidates > limit {
+		limit = s.rerankCandidates
+	}
+	return limit
+}
+
+// mmrSelect greedily picks topK results, each time taking the candidate
+// that best balances its relevance against its similarity to results
+// already picked. Candidates without vectors are treated as unique.
+func (s *Service) mmrSelect(candidates []vector.SearchResult, topK int) []vector.SearchResult {
+	if len(candidates) <= topK {
+		return candidates
+	}
+
+	remaining := append([]vector.SearchResult(nil), candidates...)
+	selected := make([]vector.SearchResult, 0, topK)
+	for len(selected) < topK && len(remaining) > 0 {
+		bestIdx := 0
+		bestScore := math.Inf(-1)
+		for i, c := range remaining {
+			var redundancy float64
+			for _, sel := range selected {
+				redundancy = math.Max(redundancy, cosine(c.Vector, sel.Vector))
+			}
+			score := s.mmrLambda*float64(c.Score) - (1-s.mmrLambda)*redundancy
+			if score > bestScore {
+				bestIdx, bestScore = i, score
+			}
+		}
+		selected = append(selected, remaining[bestIdx])
+		remaining = append(remaining[:bestIdx], remaining[bestIdx+1:]...)
+	}
+	return selected
+}
+
+// cosine returns the cosine similarity of a and b, or 0 if either is empty
+// or they differ in length.
+func cosine(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
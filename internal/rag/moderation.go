@@ -0,0 +1,52 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// DefaultPolicyMessage is returned in place of blocked queries or answers.
+const DefaultPolicyMessage = "Sorry, I can't help with that request. I can answer questions about using SyntraFlow."
+
+// Moderator decides whether a piece of text is allowed. When it is not,
+// reason describes why.
+type Moderator interface {
+	Moderate(ctx context.Context, text string) (allowed bool, reason string, err error)
+}
+
+// ModeratorFunc adapts a function to the Moderator interface.
+type ModeratorFunc func(ctx context.Context, text string) (bool, string, error)
+
+// Moderate calls f(ctx, text).
+func (f ModeratorFunc) Moderate(ctx context.Context, text string) (bool, string, error) {
+	return f(ctx, text)
+}
+
+// WithModeration checks queries before they reach the LLM and answers before
+// they are returned, replacing blocked content with policyMessage. Streamed
+// answers are buffered until complete so they can be checked too, which
+// means they arrive in one piece.
+func WithModeration(m Moderator, policyMessage string) Option {
+	return func(s *Service) {
+		s.moderator = m
+		if policyMessage != "" {
+			s.policyMessage = policyMessage
+		}
+	}
+}
+
+// allowed runs the moderator, if any, over text.
+func (s *Service) allowed(ctx context.Context, kind, text string) (bool, error) {
+	if s.moderator == nil {
+		return true, nil
+	}
+	ok, reason, err := s.moderator.Moderate(ctx, text)
+	if err != nil {
+		return false, fmt.Errorf("moderate %s: %w", kind, err)
+	}
+	if !ok {
+		slog.Info("moderation blocked", "kind", kind, "reason", reason)
+	}
+	return ok, nil
+}
@@ -0,0 +1,99 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go-bot/internal/llm"
+)
+
+// blockingModerator blocks any text containing one of its words.
+func blockingModerator(words ...string) Moderator {
+	return ModeratorFunc(func(ctx context.Context, text string) (bool, string, error) {
+		for _, w := range words {
+			if strings.Contains(strings.ToLower(text), w) {
+				return false, "mentions " + w, nil
+			}
+		}
+		return true, "", nil
+	})
+}
+
+func TestModeration(t *testing.T) {
+	const policy = "That's outside what I can help with."
+	const answer = "Run payroll from the dashboard."
+
+	tests := []struct {
+		name       string
+		moderator  Moderator
+		query      string
+		wantAnswer string
+		wantLLM    bool
+		wantErr    bool
+	}{
+		{
+			name:       "allowed",
+			moderator:  blockingModerator("hack"),
+			query:      "how do I run payroll",
+			wantAnswer: answer,
+			wantLLM:    true,
+		},
+		{
+			name:       "query blocked",
+			moderator:  blockingModerator("hack"),
+			query:      "how do I hack payroll",
+			wantAnswer: policy,
+		},
+		{
+			name:       "answer blocked",
+			moderator:  blockingModerator("dashboard"),
+			query:      "how do I run payroll",
+			wantAnswer: policy,
+			wantLLM:    true,
+		},
+		{
+			name: "moderator failure",
+			moderator: ModeratorFunc(func(ctx context.Context, text string) (bool, string, error) {
+				return false, "", errors.New("moderation endpoint down")
+			}),
+			query:   "how do I run payroll",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, stream := range []bool{false, true} {
+				completer := &llm.FakeCompleter{Answers: []string{answer}}
+				store := newTestStore(t, testDoc{id: "p", module: "Payroll", text: answer, score: 0.9})
+				svc := NewService(completer, &fakeEmbedder{}, store, WithModeration(tt.moderator, policy))
+
+				var got string
+				var err error
+				if stream {
+					var sb strings.Builder
+					_, err = svc.StreamQuery(context.Background(), tt.query, QueryOptions{}, &sb)
+					got = sb.String()
+				} else {
+					var result *QueryResult
+					result, err = svc.Query(context.Background(), tt.query, QueryOptions{})
+					if result != nil {
+						got = result.Answer
+					}
+				}
+
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("stream=%v: error = %v, want error %v", stream, err, tt.wantErr)
+				}
+				if got != tt.wantAnswer {
+					t.Errorf("stream=%v: answer = %q, want %q", stream, got, tt.wantAnswer)
+				}
+				if calledLLM := len(completer.Calls()) > 0; calledLLM != tt.wantLLM {
+					t.Errorf("stream=%v: LLM called = %v, want %v", stream, calledLLM, tt.wantLLM)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package rag
+
+import (
+	"log/slog"
+
+	"go-bot/internal/llm"
+	"go-bot/internal/vector"
+)
+
+// AllUsersRole marks knowledge base entries visible to every role. Roles
+// are matched case-insensitively.
+const AllUsersRole = "All Users"
+
+const (
+	// DefaultTopK is the number of documents retrieved when unset.
+	DefaultTopK = 5
+	// MaxTopK caps how many documents a single query may retrieve.
+	MaxTopK = 50
+
+	// DefaultMaxTokens is the answer length limit when unset.
+	DefaultMaxTokens = 1024
+	// DefaultContextWindow is the model's context size in tokens.
+	DefaultContextWindow = 131072
+	// minAnswerTokens is the floor when clamping to the context window.
+	minAnswerTokens = 64
+)
+
+// QueryOptions holds per-request overrides. The zero value uses the service
+// defaults.
+type QueryOptions struct {
+	// TopK is the number of documents to retrieve, clamped to 1..MaxTopK.
+	TopK int
+
+	// Modules restricts retrieval to documents from these modules.
+	Modules []string
+
+	// ModulesRestricted marks Modules as the only modules the caller may
+	// read, so retrieval is never broadened beyond them.
+	ModulesRestricted bool
+
+	// Roles restricts retrieval to documents available to any of these
+	// roles, compared case-insensitively. Documents for "All Users" are
+	// always included. Callers must derive roles from the authenticated
+	// user, never from request input.
+	Roles []string
+
+	// ConversationID replays and records prior turns of a conversation.
+	ConversationID string
+
+	// ScoreThreshold overrides the service's minimum relevance score when
+	// set.
+	ScoreThreshold *float32
+
+	// MaxTokens overrides the service's answer length limit when positive.
+	MaxTokens int
+
+	// Seed and Temperature override the LLM's sampling when set; a seed
+	// with temperature zero gives reproducible answers.
+	Seed        *int
+	Temperature *float64
+
+	// Language is the answer language: a name or ISO 639-1 code, or
+	// AutoLanguage to match the question. Empty means DefaultLanguage.
+	Language string
+
+	// Debug makes Query return the context and messages sent to the LLM
+	// in QueryResult.Debug.
+	Debug bool
+}
+
+// WithMaxTokens sets the default answer length limit in tokens.
+func WithMaxTokens(n int) Option {
+	return func(s *Service) {
+		if n > 0 {
+			s.maxTokens = n
+		}
+	}
+}
+
+// WithContextWindow sets the model's context size, used to clamp
+// max_tokens so prompt and answer fit together.
+func WithContextWindow(tokens int) Option {
+	return func(s *Service) {
+		if tokens > 0 {
+			s.contextWindow = tokens
+		}
+	}
+}
+
+// maxTokensFor resolves the answer length limit for a request, clamped so
+// the prompt plus answer stays within the context window.
+func (s *Service) maxTokensFor(opts QueryOptions, messages []llm.Message) int {
+	maxTokens := opts.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = s.maxTokens
+	}
+	available := s.contextWindow - estimateMessageTokens(messages)
+	if maxTokens > available {
+		clamped := max(available, minAnswerTokens)
+		slog.Warn("clamping max_tokens to fit the context window", "requested", maxTokens, "clamped", clamped, "context_window", s.contextWindow)
+		maxTokens = clamped
+	}
+	return maxTokens
+}
+
+// topKFor resolves the number of documents to retrieve for a request.
+func (s *Service) topKFor(opts QueryOptions) int {
+	topK := opts.TopK
+	if topK <= 0 {
+		topK = s.topK
+	}
+	if topK > MaxTopK {
+		topK = MaxTopK
+	}
+	return topK
+}
+
+// filterFor builds the Qdrant payload filter for a request, or nil when the
+// request isn't scoped.
+func filterFor(opts QueryOptions) map[string]interface{} {
+	return vector.MustFilter(scopeConditions(opts)...)
+}
+
+// scopeConditions returns the filter conditions restricting a request to its
+// modules and roles.
+func scopeConditions(opts QueryOptions) []map[string]interface{} {
+	var conditions []map[string]interface{}
+	if len(opts.Modules) > 0 {
+		conditions = append(conditions, vector.MatchAny("module", opts.Modules))
+	}
+	if len(opts.Roles) > 0 {
+		roles := vector.NormalizeRoles(append([]string{AllUsersRole}, opts.Roles...))
+		conditions = append(conditions, vector.MatchAny("roles", roles))
+	}
+	return conditions
+}
+
+// scoreThresholdFor resolves the minimum relevance score for a request.
+func (s *Service) scoreThresholdFor(opts QueryOptions) float32 {
+	if opts.ScoreThreshold != nil {
+		return *opts.ScoreThreshold
+	}
+	return s.scoreThreshold
+}
+
+// aboveThreshold drops results scoring below the request's threshold.
+func (s *Service) aboveThreshold(results []vector.SearchResult, opts QueryOptions) []vector.SearchResult {
+	threshold := s.scoreThresholdFor(opts)
+	if threshold <= 0 {
+		return results
+	}
+	kept := results[:0:0]
+	for _, r := range results {
+		if r.Score >= threshold {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
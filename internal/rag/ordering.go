@@ -0,0 +1,64 @@
+package rag
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"go-bot/internal/vector"
+)
+
+// DefaultTieBreakKeys order results with equal scores.
+var DefaultTieBreakKeys = []string{"module", "topic", "id"}
+
+// WithTieBreak orders results whose scores are within epsilon of each other
+// by the given payload keys, so output is deterministic. The key "id" uses
+// the result ID.
+func WithTieBreak(epsilon float32, keys []string) Option {
+	return func(s *Service) {
+		s.tieEpsilon = epsilon
+		if len(keys) > 0 {
+			s.tieBreakKeys = keys
+		}
+	}
+}
+
+// sortResults orders results by score, breaking near-ties with the configured
+// keys. Scores are bucketed by epsilon so the ordering stays transitive.
+func (s *Service) sortResults(results []vector.SearchResult) {
+	bucket := func(score float32) float64 {
+		if s.tieEpsilon <= 0 {
+			return float64(score)
+		}
+		return math.Floor(float64(score) / float64(s.tieEpsilon))
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		bi, bj := bucket(results[i].Score), bucket(results[j].Score)
+		if bi != bj {
+			return bi > bj
+		}
+		for _, key := range s.tieBreakKeys {
+			ki, kj := sortKey(results[i], key), sortKey(results[j], key)
+			if ki != kj {
+				return ki < kj
+			}
+		}
+		return results[i].Score > results[j].Score
+	})
+}
+
+// sortKey returns the value of a tie-break key for a result.
+func sortKey(r vector.SearchResult, key string) string {
+	if key == "id" {
+		return r.ID
+	}
+	v, ok := r.Payload[key]
+	if !ok || v == nil {
+		return ""
+	}
+	if str, ok := v.(string); ok {
+		return str
+	}
+	return fmt.Sprintf("%v", v)
+}
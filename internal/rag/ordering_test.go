@@ -0,0 +1,69 @@
+package rag
+
+import (
+	"reflect"
+	"testing"
+
+	"go-bot/internal/llm"
+	"go-bot/internal/vector"
+)
+
+func TestSortResultsTieBreak(t *testing.T) {
+	result := func(id, module, topic string, score float32) vector.SearchResult {
+		return vector.SearchResult{ID: id, Score: score, Payload: map[string]interface{}{"module": module, "topic": topic}}
+	}
+	// Shuffled so the input order can't explain the output
+	input := []vector.SearchResult{
+		result("3", "Payroll", "Run", 0.8),
+		result("1", "Leave", "Apply", 0.8),
+		result("5", "Payroll", "Approve", 0.8005),
+		result("2", "Leave", "Apply", 0.8),
+		result("4", "Expenses", "Submit", 0.95),
+		result("6", "Attendance", "Clock in", 0.5),
+	}
+
+	tests := []struct {
+		name    string
+		epsilon float32
+		keys    []string
+		want    []string
+	}{
+		{
+			name: "exact ties by module, topic, id",
+			want: []string{"4", "5", "1", "2", "3", "6"},
+		},
+		{
+			name:    "near ties within epsilon",
+			epsilon: 0.01,
+			want:    []string{"4", "1", "2", "5", "3", "6"},
+		},
+		{
+			name:    "configured keys",
+			epsilon: 0.01,
+			keys:    []string{"module", "id"},
+			want:    []string{"4", "1", "2", "3", "5", "6"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewService(&llm.FakeCompleter{}, &fakeEmbedder{}, newTestStore(t), WithTieBreak(tt.epsilon, tt.keys))
+
+			// Sorting must not depend on the input order
+			for _, order := range [][]int{{0, 1, 2, 3, 4, 5}, {5, 4, 3, 2, 1, 0}, {3, 0, 5, 1, 4, 2}} {
+				results := make([]vector.SearchResult, len(input))
+				for i, j := range order {
+					results[i] = input[j]
+				}
+				svc.sortResults(results)
+
+				var ids []string
+				for _, r := range results {
+					ids = append(ids, r.ID)
+				}
+				if !reflect.DeepEqual(ids, tt.want) {
+					t.Errorf("order %v sorted to %v, want %v", order, ids, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go-bot/internal/llm"
+	"go-bot/internal/vector"
+)
+
+const (
+	// DefaultRerankCandidates is how many results are fetched for
+	// reranking when unset.
+	DefaultRerankCandidates = 20
+	// maxRerankCandidates caps the documents sent to the LLM per query.
+	maxRerankCandidates = 30
+	// rerankDocChars truncates each candidate in the reranking prompt.
+	rerankDocChars = 800
+)
+
+const rerankInstructions = `You rank knowledge base documents about SyntraFlow by how well they answer the user's question.
+
+Score every document from 0 (irrelevant) to 10 (answers the question directly). Reply with one line per document in the form "<document number>: <score>" and nothing else.`
+
+// WithRerank enables LLM reranking. Retrieval fetches candidates results,
+// the LLM scores each for relevance to the question and the topK best
+// scoring are kept. Candidates are capped to bound cost. Reranking takes
+// the place of MMR selection when both are enabled.
+func WithRerank(candidates int) Option {
+	return func(s *Service) {
+		if candidates <= 0 {
+			candidates = DefaultRerankCandidates
+		}
+		s.rerankCandidates = min(candidates, maxRerankCandidates)
+	}
+}
+
+// rerank orders candidates by LLM relevance score and keeps topK. Documents
+// the LLM didn't score rank last; if the LLM call fails the vector order is
+// kept.
+func (s *Service) rerank(ctx context.Context, query string, candidates []vector.SearchResult, topK int, opts QueryOptions) []vector.SearchResult {
+	if len(candidates) <= 1 {
+		return candidates
+	}
+
+	var sb strings.Builder
+	for i, r := range candidates {
+		text, _ := r.Payload["text"].(string)
+		fmt.Fprintf(&sb, "Document %d:\n%s\n\n", i+1, truncateRunes(text, rerankDocChars))
+	}
+	fmt.Fprintf(&sb, "Question: %s", query)
+
+	resp, err := s.llmClient.CreateChatCompletion(ctx, []llm.Message{
+		{Role: "system", Content: rerankInstructions},
+		{Role: "user", Content: sb.String()},
+	}, 10*len(candidates)+50, s.samplingFor(opts))
+	if err != nil {
+		slog.Warn("rerank failed, keeping vector order", "error", err)
+		return firstN(candidates, topK)
+	}
+	recordUsage(ctx, resp.Usage)
+	if len(resp.Choices) == 0 {
+		slog.Warn("rerank returned no choices, keeping vector order")
+		return firstN(candidates, topK)
+	}
+
+	scores := parseRerankScores(resp.Choices[0].Message.Content, len(candidates))
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return scores[order[a]] > scores[order[b]]
+	})
+
+	reranked := make([]vector.SearchResult, 0, min(topK, len(candidates)))
+	for _, i := range order[:min(topK, len(order))] {
+		reranked = append(reranked, candidates[i])
+	}
+	slog.Debug("reranked results", "candidates", len(candidates), "kept", len(reranked))
+	return reranked
+}
+
+// rerankLine matches "<n>: <score>" lines, tolerating brackets and list
+// markers the LLM adds.
+var rerankLine = regexp.MustCompile(`(?m)^\W*(?:Document\s*)?(\d+)\W*[:=-]\s*(\d+(?:\.\d+)?)`)
+
+// parseRerankScores returns a score per document, -1 for documents the
+// reply doesn't score.
+func parseRerankScores(reply string, n int) []float64 {
+	scores := make([]float64, n)
+	for i := range scores {
+		scores[i] = -1
+	}
+	for _, m := range rerankLine.FindAllStringSubmatch(reply, -1) {
+		doc, err := strconv.Atoi(m[1])
+		if err != nil || doc < 1 || doc > n {
+			continue
+		}
+		if score, err := strconv.ParseFloat(m[2], 64); err == nil {
+			scores[doc-1] = score
+		}
+	}
+	return scores
+}
+
+func firstN(results []vector.SearchResult, n int) []vector.SearchResult {
+	if len(results) > n {
+		return results[:n]
+	}
+	return results
+}
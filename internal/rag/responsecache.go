@@ -0,0 +1,114 @@
+package rag
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"go-bot/internal/cache"
+)
+
+// WithResponseCache caches non-streamed answers in c. Keys cover the
+// normalized question, the request's scope and generation settings, and a
+// version hash of model and the system prompt, so changing either misses
+// the answers cached under the old ones. Conversation and debug requests
+// are never cached.
+func WithResponseCache(c *cache.ResponseCache, model string) Option {
+	return func(s *Service) {
+		s.responseCache = c
+		s.model = model
+	}
+}
+
+// promptVersion hashes the model and everything that shapes the answer
+// prompt.
+func (s *Service) promptVersion() string {
+	var seed, temperature string
+	if s.seed != nil {
+		seed = fmt.Sprint(*s.seed)
+	}
+	if s.temperature != nil {
+		temperature = fmt.Sprint(*s.temperature)
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "model=%s\x00prompt=%s\x00structured=%t\x00citations=%t\x00strict=%t,%v,%g\x00sampling=%s,%s\x00",
+		s.model, s.systemPrompt, s.structuredAnswers, s.citations,
+		s.strictGlobal, sortedKeys(s.strictModules), s.strictMinScore, seed, temperature)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseKey returns the cache key for a request, or false when its
+// answer mustn't be cached.
+func (s *Service) responseKey(userQuery string, opts QueryOptions) (string, bool) {
+	if s.responseCache == nil || opts.ConversationID != "" || opts.Debug {
+		return "", false
+	}
+	modules := sortedLower(opts.Modules)
+	roles := sortedLower(opts.Roles)
+	var seed, temperature string
+	if opts.Seed != nil {
+		seed = fmt.Sprint(*opts.Seed)
+	}
+	if opts.Temperature != nil {
+		temperature = fmt.Sprint(*opts.Temperature)
+	}
+	var threshold string
+	if opts.ScoreThreshold != nil {
+		threshold = fmt.Sprint(*opts.ScoreThreshold)
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%t\x00%d\x00%s\x00%d\x00%s\x00%s\x00%s",
+		s.promptVersionHash, normalizeQuery(userQuery),
+		strings.Join(modules, ","), strings.Join(roles, ","), opts.ModulesRestricted,
+		s.topKFor(opts), threshold, opts.MaxTokens, seed, temperature, opts.Language)
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// cachedResponse returns the cached answer for key. Cached answers made no
+// LLM call, so they carry no usage.
+func (s *Service) cachedResponse(key string) (*QueryResult, bool) {
+	data, ok := s.responseCache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	var result QueryResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		slog.Warn("dropping unreadable cached answer", "error", err)
+		return nil, false
+	}
+	result.Usage = nil
+	return &result, true
+}
+
+// cacheResponse stores an answer under key.
+func (s *Service) cacheResponse(key string, result *QueryResult) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		slog.Warn("not caching answer", "error", err)
+		return
+	}
+	s.responseCache.Put(key, data)
+}
+
+func sortedLower(values []string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = strings.ToLower(v)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,73 @@
+package rag
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-bot/internal/cache"
+	"go-bot/internal/llm"
+)
+
+func TestResponseCacheKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    string
+		prompt   string
+		query    string
+		opts     QueryOptions
+		wantMiss bool
+	}{
+		{name: "same settings", model: "m1", prompt: "prompt v1", query: "How do I run payroll?"},
+		{name: "same question reworded in case and spacing", model: "m1", prompt: "prompt v1", query: "how do I   run payroll?"},
+		{name: "model changed", model: "m2", prompt: "prompt v1", query: "How do I run payroll?", wantMiss: true},
+		{name: "prompt changed", model: "m1", prompt: "prompt v2", query: "How do I run payroll?", wantMiss: true},
+		{name: "scope changed", model: "m1", prompt: "prompt v1", query: "How do I run payroll?", opts: QueryOptions{Modules: []string{"Payroll"}}, wantMiss: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses := cache.NewResponseCache(10, time.Minute)
+			store := newTestStore(t, testDoc{id: "p", module: "Payroll", text: "Run payroll from the dashboard.", score: 0.9})
+
+			// Cache an answer under the original settings
+			first := NewService(&llm.FakeCompleter{Answers: []string{"cached"}}, &fakeEmbedder{}, store,
+				WithSystemPrompt("prompt v1"), WithResponseCache(responses, "m1"))
+			if _, err := first.Query(context.Background(), "How do I run payroll?", QueryOptions{}); err != nil {
+				t.Fatal(err)
+			}
+
+			completer := &llm.FakeCompleter{Answers: []string{"fresh"}}
+			svc := NewService(completer, &fakeEmbedder{}, store,
+				WithSystemPrompt(tt.prompt), WithResponseCache(responses, tt.model))
+			result, err := svc.Query(context.Background(), tt.query, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			missed := len(completer.Calls()) > 0
+			if missed != tt.wantMiss {
+				t.Errorf("cache miss = %v, want %v", missed, tt.wantMiss)
+			}
+			if !tt.wantMiss && (result.Answer != "cached" || result.Usage != nil) {
+				t.Errorf("hit returned answer %q with usage %v, want the cached answer without usage", result.Answer, result.Usage)
+			}
+		})
+	}
+}
+
+func TestResponseCacheSkipsConversations(t *testing.T) {
+	responses := cache.NewResponseCache(10, time.Minute)
+	completer := &llm.FakeCompleter{Answers: []string{"answer"}}
+	store := newTestStore(t, testDoc{id: "p", module: "Payroll", text: "Run payroll from the dashboard.", score: 0.9})
+	svc := NewService(completer, &fakeEmbedder{}, store, WithResponseCache(responses, "m1"))
+
+	for i := 0; i < 2; i++ {
+		if _, err := svc.Query(context.Background(), "How do I run payroll?", QueryOptions{ConversationID: "c1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := len(completer.Calls()); got != 2 {
+		t.Errorf("got %d LLM calls, want 2: conversation answers must not be cached", got)
+	}
+}
@@ -0,0 +1,87 @@
+package rag
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"go-bot/internal/llm"
+)
+
+// maxQueryVariants caps the alternative phrasings searched alongside the
+// rewritten query.
+const maxQueryVariants = 3
+
+const rewriteInstructions = `Rewrite the user's question about SyntraFlow into a complete, specific search query for a knowledge base. Expand abbreviations and add the likely feature or module name when it is implied.
+
+Reply with the rewritten query on the first line and nothing else.`
+
+const rewriteVariantInstructions = `Rewrite the user's question about SyntraFlow into a complete, specific search query for a knowledge base. Expand abbreviations and add the likely feature or module name when it is implied.
+
+Reply with the rewritten query on the first line, then up to 3 alternative phrasings using synonyms, one per line. Do not number the lines or add any other text.`
+
+// WithQueryRewriting makes the service ask the LLM to rewrite each question
+// into a fuller search query before retrieval. With variants set, the LLM
+// also suggests alternative phrasings, which are searched as well. The
+// original question is still used in the answer prompt.
+func WithQueryRewriting(variants bool) Option {
+	return func(s *Service) {
+		s.rewriteQueries = true
+		s.rewriteVariants = variants
+	}
+}
+
+// searchQueries returns the queries to retrieve with for userQuery. Without
+// rewriting, or if the rewrite fails, it is just userQuery.
+func (s *Service) searchQueries(ctx context.Context, userQuery string, opts QueryOptions) []string {
+	if !s.rewriteQueries {
+		return []string{userQuery}
+	}
+
+	instructions := rewriteInstructions
+	if s.rewriteVariants {
+		instructions = rewriteVariantInstructions
+	}
+	messages := []llm.Message{
+		{Role: "system", Content: instructions},
+		{Role: "user", Content: userQuery},
+	}
+
+	resp, err := s.llmClient.CreateChatCompletion(ctx, messages, 200, s.samplingFor(opts))
+	if err != nil {
+		slog.Warn("query rewrite failed, using original query", "error", err)
+		return []string{userQuery}
+	}
+	recordUsage(ctx, resp.Usage)
+	if len(resp.Choices) == 0 {
+		return []string{userQuery}
+	}
+
+	queries := parseRewrite(resp.Choices[0].Message.Content)
+	if len(queries) == 0 {
+		return []string{userQuery}
+	}
+	slog.Info("rewrote query", "original", userQuery, "rewritten", queries)
+	return queries
+}
+
+// listMarker matches bullets or numbering the LLM adds despite instructions.
+var listMarker = regexp.MustCompile(`^\s*(?:[-*]|\d+[.)])\s+`)
+
+// parseRewrite splits the LLM reply into the rewritten query followed by
+// at most maxQueryVariants alternatives, dropping blank lines.
+func parseRewrite(reply string) []string {
+	var queries []string
+	for _, line := range strings.Split(reply, "\n") {
+		line = strings.TrimSpace(listMarker.ReplaceAllString(line, ""))
+		if line == "" {
+			continue
+		}
+		queries = append(queries, line)
+		if len(queries) == 1+maxQueryVariants {
+			break
+		}
+	}
+	return queries
+}
@@ -2,36 +2,265 @@ package rag
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
 
+	"go-bot/internal/cache"
 	"go-bot/internal/llm"
 	"go-bot/internal/vector"
 )
 
 // Service handles RAG queries.
 type Service struct {
-	llmClient    *llm.Client
-	embedder     *llm.Embedder
-	vectorClient *vector.Client
+	llmClient    llm.ChatCompleter
+	embedder     llm.Embedder
+	vectorClient vector.VectorStore
 	topK         int
+	systemPrompt string
+
+	// Stores holding modules kept apart from the default store.
+	moduleStores map[string]vector.VectorStore
+
+	// Answer length limit, the model's context size and the budget for
+	// retrieved documents, in tokens.
+	maxTokens     int
+	contextWindow int
+	contextBudget int
+
+	// Optional input/output moderation.
+	moderator     Moderator
+	policyMessage string
+
+	// Sequences at which the LLM stops answering.
+	stopSequences []string
+
+	// Default sampling seed and temperature, set in deterministic mode.
+	seed        *int
+	temperature *float64
+
+	// Post-processing of non-streaming answers, applied in order.
+	cleanupRules []func(string) string
+
+	// Request overview/steps sections in non-streaming answers.
+	structuredAnswers bool
+
+	// Label context documents and ask the LLM to cite them.
+	citations bool
+
+	// Strict grounding, globally or for specific modules.
+	strictGlobal   bool
+	strictModules  map[string]bool
+	strictMinScore float32
+
+	// Post-generation check of strict-grounding answers against context.
+	groundingMinOverlap float32
+
+	// Results scoring below this are dropped before building context.
+	scoreThreshold float32
+
+	// Deterministic ordering of near-equal scores.
+	tieEpsilon   float32
+	tieBreakKeys []string
+
+	// Per-conversation history of prior turns.
+	conversations      ConversationStore
+	maxHistoryTurns    int
+	maxConversations   int
+	historyAnswerRunes int
+
+	// Retry module-scoped searches that find nothing without the module.
+	broadenRetrieval bool
+
+	// Minimum word overlap for a stored query variation to guarantee its
+	// entry a place in the context; zero disables it.
+	variationMatchThreshold float64
+
+	// Patterns for questions answered without retrieval.
+	metaPatterns []*regexp.Regexp
+
+	// Optional cache of query embeddings, keyed by normalized query.
+	embedCache *cache.EmbeddingCache
+
+	// Optional cache of answers, keyed by request and prompt version.
+	responseCache     *cache.ResponseCache
+	model             string
+	promptVersionHash string
+
+	// Optional counts of the questions asked, used to warm the caches.
+	queryTracker *cache.QueryTracker
+
+	// Follow-up requests allowed for answers truncated at max_tokens.
+	maxContinuations int
+
+	// LLM query rewriting before retrieval, optionally with variants.
+	rewriteQueries  bool
+	rewriteVariants bool
+
+	// Maximal Marginal Relevance re-ranking of retrieved results.
+	mmrEnabled bool
+	mmrLambda  float64
+	mmrFetchK  int
+
+	// LLM reranking of this many retrieved candidates; zero disables it.
+	rerankCandidates int
+
+	// Maximum number of query variants embedded and searched at once.
+	retrievalConcurrency int
+
+	// Streaming confidence gate; disabled when threshold is zero.
+	confidenceThreshold  float32
+	lowConfidenceMessage string
+
+	// Disclaimer for answers with confidence below the threshold; disabled
+	// when threshold is zero.
+	disclaimerThreshold float32
+	disclaimer          string
+}
+
+// DefaultLowConfidenceMessage is sent instead of a streamed answer when
+// retrieval confidence is below the configured threshold.
+const DefaultLowConfidenceMessage = "I'm not confident I have accurate information about that. Could you rephrase your question or ask about a specific SyntraFlow feature?"
+
+// NoInformationMessage is returned without calling the LLM when no retrieved
+// document passes the score threshold.
+const NoInformationMessage = "I don't have information about that. Please try rephrasing your question or ask about a specific SyntraFlow feature."
+
+// ErrEmbedderUnavailable is returned when a query can't be embedded and its
+// embedding isn't cached. Cached queries keep working while the embedder is
+// down.
+var ErrEmbedderUnavailable = errors.New("embedder unavailable")
+
+// Option configures optional Service behaviour.
+type Option func(*Service)
+
+// WithConfidenceGate makes StreamQuery send a single fallback message instead
+// of streaming an answer when the best retrieval score is below threshold.
+func WithConfidenceGate(threshold float32, message string) Option {
+	return func(s *Service) {
+		s.confidenceThreshold = threshold
+		if message != "" {
+			s.lowConfidenceMessage = message
+		}
+	}
+}
+
+// WithSystemPrompt replaces the built-in system prompt used for knowledge
+// base answers. An empty prompt keeps the default.
+func WithSystemPrompt(prompt string) Option {
+	return func(s *Service) {
+		if strings.TrimSpace(prompt) != "" {
+			s.systemPrompt = prompt
+		}
+	}
+}
+
+// WithScoreThreshold drops retrieved documents scoring below threshold.
+// Zero keeps every result.
+func WithScoreThreshold(threshold float32) Option {
+	return func(s *Service) {
+		s.scoreThreshold = threshold
+	}
+}
+
+// WithEmbeddingCache caches query embeddings so repeated questions skip
+// the embedder.
+func WithEmbeddingCache(c *cache.EmbeddingCache) Option {
+	return func(s *Service) {
+		s.embedCache = c
+	}
+}
+
+// WithRetrievalConcurrency bounds how many query variants are embedded and
+// searched in parallel. A value of 1 runs them sequentially.
+func WithRetrievalConcurrency(n int) Option {
+	return func(s *Service) {
+		if n > 0 {
+			s.retrievalConcurrency = n
+		}
+	}
 }
 
 // NewService creates a new RAG service.
-func NewService(llmClient *llm.Client, embedder *llm.Embedder, vectorClient *vector.Client) *Service {
-	return &Service{
-		llmClient:    llmClient,
-		embedder:     embedder,
-		vectorClient: vectorClient,
-		topK:         5,
+func NewService(llmClient llm.ChatCompleter, embedder llm.Embedder, vectorClient vector.VectorStore, opts ...Option) *Service {
+	s := &Service{
+		llmClient:            llmClient,
+		embedder:             embedder,
+		vectorClient:         vectorClient,
+		topK:                 DefaultTopK,
+		maxTokens:            DefaultMaxTokens,
+		contextWindow:        DefaultContextWindow,
+		systemPrompt:         defaultSystemPrompt,
+		retrievalConcurrency: 4,
+		metaPatterns:         compileMetaPatterns(DefaultMetaPatterns),
+		policyMessage:        DefaultPolicyMessage,
+		maxHistoryTurns:      DefaultMaxHistoryTurns,
+		maxConversations:     DefaultMaxConversations,
+		maxContinuations:     DefaultMaxContinuations,
+		tieBreakKeys:         DefaultTieBreakKeys,
+		lowConfidenceMessage: DefaultLowConfidenceMessage,
+		disclaimer:           DefaultLowConfidenceDisclaimer,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.conversations == nil {
+		s.conversations = NewMemoryStore(s.maxHistoryTurns*2, s.maxConversations)
+	}
+	if s.responseCache != nil {
+		s.promptVersionHash = s.promptVersion()
 	}
+	return s
 }
 
 // QueryResult represents the result of a RAG query.
 type QueryResult struct {
-	Answer   string
-	Sources  []Source
+	Answer  string
+	Sources []Source
+
+	// Usage is nil when no LLM call was made.
+	Usage *llm.Usage
+
+	// FinishReason is the LLM's reason for stopping, e.g. "stop" or
+	// "length" when the answer was cut off.
+	FinishReason string
+
+	// Set when structured answers are enabled and the answer parsed.
+	Overview string
+	Steps    []string
+
+	// Confidence (0-1) is derived from the retrieval scores of the sources;
+	// see confidence. It is 1 for answers that need no retrieval and 0 for
+	// fixed answers given when nothing relevant was found.
+	Confidence float32
+
+	// Debug is set when QueryOptions.Debug was requested and the answer
+	// came from the LLM.
+	Debug *DebugInfo
+
+	// Broadened is set when nothing relevant was found in the requested
+	// modules and the sources come from a search without them; see
+	// WithBroadenedRetrieval.
+	Broadened bool
+}
+
+// DebugInfo is the exact input the LLM saw for an answer.
+type DebugInfo struct {
+	Context  string        `json:"context"`
+	Messages []llm.Message `json:"messages"`
+}
+
+// debugInfo returns the debug info for a request, or nil unless requested.
+func debugInfo(opts QueryOptions, contextText string, messages []llm.Message) *DebugInfo {
+	if !opts.Debug {
+		return nil
+	}
+	return &DebugInfo{Context: contextText, Messages: messages}
 }
 
 // Source represents a retrieved document source.
@@ -40,172 +269,509 @@ type Source struct {
 	Module string
 	Topic  string
 	Score  float32
+
+	// Citation is the marker the answer uses to cite this source, set in
+	// citation mode.
+	Citation string
+
+	// MatchedVariation is the document's query variation closest to the
+	// question, useful for improving knowledge base phrasing.
+	MatchedVariation string
 }
 
-// Query performs a RAG query and returns the answer.
-func (s *Service) Query(ctx context.Context, userQuery string) (*QueryResult, error) {
-	// 1. Embed the query
-	queryEmbedding, err := s.embedder.EmbedSingle(ctx, userQuery)
-	if err != nil {
-		return nil, fmt.Errorf("embed query: %w", err)
+// Query performs a RAG query and returns the answer. Its usage includes
+// the LLM calls made for rewriting and reranking. With a response cache,
+// cached answers are returned without usage.
+func (s *Service) Query(ctx context.Context, userQuery string, opts QueryOptions) (*QueryResult, error) {
+	key, cacheable := s.responseKey(userQuery, opts)
+	if cacheable {
+		if result, ok := s.cachedResponse(key); ok {
+			s.recordQuery(userQuery)
+			return result, nil
+		}
+	}
+
+	ctx, tally := withUsageTally(ctx)
+	result, err := s.query(ctx, userQuery, opts)
+	if result != nil {
+		result.Usage = tally.addTo(result.Usage)
+	}
+	if err == nil && cacheable {
+		s.cacheResponse(key, result)
+	}
+	return result, err
+}
+
+func (s *Service) query(ctx context.Context, userQuery string, opts QueryOptions) (*QueryResult, error) {
+	if ok, err := s.allowed(ctx, "query", userQuery); err != nil {
+		return nil, err
+	} else if !ok {
+		return &QueryResult{Answer: s.policyMessage}, nil
 	}
+	s.recordQuery(userQuery)
 
-	// 2. Search for relevant documents
-	results, err := s.vectorClient.Search(ctx, queryEmbedding, s.topK)
+	// Questions about the bot itself don't need retrieval
+	if s.isMetaQuestion(userQuery) {
+		messages := s.buildMetaMessages(userQuery)
+		messages[0].Content += languageInstructions(opts.Language)
+		messages = s.withHistory(ctx, messages, opts.ConversationID)
+		result, err := s.complete(ctx, messages, opts)
+		if err != nil {
+			return nil, err
+		}
+		result.Confidence = 1
+		result.Debug = debugInfo(opts, "", messages)
+		result, err = s.moderateAnswer(ctx, result)
+		if err != nil {
+			return nil, err
+		}
+		s.remember(ctx, opts.ConversationID, userQuery, result.Answer)
+		return result, nil
+	}
+
+	// 1-2. Embed the query and search for relevant documents
+	results, broadened, err := s.retrieveBroadening(ctx, s.searchQueries(ctx, userQuery, opts), opts)
 	if err != nil {
-		return nil, fmt.Errorf("search: %w", err)
+		return nil, err
+	}
+
+	// Drop weak matches, keeping entries whose stored variations match the
+	// question; answer gracefully if nothing relevant is left
+	matches := s.variationMatches(ctx, userQuery, opts)
+	results = includeMatches(s.aboveThreshold(results, opts), matches)
+
+	// Refuse rather than guess when strict grounding has nothing to go on
+	strict := s.strictFor(opts, results)
+	if strict && s.unsupported(results) {
+		return &QueryResult{Answer: DefaultRefusalMessage}, nil
+	}
+	if len(results) == 0 && s.scoreThresholdFor(opts) > 0 {
+		return &QueryResult{Answer: NoInformationMessage}, nil
 	}
 
 	// 3. Build context from results
-	context_text := s.buildContext(results)
+	contextText, results := s.buildContext(results)
 
 	// 4. Build messages
-	messages := []llm.Message{
-		{
-			Role: "system",
-			Content: `You are the official Support Assistant for SyntraFlow - a comprehensive employee management system.
-
-## About SyntraFlow:
-SyntraFlow is an all-in-one Employee Management System (EMS) designed to streamline HR operations for organizations of all sizes. Key features include:
-- **Authentication & Access Control**: Secure sign-in, sign-up, password management, and role-based permissions
-- **Employee Management**: Complete employee lifecycle management including onboarding, profiles, and document handling
-- **Attendance & Rota Management**: Shift scheduling, clock in/out tracking, terminals, and live attendance monitoring
-- **Leave Management**: Leave requests, approvals, balances, WFH requests, and policy configuration
-- **Payroll & Salary**: Salary elements, payroll processing, and payslip generation
-- **Dashboard**: Real-time performance metrics, attendance insights, meetings, and company events
-- **Calendar**: Meeting scheduling, time insights, and team availability
-- **Policy Manager**: Configure leave policies, shift policies, WFH rules, and compensation structures
-- **Reports**: Time & attendance reports, lateness tracking, and live tracking
-
-## Your Role:
-- You are the primary support resource for SyntraFlow users
-- Help employees and administrators navigate the platform
-- Provide clear, step-by-step guidance for all features
-
-## Guidelines:
-1. For questions about what SyntraFlow is, use the About SyntraFlow section above
-2. For specific feature questions, use the provided context from the knowledge base
-3. Be concise but thorough - include all necessary steps
-4. Use numbered lists for step-by-step instructions
-5. If the context doesn't have specific details, say so politely and offer to help with something else
-6. Never make up features or steps
-7. Be professional, friendly, and helpful
-
-## Response Format:
-- Start with a direct answer
-- Follow with step-by-step instructions if applicable
-- End with a helpful tip if relevant`,
-		},
-		{
-			Role:    "user",
-			Content: fmt.Sprintf("Context from SyntraFlow Knowledge Base:\n%s\n\nUser Question: %s", context_text, userQuery),
-		},
+	messages := s.buildMessages(contextText, userQuery)
+	if strict {
+		messages[0].Content += strictGroundingInstructions
+	}
+	if s.structuredAnswers {
+		messages[0].Content += structuredAnswerInstructions
+	}
+	if s.citations {
+		messages[0].Content += citationInstructions
 	}
+	messages[0].Content += languageInstructions(opts.Language)
+	messages = s.withHistory(ctx, messages, opts.ConversationID)
 
 	// 5. Get LLM response
-	resp, err := s.llmClient.CreateChatCompletion(ctx, messages, 1024)
+	result, err := s.complete(ctx, messages, opts)
 	if err != nil {
-		return nil, fmt.Errorf("llm completion: %w", err)
-	}
-
-	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("no response from LLM")
+		return nil, err
 	}
 
 	// 6. Build result
 	sources := make([]Source, len(results))
+	queryWords := wordSet(userQuery)
 	for i, r := range results {
 		module, _ := r.Payload["module"].(string)
 		topic, _ := r.Payload["topic"].(string)
 		id, _ := r.Payload["id"].(string)
+		variation, _ := nearestVariation(queryWords, r.Payload)
 		sources[i] = Source{
-			ID:     id,
-			Module: module,
-			Topic:  topic,
-			Score:  r.Score,
+			ID:               id,
+			Module:           module,
+			Topic:            topic,
+			Score:            r.Score,
+			MatchedVariation: variation,
 		}
+		if s.citations {
+			sources[i].Citation = citationMarker(r)
+		}
+	}
+
+	result.Sources = sources
+	result.Broadened = broadened
+	result.Confidence = confidence(results, s.scoreThresholdFor(opts))
+	result.Debug = debugInfo(opts, contextText, messages)
+	if s.structuredAnswers {
+		if ans, ok := parseStructuredAnswer(result.Answer); ok {
+			result.Answer = ans.prose()
+			result.Overview = ans.Overview
+			result.Steps = ans.Steps
+		}
+	}
+	if strict && !s.grounded(result.Answer, contextText) {
+		result = &QueryResult{Answer: DefaultRefusalMessage, Usage: result.Usage, Debug: result.Debug}
+	}
+	if s.needsDisclaimer(result.Confidence) {
+		result.Answer = s.disclaimer + result.Answer
 	}
 
-	return &QueryResult{
-		Answer:  resp.Choices[0].Message.Content,
-		Sources: sources,
-	}, nil
+	result, err = s.moderateAnswer(ctx, result)
+	if err != nil {
+		return nil, err
+	}
+	s.remember(ctx, opts.ConversationID, userQuery, result.Answer)
+	return result, nil
 }
 
-// StreamQuery performs a RAG query with streaming response.
-func (s *Service) StreamQuery(ctx context.Context, userQuery string, writer io.Writer) error {
-	// 1. Embed the query
-	queryEmbedding, err := s.embedder.EmbedSingle(ctx, userQuery)
+// moderateAnswer replaces a blocked answer with the policy message.
+func (s *Service) moderateAnswer(ctx context.Context, result *QueryResult) (*QueryResult, error) {
+	ok, err := s.allowed(ctx, "answer", result.Answer)
 	if err != nil {
-		return fmt.Errorf("embed query: %w", err)
+		return nil, err
+	}
+	if !ok {
+		return &QueryResult{Answer: s.policyMessage, Usage: result.Usage}, nil
+	}
+	return result, nil
+}
+
+// StreamQuery performs a RAG query with streaming response. The returned
+// result's usage includes the LLM calls made for rewriting and reranking,
+// and is nil when no LLM call was made.
+func (s *Service) StreamQuery(ctx context.Context, userQuery string, opts QueryOptions, writer io.Writer) (*llm.StreamResult, error) {
+	ctx, tally := withUsageTally(ctx)
+	result, err := s.streamQuery(ctx, userQuery, opts, writer)
+	if result != nil {
+		result.Usage = tally.addTo(result.Usage)
+	}
+	return result, err
+}
+
+func (s *Service) streamQuery(ctx context.Context, userQuery string, opts QueryOptions, writer io.Writer) (*llm.StreamResult, error) {
+	if ok, err := s.allowed(ctx, "query", userQuery); err != nil {
+		return nil, err
+	} else if !ok {
+		return writeFixed(writer, s.policyMessage)
+	}
+	s.recordQuery(userQuery)
+
+	// Questions about the bot itself don't need retrieval
+	if s.isMetaQuestion(userQuery) {
+		messages := s.buildMetaMessages(userQuery)
+		messages[0].Content += languageInstructions(opts.Language)
+		messages = s.withHistory(ctx, messages, opts.ConversationID)
+		return s.streamAndRemember(ctx, messages, userQuery, opts, writer, "")
 	}
 
-	// 2. Search for relevant documents
-	results, err := s.vectorClient.Search(ctx, queryEmbedding, s.topK)
+	// 1-2. Embed the query and search for relevant documents
+	results, _, err := s.retrieveBroadening(ctx, s.searchQueries(ctx, userQuery, opts), opts)
 	if err != nil {
-		return fmt.Errorf("search: %w", err)
+		return nil, err
+	}
+
+	// Retrieval is cheap compared to generation, so decide whether to
+	// answer at all before committing to a stream. A matching query
+	// variation is confidence enough.
+	matches := s.variationMatches(ctx, userQuery, opts)
+	lowConfidence := len(matches) == 0 && s.belowConfidence(results)
+	results = includeMatches(s.aboveThreshold(results, opts), matches)
+
+	strict := s.strictFor(opts, results)
+	if strict && s.unsupported(results) {
+		return writeFixed(writer, DefaultRefusalMessage)
+	}
+	if lowConfidence {
+		return writeFixed(writer, s.lowConfidenceMessage)
+	}
+	if len(results) == 0 && s.scoreThresholdFor(opts) > 0 {
+		return writeFixed(writer, NoInformationMessage)
 	}
 
 	// 3. Build context from results
-	context_text := s.buildContext(results)
+	contextText, results := s.buildContext(results)
 
 	// 4. Build messages
-	messages := []llm.Message{
+	messages := s.buildMessages(contextText, userQuery)
+	if strict {
+		messages[0].Content += strictGroundingInstructions
+	}
+	if s.citations {
+		messages[0].Content += citationInstructions
+	}
+	messages[0].Content += languageInstructions(opts.Language)
+	messages = s.withHistory(ctx, messages, opts.ConversationID)
+
+	// 5. Stream LLM response, behind a disclaimer when confidence is low
+	if err := s.writeDisclaimer(writer, confidence(results, s.scoreThresholdFor(opts))); err != nil {
+		return nil, err
+	}
+	grounding := ""
+	if strict && s.groundingMinOverlap > 0 {
+		grounding = contextText
+	}
+	return s.streamAndRemember(ctx, messages, userQuery, opts, writer, grounding)
+}
+
+// streamAndRemember streams an answer and, once complete, records the turn in
+// the conversation history. With moderation on, or a grounding context to
+// check the answer against, the answer is buffered and only written once it
+// passed, or replaced by the refusal or policy message.
+func (s *Service) streamAndRemember(ctx context.Context, messages []llm.Message, userQuery string, opts QueryOptions, writer io.Writer, grounding string) (*llm.StreamResult, error) {
+	maxTokens := s.maxTokensFor(opts, messages)
+	if s.moderator != nil || grounding != "" {
+		var answer strings.Builder
+		result, err := s.llmClient.StreamChatCompletion(ctx, messages, maxTokens, s.completionOptions(opts), &answer)
+		if err != nil {
+			return result, err
+		}
+		text := answer.String()
+		if grounding != "" && !s.grounded(text, grounding) {
+			text = DefaultRefusalMessage
+		}
+		ok, err := s.allowed(ctx, "answer", text)
+		if err != nil {
+			return result, err
+		}
+		if !ok {
+			text = s.policyMessage
+		}
+		if _, err := io.WriteString(writer, text); err != nil {
+			return result, err
+		}
+		s.remember(ctx, opts.ConversationID, userQuery, text)
+		return result, nil
+	}
+	if opts.ConversationID == "" {
+		return s.llmClient.StreamChatCompletion(ctx, messages, maxTokens, s.completionOptions(opts), writer)
+	}
+
+	var answer strings.Builder
+	result, err := s.llmClient.StreamChatCompletion(ctx, messages, maxTokens, s.completionOptions(opts), io.MultiWriter(writer, &answer))
+	if err != nil {
+		return result, err
+	}
+	s.remember(ctx, opts.ConversationID, userQuery, answer.String())
+	return result, nil
+}
+
+// writeFixed writes a canned answer in place of a streamed one.
+func writeFixed(writer io.Writer, message string) (*llm.StreamResult, error) {
+	_, err := io.WriteString(writer, message)
+	return &llm.StreamResult{}, err
+}
+
+// buildMessages builds the chat messages for a knowledge base answer.
+func (s *Service) buildMessages(contextText, userQuery string) []llm.Message {
+	return []llm.Message{
 		{
-			Role: "system",
-			Content: `You are the official Support Assistant for SyntraFlow - a comprehensive employee management system.
-
-## About SyntraFlow:
-SyntraFlow is an all-in-one Employee Management System (EMS) designed to streamline HR operations for organizations of all sizes. Key features include:
-- **Authentication & Access Control**: Secure sign-in, sign-up, password management, and role-based permissions
-- **Employee Management**: Complete employee lifecycle management including onboarding, profiles, and document handling
-- **Attendance & Rota Management**: Shift scheduling, clock in/out tracking, terminals, and live attendance monitoring
-- **Leave Management**: Leave requests, approvals, balances, WFH requests, and policy configuration
-- **Payroll & Salary**: Salary elements, payroll processing, and payslip generation
-- **Dashboard**: Real-time performance metrics, attendance insights, meetings, and company events
-- **Calendar**: Meeting scheduling, time insights, and team availability
-- **Policy Manager**: Configure leave policies, shift policies, WFH rules, and compensation structures
-- **Reports**: Time & attendance reports, lateness tracking, and live tracking
-
-## Your Role:
-- You are the primary support resource for SyntraFlow users
-- Help employees and administrators navigate the platform
-- Provide clear, step-by-step guidance for all features
-
-## Guidelines:
-1. For questions about what SyntraFlow is, use the About SyntraFlow section above
-2. For specific feature questions, use the provided context from the knowledge base
-3. Be concise but thorough - include all necessary steps
-4. Use numbered lists for step-by-step instructions
-5. If the context doesn't have specific details, say so politely and offer to help with something else
-6. Never make up features or steps
-7. Be professional, friendly, and helpful
-
-## Response Format:
-- Start with a direct answer
-- Follow with step-by-step instructions if applicable
-- End with a helpful tip if relevant`,
+			Role:    "system",
+			Content: s.systemPrompt,
 		},
 		{
 			Role:    "user",
-			Content: fmt.Sprintf("Context from SyntraFlow Knowledge Base:\n%s\n\nUser Question: %s", context_text, userQuery),
+			Content: fmt.Sprintf("Context from SyntraFlow Knowledge Base:\n%s\n\nUser Question: %s", contextText, userQuery),
 		},
 	}
+}
+
+// retrieve embeds and searches each query variant concurrently, then merges
+// the results, keeping the best score per document.
+func (s *Service) retrieve(ctx context.Context, queries []string, opts QueryOptions) ([]vector.SearchResult, error) {
+	topK := s.topKFor(opts)
+	limit := s.fetchLimit(topK)
+	filter := filterFor(opts)
+	stores := s.storesFor(opts)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	resultSets := make([][]vector.SearchResult, len(queries))
+	errs := make([]error, len(queries))
+
+	sem := make(chan struct{}, s.retrievalConcurrency)
+	var wg sync.WaitGroup
+	for i, q := range queries {
+		wg.Add(1)
+		go func(i int, q string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			embedding, err := s.embedQuery(ctx, q)
+			if err != nil {
+				errs[i] = fmt.Errorf("embed query: %w", err)
+				// Other variants may still be answered from the cache
+				if !errors.Is(err, ErrEmbedderUnavailable) {
+					cancel()
+				}
+				return
+			}
+			results, err := searchStores(ctx, stores, limit, func(ctx context.Context, store vector.VectorStore) ([]vector.SearchResult, error) {
+				if store.Hybrid() {
+					return store.HybridSearch(ctx, embedding, vector.EncodeSparse(q), limit, filter, s.mmrEnabled)
+				}
+				if s.mmrEnabled {
+					return store.SearchWithVectors(ctx, embedding, limit, filter)
+				}
+				return store.SearchWithFilter(ctx, embedding, limit, filter)
+			})
+			if err != nil {
+				errs[i] = fmt.Errorf("search: %w", err)
+				cancel()
+				return
+			}
+			resultSets[i] = results
+		}(i, q)
+	}
+	wg.Wait()
+
+	var available [][]vector.SearchResult
+	var unavailable error
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			available = append(available, resultSets[i])
+		case errors.Is(err, ErrEmbedderUnavailable):
+			unavailable = err
+		default:
+			return nil, err
+		}
+	}
+	if len(available) == 0 {
+		return nil, unavailable
+	}
+	if unavailable != nil {
+		slog.Warn("embedder unavailable, retrieving with cached query variants only",
+			"cached", len(available), "variants", len(queries), "error", unavailable)
+	}
+
+	results := available[0]
+	if len(available) > 1 {
+		results = mergeResults(available, limit)
+	}
+	if s.rerankCandidates > 0 {
+		// The reranked order is final; sorting by score would undo it
+		return s.rerank(ctx, queries[0], results, topK, opts), nil
+	}
+	if s.mmrEnabled {
+		results = s.mmrSelect(results, topK)
+	}
+	s.sortResults(results)
+	return results, nil
+}
+
+// embedQuery embeds a query, using the embedding cache when configured.
+// Cache hits never reach the embedder, so they keep working while it is
+// down; other failures wrap ErrEmbedderUnavailable.
+func (s *Service) embedQuery(ctx context.Context, query string) ([]float32, error) {
+	key := normalizeQuery(query)
+	if s.embedCache != nil {
+		if embedding, ok := s.embedCache.Get(key); ok {
+			return embedding, nil
+		}
+	}
+
+	embedding, err := s.embedder.EmbedSingle(ctx, query)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %w", ErrEmbedderUnavailable, err)
+	}
+	if s.embedCache != nil {
+		s.embedCache.Put(key, embedding)
+	}
+	return embedding, nil
+}
+
+// normalizeQuery lowercases a query and collapses whitespace so trivially
+// different phrasings share a cache entry.
+func normalizeQuery(query string) string {
+	return strings.ToLower(strings.Join(strings.Fields(query), " "))
+}
+
+// mergeResults deduplicates results by ID, keeping the highest score, and
+// returns the best topK.
+func mergeResults(sets [][]vector.SearchResult, topK int) []vector.SearchResult {
+	best := make(map[string]vector.SearchResult)
+	for _, set := range sets {
+		for _, r := range set {
+			if existing, ok := best[r.ID]; !ok || r.Score > existing.Score {
+				best[r.ID] = r
+			}
+		}
+	}
+
+	merged := make([]vector.SearchResult, 0, len(best))
+	for _, r := range best {
+		merged = append(merged, r)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].Score != merged[j].Score {
+			return merged[i].Score > merged[j].Score
+		}
+		return merged[i].ID < merged[j].ID
+	})
 
-	// 5. Stream LLM response
-	return s.llmClient.StreamChatCompletion(ctx, messages, 1024, writer)
+	if len(merged) > topK {
+		merged = merged[:topK]
+	}
+	return merged
 }
 
-func (s *Service) buildContext(results []vector.SearchResult) string {
+// belowConfidence reports whether the confidence gate is enabled and the best
+// result scores under it.
+func (s *Service) belowConfidence(results []vector.SearchResult) bool {
+	if s.confidenceThreshold <= 0 {
+		return false
+	}
+	var best float32
+	for _, r := range results {
+		if r.Score > best {
+			best = r.Score
+		}
+	}
+	return best < s.confidenceThreshold
+}
+
+// buildContext formats results as prompt context within the context budget
+// and returns the results that made it in.
+func (s *Service) buildContext(results []vector.SearchResult) (string, []vector.SearchResult) {
 	var sb strings.Builder
+	used := results[:0:0]
+	remaining := s.contextBudget
 	for i, r := range results {
 		text, ok := r.Payload["text"].(string)
 		if !ok {
 			continue
 		}
-		sb.WriteString(fmt.Sprintf("--- Document %d (score: %.2f) ---\n", i+1, r.Score))
+		header := fmt.Sprintf("--- Document %d (score: %.2f) ---\n", i+1, r.Score)
+		if s.citations {
+			header = fmt.Sprintf("--- Document %d %s (score: %.2f) ---\n", i+1, citationMarker(r), r.Score)
+		}
+
+		if s.contextBudget > 0 {
+			cost := estimateTokens(header + text)
+			if cost > remaining {
+				// Truncate the document if a useful part of it still fits,
+				// otherwise drop it and everything scoring lower.
+				fit := remaining - estimateTokens(header)
+				if fit < minTruncatedDocTokens {
+					slog.Info("context budget reached, dropping documents",
+						"budget_tokens", s.contextBudget, "dropped", len(results)-i)
+					break
+				}
+				text = truncateRunes(text, fit*charsPerToken)
+				slog.Info("context budget reached, truncating document",
+					"budget_tokens", s.contextBudget, "id", r.ID, "dropped", len(results)-i-1)
+				sb.WriteString(header)
+				sb.WriteString(text)
+				sb.WriteString("\n\n")
+				used = append(used, r)
+				break
+			}
+			remaining -= cost
+		}
+
+		sb.WriteString(header)
 		sb.WriteString(text)
 		sb.WriteString("\n\n")
+		used = append(used, r)
 	}
-	return sb.String()
+	return sb.String(), used
 }
@@ -0,0 +1,161 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"go-bot/internal/llm"
+	"go-bot/internal/vector"
+)
+
+func TestStreamConfidenceGate(t *testing.T) {
+	const fallback = "I'm not sure about that one."
+
+	tests := []struct {
+		name         string
+		docs         []testDoc
+		threshold    float32
+		wantFallback bool
+	}{
+		{
+			name:         "nothing retrieved",
+			threshold:    0.5,
+			wantFallback: true,
+		},
+		{
+			name:         "best score below threshold",
+			docs:         []testDoc{{id: "a", module: "Payroll", text: "Payroll settings.", score: 0.3}, {id: "b", module: "Payroll", text: "Pay slips.", score: 0.4}},
+			threshold:    0.5,
+			wantFallback: true,
+		},
+		{
+			name:      "best score above threshold",
+			docs:      []testDoc{{id: "a", module: "Payroll", text: "Payroll settings.", score: 0.3}, {id: "b", module: "Payroll", text: "Run payroll from the dashboard.", score: 0.8}},
+			threshold: 0.5,
+		},
+		{
+			name: "gate disabled",
+			docs: []testDoc{{id: "a", module: "Payroll", text: "Payroll settings.", score: 0.3}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			completer := &llm.FakeCompleter{Answers: []string{"Run payroll from the dashboard."}}
+			svc := NewService(completer, &fakeEmbedder{}, newTestStore(t, tt.docs...),
+				WithConfidenceGate(tt.threshold, fallback))
+
+			var sb strings.Builder
+			if _, err := svc.StreamQuery(context.Background(), "how do I run payroll", QueryOptions{}, &sb); err != nil {
+				t.Fatal(err)
+			}
+
+			calls := len(completer.Calls())
+			if tt.wantFallback {
+				if sb.String() != fallback {
+					t.Errorf("streamed %q, want the fallback", sb.String())
+				}
+				if calls != 0 {
+					t.Errorf("StreamChatCompletion called %d times for a low-confidence query", calls)
+				}
+			} else if calls != 1 || sb.String() == fallback {
+				t.Errorf("streamed %q after %d calls, want a streamed answer", sb.String(), calls)
+			}
+		})
+	}
+}
+
+func TestConcurrentRetrieval(t *testing.T) {
+	// Each variant finds a different document best, so the merged result
+	// depends on every variant's search landing in its own slot
+	store := newTestStore(t,
+		testDoc{id: "a", module: "Payroll", text: "Run payroll from the dashboard.", score: 0.9},
+		testDoc{id: "b", module: "Payroll", text: "Pay runs are listed under History.", score: 0.1},
+		testDoc{id: "c", module: "Payroll", text: "Payroll settings.", score: 0.6},
+	)
+	vectors := map[string][]float32{
+		"how do I run payroll": queryVector,
+		"pay run history":      {0, 1, 0},
+		"payroll setup":        scored(0.6),
+	}
+	queries := []string{"how do I run payroll", "pay run history", "payroll setup"}
+
+	sequential := NewService(&llm.FakeCompleter{}, &fakeEmbedder{vectors: vectors}, store, WithRetrievalConcurrency(1))
+	want, err := sequential.retrieve(context.Background(), queries, QueryOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each document keeps its best score across the variants
+	if got := fmt.Sprint(resultScores(want)); got != "[c=1.000 b=0.995 a=0.900]" {
+		t.Fatalf("sequential retrieval = %s, want each document at its best variant's score", got)
+	}
+
+	tests := []struct {
+		name        string
+		concurrency int
+		delay       time.Duration
+	}{
+		{name: "two at a time", concurrency: 2},
+		{name: "all at once", concurrency: 4},
+		{name: "all at once with slow embeddings", concurrency: 4, delay: 5 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			embedder := &fakeEmbedder{vectors: vectors, delay: tt.delay}
+			svc := NewService(&llm.FakeCompleter{}, embedder, store, WithRetrievalConcurrency(tt.concurrency))
+
+			for i := 0; i < 20; i++ {
+				got, err := svc.retrieve(context.Background(), queries, QueryOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !sameResults(got, want) {
+					t.Fatalf("retrieval = %v, want %v", resultScores(got), resultScores(want))
+				}
+			}
+			if calls := embedder.calls.Load(); calls != 20*int32(len(queries)) {
+				t.Errorf("%d embeddings, want one per variant per query", calls)
+			}
+		})
+	}
+}
+
+func BenchmarkRetrieval(b *testing.B) {
+	store := &countingStore{MemoryStore: vector.NewMemoryStore(testDim)}
+	queries := []string{"how do I run payroll", "pay run", "payroll schedule", "salary processing"}
+
+	for _, concurrency := range []int{1, 4} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			embedder := &fakeEmbedder{delay: time.Millisecond}
+			svc := NewService(&llm.FakeCompleter{}, embedder, store, WithRetrievalConcurrency(concurrency))
+			for i := 0; i < b.N; i++ {
+				if _, err := svc.retrieve(context.Background(), queries, QueryOptions{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func sameResults(a, b []vector.SearchResult) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].ID != b[i].ID || a[i].Score != b[i].Score {
+			return false
+		}
+	}
+	return true
+}
+
+func resultScores(results []vector.SearchResult) []string {
+	out := make([]string, len(results))
+	for i, r := range results {
+		out[i] = fmt.Sprintf("%s=%.3f", r.ID, r.Score)
+	}
+	return out
+}
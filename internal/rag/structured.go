@@ -0,0 +1,65 @@
+package rag
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// structuredAnswerInstructions is appended to the system prompt when
+// structured answers are enabled.
+const structuredAnswerInstructions = `
+
+## Output Format:
+Respond with a single JSON object and nothing else, in this shape:
+{"overview": "<short conceptual explanation>", "steps": ["<step 1>", "<step 2>"]}
+Use an empty "steps" array when the question has no procedure.`
+
+// structuredAnswer is the JSON shape requested from the LLM.
+type structuredAnswer struct {
+	Overview string   `json:"overview"`
+	Steps    []string `json:"steps"`
+}
+
+// WithStructuredAnswers asks the LLM for separate overview and steps sections
+// in non-streaming answers. Answers that fail to parse fall back to prose.
+func WithStructuredAnswers(enabled bool) Option {
+	return func(s *Service) {
+		s.structuredAnswers = enabled
+	}
+}
+
+// parseStructuredAnswer extracts the overview and steps from an LLM answer,
+// tolerating surrounding code fences or text.
+func parseStructuredAnswer(content string) (structuredAnswer, bool) {
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start < 0 || end <= start {
+		return structuredAnswer{}, false
+	}
+
+	var ans structuredAnswer
+	if err := json.Unmarshal([]byte(content[start:end+1]), &ans); err != nil {
+		return structuredAnswer{}, false
+	}
+	if strings.TrimSpace(ans.Overview) == "" {
+		return structuredAnswer{}, false
+	}
+	return ans, true
+}
+
+// prose renders a structured answer as plain text for clients that only read
+// the answer field.
+func (a structuredAnswer) prose() string {
+	var sb strings.Builder
+	sb.WriteString(a.Overview)
+	for i, step := range a.Steps {
+		if i == 0 {
+			sb.WriteString("\n\n")
+		} else {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(fmt.Sprintf("%d. %s", i+1, step))
+	}
+	return sb.String()
+}
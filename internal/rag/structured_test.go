@@ -0,0 +1,91 @@
+package rag
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go-bot/internal/llm"
+)
+
+func TestParseStructuredAnswer(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    structuredAnswer
+		wantOK  bool
+	}{
+		{
+			name:    "plain JSON",
+			content: `{"overview": "Payroll pays employees.", "steps": ["Open Payroll", "Click Run"]}`,
+			want:    structuredAnswer{Overview: "Payroll pays employees.", Steps: []string{"Open Payroll", "Click Run"}},
+			wantOK:  true,
+		},
+		{
+			name:    "in a code fence",
+			content: "```json\n{\"overview\": \"Payroll pays employees.\", \"steps\": []}\n```",
+			want:    structuredAnswer{Overview: "Payroll pays employees.", Steps: []string{}},
+			wantOK:  true,
+		},
+		{
+			name:    "surrounded by text",
+			content: `Here you go: {"overview": "Payroll pays employees."} Hope that helps!`,
+			want:    structuredAnswer{Overview: "Payroll pays employees."},
+			wantOK:  true,
+		},
+		{name: "prose", content: "Open Payroll and click Run."},
+		{name: "malformed JSON", content: `{"overview": "Payroll pays employees.", "steps": [}`},
+		{name: "missing overview", content: `{"steps": ["Open Payroll"]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseStructuredAnswer(tt.content)
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseStructuredAnswer = %+v, %v; want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestStructuredAnswers(t *testing.T) {
+	tests := []struct {
+		name         string
+		reply        string
+		wantAnswer   string
+		wantOverview string
+		wantSteps    []string
+	}{
+		{
+			name:         "structured",
+			reply:        `{"overview": "Payroll pays employees.", "steps": ["Open Payroll", "Click Run"]}`,
+			wantAnswer:   "Payroll pays employees.\n\n1. Open Payroll\n2. Click Run",
+			wantOverview: "Payroll pays employees.",
+			wantSteps:    []string{"Open Payroll", "Click Run"},
+		},
+		{
+			name:       "prose fallback",
+			reply:      "Open Payroll and click Run.",
+			wantAnswer: "Open Payroll and click Run.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			completer := &llm.FakeCompleter{Answers: []string{tt.reply}}
+			store := newTestStore(t, testDoc{id: "p", module: "Payroll", text: "Run payroll from the dashboard.", score: 0.9})
+			svc := NewService(completer, &fakeEmbedder{}, store, WithStructuredAnswers(true))
+
+			result, err := svc.Query(context.Background(), "how do I run payroll", QueryOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Answer != tt.wantAnswer || result.Overview != tt.wantOverview || !reflect.DeepEqual(result.Steps, tt.wantSteps) {
+				t.Errorf("result = %q, %q, %v; want %q, %q, %v",
+					result.Answer, result.Overview, result.Steps, tt.wantAnswer, tt.wantOverview, tt.wantSteps)
+			}
+			if prompt := completer.Calls()[0][0].Content; !strings.Contains(prompt, structuredAnswerInstructions) {
+				t.Error("system prompt doesn't request a structured answer")
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package rag
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"go-bot/internal/llm"
+)
+
+// charsPerToken approximates how many characters make up one token for
+// English text with Llama-family tokenizers.
+const charsPerToken = 4
+
+// messageOverheadTokens approximates the per-message formatting overhead.
+const messageOverheadTokens = 4
+
+// estimateTokens returns a rough token count for text.
+func estimateTokens(text string) int {
+	n := utf8.RuneCountInString(text)
+	return (n + charsPerToken - 1) / charsPerToken
+}
+
+// estimateMessageTokens returns a rough token count for a chat prompt.
+func estimateMessageTokens(messages []llm.Message) int {
+	total := 0
+	for _, m := range messages {
+		total += estimateTokens(m.Content) + messageOverheadTokens
+	}
+	return total
+}
+
+// minTruncatedDocTokens is the smallest useful slice of a document kept when
+// truncating it to fit the context budget.
+const minTruncatedDocTokens = 100
+
+// WithContextBudget caps the retrieved context at roughly tokens tokens.
+// Lower-scored documents are truncated or dropped to fit; zero disables the
+// budget.
+func WithContextBudget(tokens int) Option {
+	return func(s *Service) {
+		if tokens >= 0 {
+			s.contextBudget = tokens
+		}
+	}
+}
+
+// truncateRunes cuts text to at most n runes.
+func truncateRunes(text string, n int) string {
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	return string([]rune(text)[:n])
+}
+
+// TokenEstimate breaks down the estimated prompt size for a query.
+type TokenEstimate struct {
+	SystemTokens   int
+	ContextTokens  int
+	QuestionTokens int
+	TotalTokens    int
+}
+
+// EstimatePromptTokens runs retrieval and builds the prompt for userQuery
+// exactly as Query would, returning its estimated token count without
+// calling the LLM.
+func (s *Service) EstimatePromptTokens(ctx context.Context, userQuery string, opts QueryOptions) (*TokenEstimate, error) {
+	if s.isMetaQuestion(userQuery) {
+		messages := s.buildMetaMessages(userQuery)
+		messages[0].Content += languageInstructions(opts.Language)
+		return &TokenEstimate{
+			SystemTokens:   estimateTokens(messages[0].Content),
+			QuestionTokens: estimateTokens(userQuery),
+			TotalTokens:    estimateMessageTokens(messages),
+		}, nil
+	}
+
+	results, _, err := s.retrieveBroadening(ctx, []string{userQuery}, opts)
+	if err != nil {
+		return nil, err
+	}
+	results = includeMatches(s.aboveThreshold(results, opts), s.variationMatches(ctx, userQuery, opts))
+
+	contextText, results := s.buildContext(results)
+	messages := s.buildMessages(contextText, userQuery)
+	if s.strictFor(opts, results) {
+		messages[0].Content += strictGroundingInstructions
+	}
+	if s.structuredAnswers {
+		messages[0].Content += structuredAnswerInstructions
+	}
+	if s.citations {
+		messages[0].Content += citationInstructions
+	}
+	messages[0].Content += languageInstructions(opts.Language)
+
+	return &TokenEstimate{
+		SystemTokens:   estimateTokens(messages[0].Content),
+		ContextTokens:  estimateTokens(contextText),
+		QuestionTokens: estimateTokens(userQuery),
+		TotalTokens:    estimateMessageTokens(messages),
+	}, nil
+}
@@ -0,0 +1,85 @@
+package rag
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go-bot/internal/llm"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{text: "", want: 0},
+		{text: "abc", want: 1},
+		{text: "abcd", want: 1},
+		{text: "abcde", want: 2},
+		{text: strings.Repeat("é", 8), want: 2},
+	}
+	for _, tt := range tests {
+		if got := estimateTokens(tt.text); got != tt.want {
+			t.Errorf("estimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestEstimatePromptTokens(t *testing.T) {
+	const prompt = "You answer payroll questions."
+	const query = "how do I run payroll"
+	doc := testDoc{id: "p", module: "Payroll", text: "Run payroll from the dashboard.", score: 0.9}
+	system := prompt + languageInstructions("")
+
+	tests := []struct {
+		name        string
+		docs        []testDoc
+		query       string
+		wantContext string
+	}{
+		{
+			name:        "one document",
+			docs:        []testDoc{doc},
+			query:       query,
+			wantContext: "--- Document 1 (score: 0.90) ---\nRun payroll from the dashboard.\n\n",
+		},
+		{
+			name:  "no documents",
+			query: query,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			completer := &llm.FakeCompleter{Answers: []string{"Open the dashboard."}}
+			svc := NewService(completer, &fakeEmbedder{}, newTestStore(t, tt.docs...), WithSystemPrompt(prompt))
+
+			got, err := svc.EstimatePromptTokens(context.Background(), tt.query, QueryOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			user := "Context from SyntraFlow Knowledge Base:\n" + tt.wantContext + "\n\nUser Question: " + tt.query
+			want := TokenEstimate{
+				SystemTokens:   estimateTokens(system),
+				ContextTokens:  estimateTokens(tt.wantContext),
+				QuestionTokens: 5,
+				TotalTokens:    estimateTokens(system) + estimateTokens(user) + 2*messageOverheadTokens,
+			}
+			if *got != want {
+				t.Errorf("estimate = %+v, want %+v", *got, want)
+			}
+			if tt.wantContext != "" && got.ContextTokens != 17 {
+				t.Errorf("ContextTokens = %d, want 17 for 66 characters", got.ContextTokens)
+			}
+
+			// The estimate covers the prompt Query actually sends
+			if _, err := svc.Query(context.Background(), tt.query, QueryOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			if sent := estimateMessageTokens(completer.Calls()[0]); sent != got.TotalTokens {
+				t.Errorf("TotalTokens = %d, but the prompt sent is %d tokens", got.TotalTokens, sent)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package rag
+
+import (
+	"context"
+	"sync"
+
+	"go-bot/internal/llm"
+)
+
+// usageTally sums the token usage of the LLM calls a query makes besides
+// the answer itself, like query rewriting and reranking, so it can be
+// reported with the answer's.
+type usageTally struct {
+	mu    sync.Mutex
+	usage llm.Usage
+}
+
+type usageTallyKey struct{}
+
+// withUsageTally attaches a fresh usageTally to ctx.
+func withUsageTally(ctx context.Context) (context.Context, *usageTally) {
+	tally := &usageTally{}
+	return context.WithValue(ctx, usageTallyKey{}, tally), tally
+}
+
+// recordUsage adds usage to the query's tally, if ctx has one.
+func recordUsage(ctx context.Context, usage llm.Usage) {
+	tally, _ := ctx.Value(usageTallyKey{}).(*usageTally)
+	if tally == nil {
+		return
+	}
+	tally.mu.Lock()
+	defer tally.mu.Unlock()
+	tally.usage.PromptTokens += usage.PromptTokens
+	tally.usage.CompletionTokens += usage.CompletionTokens
+	tally.usage.TotalTokens += usage.TotalTokens
+}
+
+// addTo returns usage plus the tally. It is nil when both are empty, so
+// answers that made no LLM call keep reporting no usage.
+func (t *usageTally) addTo(usage *llm.Usage) *llm.Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.usage == (llm.Usage{}) {
+		return usage
+	}
+	total := t.usage
+	if usage != nil {
+		total.PromptTokens += usage.PromptTokens
+		total.CompletionTokens += usage.CompletionTokens
+		total.TotalTokens += usage.TotalTokens
+	}
+	return &total
+}
@@ -0,0 +1,78 @@
+package rag
+
+import (
+	"context"
+	"log/slog"
+
+	"go-bot/internal/vector"
+)
+
+// variationMatchLimit bounds the candidates fetched by the variation match
+// stage.
+const variationMatchLimit = 20
+
+// WithVariationMatch makes retrieval always include entries with a stored
+// query variation whose word overlap with the question is at least
+// minOverlap (0-1), even when vector search misses them or scores them
+// below the threshold. Zero disables it. Candidates are the entries
+// closest to the question sharing at least one word with it through
+// query_variations, which should have a "text" payload index.
+func WithVariationMatch(minOverlap float64) Option {
+	return func(s *Service) {
+		s.variationMatchThreshold = minOverlap
+	}
+}
+
+// variationMatches returns entries whose query variations closely match
+// userQuery, best vector score first. Failures are logged and yield no
+// matches, since the stage only adds to vector retrieval.
+func (s *Service) variationMatches(ctx context.Context, userQuery string, opts QueryOptions) []vector.SearchResult {
+	if s.variationMatchThreshold <= 0 {
+		return nil
+	}
+
+	embedding, err := s.embedQuery(ctx, userQuery)
+	if err != nil {
+		slog.Warn("variation match: embed query", "error", err)
+		return nil
+	}
+	filter := vector.AnyWordFilter("query_variations", userQuery, scopeConditions(opts)...)
+	candidates, err := searchStores(ctx, s.storesFor(opts), variationMatchLimit, func(ctx context.Context, store vector.VectorStore) ([]vector.SearchResult, error) {
+		return store.SearchWithFilter(ctx, embedding, variationMatchLimit, filter)
+	})
+	if err != nil {
+		slog.Warn("variation match: search", "error", err)
+		return nil
+	}
+
+	queryWords := wordSet(userQuery)
+	matches := candidates[:0:0]
+	for _, r := range candidates {
+		if _, overlap := nearestVariation(queryWords, r.Payload); overlap >= s.variationMatchThreshold {
+			matches = append(matches, r)
+		}
+	}
+	if len(matches) > 0 {
+		slog.Debug("variation match", "matches", len(matches))
+	}
+	return matches
+}
+
+// includeMatches puts variation matches missing from results at the front,
+// so they survive the context budget.
+func includeMatches(results, matches []vector.SearchResult) []vector.SearchResult {
+	if len(matches) == 0 {
+		return results
+	}
+	seen := make(map[string]bool, len(results))
+	for _, r := range results {
+		seen[r.ID] = true
+	}
+	var missing []vector.SearchResult
+	for _, m := range matches {
+		if !seen[m.ID] {
+			missing = append(missing, m)
+		}
+	}
+	return append(missing, results...)
+}
@@ -0,0 +1,45 @@
+package rag
+
+import (
+	"context"
+	"log/slog"
+
+	"go-bot/internal/cache"
+)
+
+// WithQueryTracker counts the questions the service answers in t, so the
+// most frequent ones can be used to warm the embedding cache.
+func WithQueryTracker(t *cache.QueryTracker) Option {
+	return func(s *Service) {
+		s.queryTracker = t
+	}
+}
+
+// recordQuery counts a question in the query tracker, if any.
+func (s *Service) recordQuery(userQuery string) {
+	if s.queryTracker != nil {
+		s.queryTracker.Record(normalizeQuery(userQuery))
+	}
+}
+
+// WarmEmbeddings embeds the n most frequent tracked questions into the
+// embedding cache and returns how many it embedded, skipping those already
+// cached. It does nothing without both a tracker and an embedding cache,
+// and stops at the first failure since the embedder is then likely down.
+func (s *Service) WarmEmbeddings(ctx context.Context, n int) (int, error) {
+	if s.queryTracker == nil || s.embedCache == nil || n <= 0 {
+		return 0, nil
+	}
+	warmed := 0
+	for _, qc := range s.queryTracker.Top(n) {
+		if s.embedCache.Contains(qc.Query) {
+			continue
+		}
+		if _, err := s.embedQuery(ctx, qc.Query); err != nil {
+			return warmed, err
+		}
+		warmed++
+	}
+	slog.Info("warmed embedding cache from frequent queries", "queries", warmed)
+	return warmed, nil
+}
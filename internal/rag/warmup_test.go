@@ -0,0 +1,80 @@
+package rag
+
+import (
+	"context"
+	"testing"
+
+	"go-bot/internal/cache"
+	"go-bot/internal/llm"
+)
+
+func TestWarmEmbeddingsUsesFrequentQueries(t *testing.T) {
+	store := newTestStore(t, testDoc{id: "p", module: "Payroll", text: "Run payroll from the dashboard.", score: 0.9})
+	tracker := cache.NewQueryTracker(10)
+
+	// Record traffic through the service, as the server does
+	asked := NewService(&llm.FakeCompleter{Answers: []string{"ok"}}, &fakeEmbedder{}, store, WithQueryTracker(tracker))
+	traffic := map[string]int{
+		"How do I run payroll?":    4,
+		"how do i  RUN payroll?":   1, // same question once normalized
+		"How do I request leave?":  3,
+		"Where is the login page?": 2,
+		"What is a payslip?":       1,
+	}
+	for query, n := range traffic {
+		for i := 0; i < n; i++ {
+			if _, err := asked.Query(context.Background(), query, QueryOptions{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name      string
+		n         int
+		preloaded []string
+		want      []string
+		wantCalls int32
+	}{
+		{
+			name:      "top two",
+			n:         2,
+			want:      []string{"how do i run payroll?", "how do i request leave?"},
+			wantCalls: 2,
+		},
+		{
+			name:      "top three with one cached",
+			n:         3,
+			preloaded: []string{"how do i request leave?"},
+			want:      []string{"how do i run payroll?", "how do i request leave?", "where is the login page?"},
+			wantCalls: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			embedCache := cache.NewEmbeddingCache(10)
+			for _, q := range tt.preloaded {
+				embedCache.Put(q, queryVector)
+			}
+			embedder := &fakeEmbedder{}
+			svc := NewService(&llm.FakeCompleter{}, embedder, store,
+				WithQueryTracker(tracker), WithEmbeddingCache(embedCache))
+
+			warmed, err := svc.WarmEmbeddings(context.Background(), tt.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if int32(warmed) != tt.wantCalls || embedder.calls.Load() != tt.wantCalls {
+				t.Errorf("warmed %d with %d embedder calls, want %d", warmed, embedder.calls.Load(), tt.wantCalls)
+			}
+			for _, q := range tt.want {
+				if !embedCache.Contains(q) {
+					t.Errorf("%q was not warmed", q)
+				}
+			}
+			if st := embedCache.CacheStats(); st.Entries != len(tt.want) {
+				t.Errorf("cache holds %d queries, want %d", st.Entries, len(tt.want))
+			}
+		})
+	}
+}
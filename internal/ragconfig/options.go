@@ -0,0 +1,95 @@
+// Package ragconfig builds the RAG service options from the configuration,
+// so the server and the query tool answer with the same settings.
+package ragconfig
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"go-bot/config"
+	"go-bot/internal/cache"
+	"go-bot/internal/llm"
+	"go-bot/internal/rag"
+	"go-bot/internal/vector"
+)
+
+// Options returns the RAG service options described by cfg. Module
+// collections are opened on vectorClient, and the embedding and response
+// caches are registered with caches unless it is nil. Conversation storage
+// is left to the caller, as is overriding the deterministic seed.
+func Options(cfg *config.Config, vectorClient *vector.Client, caches *cache.Registry) ([]rag.Option, error) {
+	opts := []rag.Option{
+		rag.WithConfidenceGate(cfg.StreamConfidenceThreshold, cfg.LowConfidenceMessage),
+		rag.WithConfidenceDisclaimer(cfg.ConfidenceDisclaimerThreshold, cfg.ConfidenceDisclaimer),
+		rag.WithRetrievalConcurrency(cfg.RetrievalConcurrency),
+		rag.WithStructuredAnswers(cfg.StructuredAnswers),
+		rag.WithCitations(cfg.Citations),
+		rag.WithStrictGrounding(cfg.StrictGrounding, cfg.StrictGroundingModules, cfg.StrictGroundingMinScore),
+		rag.WithGroundingCheck(cfg.StrictGroundingMinOverlap),
+		rag.WithMaxHistory(cfg.ConversationMaxTurns),
+		rag.WithMaxConversations(cfg.ConversationMax),
+		rag.WithHistoryAnswerLimit(cfg.ConversationAnswerMaxChars),
+		rag.WithScoreThreshold(cfg.ScoreThreshold),
+		rag.WithVariationMatch(cfg.VariationMatchThreshold),
+		rag.WithBroadenedRetrieval(cfg.BroadenRetrieval),
+		rag.WithTieBreak(cfg.TieBreakEpsilon, cfg.TieBreakKeys),
+		rag.WithContextBudget(cfg.ContextBudgetTokens),
+		rag.WithContinuations(cfg.LLMMaxContinuations),
+		rag.WithMaxTokens(cfg.LLMMaxTokens),
+		rag.WithContextWindow(cfg.LLMContextWindow),
+	}
+	if !cfg.MetaDetection {
+		opts = append(opts, rag.WithMetaPatterns(nil))
+	} else if len(cfg.MetaPatterns) > 0 {
+		opts = append(opts, rag.WithMetaPatterns(cfg.MetaPatterns))
+	}
+	if cfg.QueryRewriting {
+		opts = append(opts, rag.WithQueryRewriting(cfg.QueryVariants))
+	}
+	if cfg.MMREnabled {
+		opts = append(opts, rag.WithMMR(cfg.MMRLambda, cfg.MMRFetchK))
+	}
+	if len(cfg.AnswerCleanupRules) > 0 || len(cfg.AnswerCleanupPatterns) > 0 {
+		opts = append(opts, rag.WithAnswerCleanup(cfg.AnswerCleanupRules, cfg.AnswerCleanupPatterns))
+	}
+	if len(cfg.LLMStopSequences) > 0 {
+		opts = append(opts, rag.WithStopSequences(cfg.LLMStopSequences))
+	}
+	if cfg.Rerank {
+		opts = append(opts, rag.WithRerank(cfg.RerankCandidates))
+	}
+	if len(cfg.ModuleCollections) > 0 {
+		opts = append(opts, rag.WithModuleStores(vectorClient.ForModules(cfg.ModuleCollections)))
+	}
+	if cfg.EmbedCacheSize > 0 {
+		embedCache := cache.NewEmbeddingCache(cfg.EmbedCacheSize)
+		if caches != nil {
+			caches.Register(embedCache)
+		}
+		opts = append(opts, rag.WithEmbeddingCache(embedCache))
+	}
+	if cfg.ResponseCacheSize > 0 {
+		responseCache := cache.NewResponseCache(cfg.ResponseCacheSize, cfg.ResponseCacheTTL)
+		if caches != nil {
+			caches.Register(responseCache)
+		}
+		opts = append(opts, rag.WithResponseCache(responseCache, cfg.GroqModel))
+	}
+	if cfg.DeterministicSeed >= 0 {
+		opts = append(opts, rag.WithDeterministic(cfg.DeterministicSeed))
+	}
+	if cfg.SystemPromptFile != "" {
+		prompt, err := os.ReadFile(cfg.SystemPromptFile)
+		if err != nil {
+			return nil, fmt.Errorf("read system prompt: %w", err)
+		}
+		log.Printf("Loaded system prompt from %s", cfg.SystemPromptFile)
+		opts = append(opts, rag.WithSystemPrompt(string(prompt)))
+	}
+	if cfg.ModerationURL != "" {
+		moderator := llm.NewModerationClient(cfg.ModerationURL, cfg.ModerationAPIKey)
+		opts = append(opts, rag.WithModeration(moderator, cfg.ModerationMessage))
+	}
+	return opts, nil
+}
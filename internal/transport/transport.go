@@ -0,0 +1,28 @@
+// Package transport builds the HTTP transport shared by the Groq, Qdrant and
+// embedding clients, so they pool connections under one set of limits.
+package transport
+
+import (
+	"net/http"
+	"time"
+)
+
+// New returns a copy of http.DefaultTransport with the given connection
+// pool limits. Zero values keep the defaults, except that the per-host
+// idle limit is raised to maxIdleConns: Go's default of 2 makes concurrent
+// requests to the same host open and close connections constantly.
+func New(maxIdleConns, maxIdleConnsPerHost int, idleConnTimeout time.Duration) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if maxIdleConns > 0 {
+		t.MaxIdleConns = maxIdleConns
+	}
+	if maxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	} else {
+		t.MaxIdleConnsPerHost = t.MaxIdleConns
+	}
+	if idleConnTimeout > 0 {
+		t.IdleConnTimeout = idleConnTimeout
+	}
+	return t
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"hash/fnv"
 	"io"
@@ -18,12 +19,20 @@ type Client struct {
 	httpClient     *http.Client
 	collectionName string
 	vectorSize     int
+
+	useTLS    bool
+	apiKey    string
+	hybrid    bool
+	transport http.RoundTripper
+
+	payloadIndexes map[string]string
 }
 
 // Point represents a vector point to upsert.
 type Point struct {
 	ID      string
 	Vector  []float32
+	Sparse  *SparseVector // only stored in hybrid mode
 	Payload map[string]interface{}
 }
 
@@ -32,26 +41,42 @@ type SearchResult struct {
 	ID      string
 	Score   float32
 	Payload map[string]interface{}
+	Vector  []float32 // only set by SearchWithVectors
 }
 
-// NewClient creates a new Qdrant HTTP client.
-func NewClient(host string, port int, collectionName string, vectorSize int) (*Client, error) {
-	// Use HTTP port (6333) instead of gRPC (6334)
-	baseURL := fmt.Sprintf("http://%s:%d", host, port-1) // 6334 -> 6333
-
-	log.Printf("Connecting to Qdrant at %s", baseURL)
-
-	return &Client{
-		baseURL: baseURL,
-		httpClient: &http.Client{
-			Timeout: 60 * time.Second,
-		},
+// NewClient creates a new Qdrant HTTP client. httpPort is the REST API
+// port (6333 by default), not the gRPC port.
+func NewClient(host string, httpPort int, collectionName string, vectorSize int, opts ...ClientOption) (*Client, error) {
+	c := &Client{
 		collectionName: collectionName,
 		vectorSize:     vectorSize,
-	}, nil
+		payloadIndexes: DefaultPayloadIndexes,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	scheme := "http"
+	if c.useTLS {
+		scheme = "https"
+	}
+	c.baseURL = fmt.Sprintf("%s://%s:%d", scheme, host, httpPort)
+	base := c.transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	c.httpClient = &http.Client{
+		Timeout:   60 * time.Second,
+		Transport: &qdrantTransport{base: base, apiKey: c.apiKey},
+	}
+
+	log.Printf("Connecting to Qdrant at %s", c.baseURL)
+
+	return c, nil
 }
 
-// EnsureCollection creates the collection if it doesn't exist.
+// EnsureCollection creates the collection if it doesn't exist, and the
+// configured payload indexes if they don't.
 func (c *Client) EnsureCollection(ctx context.Context) error {
 	// Check if collection exists by getting its info
 	resp, err := c.httpClient.Get(fmt.Sprintf("%s/collections/%s", c.baseURL, c.collectionName))
@@ -63,7 +88,7 @@ func (c *Client) EnsureCollection(ctx context.Context) error {
 	// If collection exists (200 OK), we're done
 	if resp.StatusCode == http.StatusOK {
 		log.Printf("Collection %s already exists", c.collectionName)
-		return nil
+		return c.ensurePayloadIndexes(ctx)
 	}
 
 	// If 404, collection doesn't exist - create it
@@ -78,14 +103,7 @@ func (c *Client) EnsureCollection(ctx context.Context) error {
 }
 
 func (c *Client) createCollection(ctx context.Context) error {
-	createReq := map[string]interface{}{
-		"vectors": map[string]interface{}{
-			"size":     c.vectorSize,
-			"distance": "Cosine",
-		},
-	}
-
-	body, _ := json.Marshal(createReq)
+	body, _ := json.Marshal(c.collectionConfig())
 	req, err := http.NewRequestWithContext(ctx, http.MethodPut,
 		fmt.Sprintf("%s/collections/%s", c.baseURL, c.collectionName),
 		bytes.NewReader(body))
@@ -103,15 +121,43 @@ func (c *Client) createCollection(ctx context.Context) error {
 	// 200 OK or 409 Conflict (already exists) are both acceptable
 	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusConflict {
 		log.Printf("Collection %s ready", c.collectionName)
-		return nil
+		return c.ensurePayloadIndexes(ctx)
 	}
 
 	respBody, _ := io.ReadAll(resp.Body)
 	return fmt.Errorf("create collection failed (status %d): %s", resp.StatusCode, string(respBody))
 }
 
-// stringToNumericID converts a string ID to a numeric ID using FNV hash.
-func stringToNumericID(s string) uint64 {
+// RecreateCollection deletes the collection, with all its points, and
+// creates it again with the client's vector size. Use it after changing the
+// embedding model, when the existing collection has the wrong dimension.
+func (c *Client) RecreateCollection(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete,
+		fmt.Sprintf("%s/collections/%s", c.baseURL, c.collectionName), nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("delete collection: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 404 means there was nothing to delete
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("delete collection failed (status %d): %s", resp.StatusCode, string(respBody))
+	}
+	log.Printf("Deleted collection %s", c.collectionName)
+
+	return c.createCollection(ctx)
+}
+
+// NumericID converts a string ID to the numeric Qdrant point ID using a
+// 64-bit FNV hash. Distinct IDs can collide, in which case the later point
+// overwrites the earlier one.
+func NumericID(s string) uint64 {
 	h := fnv.New64a()
 	h.Write([]byte(s))
 	return h.Sum64()
@@ -123,8 +169,8 @@ func (c *Client) UpsertPoints(ctx context.Context, points []Point) error {
 
 	for i, p := range points {
 		qdrantPoints[i] = map[string]interface{}{
-			"id":      stringToNumericID(p.ID),
-			"vector":  p.Vector,
+			"id":      NumericID(p.ID),
+			"vector":  c.pointVectors(p),
 			"payload": p.Payload,
 		}
 	}
@@ -157,14 +203,120 @@ func (c *Client) UpsertPoints(ctx context.Context, points []Point) error {
 	return nil
 }
 
+// DeletePoints removes the points with the given string IDs.
+func (c *Client) DeletePoints(ctx context.Context, ids []string) error {
+	numericIDs := make([]uint64, len(ids))
+	for i, id := range ids {
+		numericIDs[i] = NumericID(id)
+	}
+	return c.deletePoints(ctx, map[string]interface{}{"points": numericIDs})
+}
+
+// DeleteByFilter removes all points matching a Qdrant payload filter, e.g.
+// every point of a module.
+func (c *Client) DeleteByFilter(ctx context.Context, filter map[string]interface{}) error {
+	return c.deletePoints(ctx, map[string]interface{}{"filter": filter})
+}
+
+func (c *Client) deletePoints(ctx context.Context, deleteReq map[string]interface{}) error {
+	body, _ := json.Marshal(deleteReq)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		fmt.Sprintf("%s/collections/%s/points/delete?wait=true", c.baseURL, c.collectionName),
+		bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("delete points: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("delete points: collection %s does not exist", c.collectionName)
+	}
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("delete failed (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
+
+// Count returns the number of points matching a payload filter. A nil
+// filter counts the whole collection.
+func (c *Client) Count(ctx context.Context, filter map[string]interface{}) (int, error) {
+	countReq := map[string]interface{}{
+		"exact": true,
+	}
+	if filter != nil {
+		countReq["filter"] = filter
+	}
+
+	body, _ := json.Marshal(countReq)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		fmt.Sprintf("%s/collections/%s/points/count", c.baseURL, c.collectionName),
+		bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("count points: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return 0, fmt.Errorf("count failed (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	var countResp struct {
+		Result struct {
+			Count int `json:"count"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&countResp); err != nil {
+		return 0, fmt.Errorf("decode response: %w", err)
+	}
+	return countResp.Result.Count, nil
+}
+
 // Search performs a vector similarity search.
 func (c *Client) Search(ctx context.Context, vector []float32, topK int) ([]SearchResult, error) {
+	return c.SearchWithFilter(ctx, vector, topK, nil)
+}
+
+// SearchWithFilter performs a vector similarity search restricted by a Qdrant
+// payload filter. A nil filter searches the whole collection.
+func (c *Client) SearchWithFilter(ctx context.Context, vector []float32, topK int, filter map[string]interface{}) ([]SearchResult, error) {
+	return c.search(ctx, vector, topK, filter, false)
+}
+
+// SearchWithVectors is like SearchWithFilter but also returns each point's
+// stored vector.
+func (c *Client) SearchWithVectors(ctx context.Context, vector []float32, topK int, filter map[string]interface{}) ([]SearchResult, error) {
+	return c.search(ctx, vector, topK, filter, true)
+}
+
+func (c *Client) search(ctx context.Context, vector []float32, topK int, filter map[string]interface{}, withVector bool) ([]SearchResult, error) {
 	searchReq := map[string]interface{}{
-		"vector":       vector,
+		"vector":       c.queryVector(vector),
 		"limit":        topK,
 		"with_payload": true,
+		"with_vector":  c.withVector(withVector),
 	}
+	if filter != nil {
+		searchReq["filter"] = filter
+	}
+	return c.doSearch(ctx, searchReq)
+}
 
+func (c *Client) doSearch(ctx context.Context, searchReq map[string]interface{}) ([]SearchResult, error) {
 	body, _ := json.Marshal(searchReq)
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
 		fmt.Sprintf("%s/collections/%s/points/search", c.baseURL, c.collectionName),
@@ -190,6 +342,7 @@ func (c *Client) Search(ctx context.Context, vector []float32, topK int) ([]Sear
 			ID      interface{}            `json:"id"`
 			Score   float32                `json:"score"`
 			Payload map[string]interface{} `json:"payload"`
+			Vector  json.RawMessage        `json:"vector"`
 		} `json:"result"`
 	}
 
@@ -210,12 +363,229 @@ func (c *Client) Search(ctx context.Context, vector []float32, topK int) ([]Sear
 			ID:      id,
 			Score:   r.Score,
 			Payload: r.Payload,
+			Vector:  decodeVector(r.Vector),
 		}
 	}
 
 	return results, nil
 }
 
+// ErrPointNotFound is returned by GetPoint when no point has the given ID.
+var ErrPointNotFound = errors.New("point not found")
+
+// GetPoint fetches a single point, with its payload and vector, by the
+// string ID it was upserted with.
+func (c *Client) GetPoint(ctx context.Context, id string) (*Point, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		fmt.Sprintf("%s/collections/%s/points/%d", c.baseURL, c.collectionName, NumericID(id)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get point: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrPointNotFound, id)
+	}
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("get point failed (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	var pointResp struct {
+		Result *struct {
+			Payload map[string]interface{} `json:"payload"`
+			Vector  json.RawMessage        `json:"vector"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&pointResp); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	if pointResp.Result == nil {
+		return nil, fmt.Errorf("%w: %s", ErrPointNotFound, id)
+	}
+
+	return &Point{
+		ID:      id,
+		Vector:  decodeVector(pointResp.Result.Vector),
+		Payload: pointResp.Result.Payload,
+	}, nil
+}
+
+// Scroll lists points in ID order, limit at a time. Pass a nil offset for
+// the first page and the returned offset for the next; a nil returned
+// offset means there are no more pages.
+func (c *Client) Scroll(ctx context.Context, offset *uint64, limit int, withPayload bool) ([]Point, *uint64, error) {
+	scrollReq := map[string]interface{}{
+		"limit":        limit,
+		"with_payload": withPayload,
+		"with_vector":  false,
+	}
+	if offset != nil {
+		scrollReq["offset"] = *offset
+	}
+
+	body, _ := json.Marshal(scrollReq)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		fmt.Sprintf("%s/collections/%s/points/scroll", c.baseURL, c.collectionName),
+		bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("scroll: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, nil, fmt.Errorf("scroll failed (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	var scrollResp struct {
+		Result struct {
+			Points []struct {
+				ID      json.Number            `json:"id"`
+				Payload map[string]interface{} `json:"payload"`
+			} `json:"points"`
+			NextPageOffset *uint64 `json:"next_page_offset"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&scrollResp); err != nil {
+		return nil, nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	points := make([]Point, len(scrollResp.Result.Points))
+	for i, p := range scrollResp.Result.Points {
+		id := p.ID.String()
+		if idVal, ok := p.Payload["id"].(string); ok {
+			id = idVal
+		}
+		points[i] = Point{ID: id, Payload: p.Payload}
+	}
+	return points, scrollResp.Result.NextPageOffset, nil
+}
+
+// Ping checks that Qdrant is reachable.
+func (c *Client) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/", nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("ping: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ping failed (status %d)", resp.StatusCode)
+	}
+	return nil
+}
+
+// CheckCollection verifies that the configured collection is reachable.
+func (c *Client) CheckCollection(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		fmt.Sprintf("%s/collections/%s", c.baseURL, c.collectionName), nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("check collection: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("collection %s unavailable (status %d)", c.collectionName, resp.StatusCode)
+	}
+	return nil
+}
+
+// ErrDimensionMismatch is returned when the collection's vector size differs
+// from the configured embedding dimension.
+var ErrDimensionMismatch = errors.New("embedding dimension mismatch")
+
+// Dimension returns the vector size the client was configured with.
+func (c *Client) Dimension() int {
+	return c.vectorSize
+}
+
+// CollectionDimension returns the vector size of the existing collection.
+func (c *Client) CollectionDimension(ctx context.Context) (int, error) {
+	info, err := c.CollectionInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if info.VectorSize == 0 {
+		if c.hybrid {
+			return 0, fmt.Errorf("collection %s has no %q named vector; recreate it with hybrid search enabled", c.collectionName, denseVectorName)
+		}
+		return 0, fmt.Errorf("collection %s has no unnamed vector config", c.collectionName)
+	}
+	return info.VectorSize, nil
+}
+
+// ValidateDimension checks that the collection's vector size matches the
+// configured one.
+func (c *Client) ValidateDimension(ctx context.Context) error {
+	size, err := c.CollectionDimension(ctx)
+	if err != nil {
+		return err
+	}
+	if size != c.vectorSize {
+		return fmt.Errorf("%w: collection %s has vector size %d but EMBEDDING_DIM is %d; fix EMBEDDING_DIM or recreate the collection",
+			ErrDimensionMismatch, c.collectionName, size, c.vectorSize)
+	}
+	return nil
+}
+
+// WaitReady pings Qdrant with exponential backoff until it responds or
+// timeout elapses.
+func (c *Client) WaitReady(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	backoff := 500 * time.Millisecond
+	const maxBackoff = 5 * time.Second
+
+	for attempt := 1; ; attempt++ {
+		err := c.Ping(ctx)
+		if err == nil {
+			if attempt > 1 {
+				log.Printf("Qdrant ready after %d attempts", attempt)
+			}
+			return nil
+		}
+		if errors.Is(err, ErrPlaintextServer) {
+			// Retrying won't change the server's protocol
+			return err
+		}
+		log.Printf("Qdrant not ready (attempt %d): %v, retrying in %v", attempt, err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("qdrant not ready after %v: %w", timeout, err)
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
 // Close closes the client (no-op for HTTP client).
 func (c *Client) Close() error {
 	return nil
@@ -0,0 +1,81 @@
+package vector
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client for a fake Qdrant served by handler.
+func newTestClient(t *testing.T, handler http.Handler, opts ...ClientOption) *Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	host, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpPort, _ := strconv.Atoi(port)
+	c, err := NewClient(host, httpPort, "kb", 3, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestWaitReady(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int32
+		timeout      time.Duration
+		wantErr      bool
+		wantAttempts int32
+	}{
+		{name: "ready", failures: 0, timeout: time.Second, wantAttempts: 1},
+		{name: "ready after two failed attempts", failures: 2, timeout: 5 * time.Second, wantAttempts: 3},
+		{name: "never ready", failures: 1000, timeout: 200 * time.Millisecond, wantErr: true, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if attempts.Add(1) <= tt.failures {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.Write([]byte(`{"title":"qdrant"}`))
+			}))
+
+			err := c.WaitReady(context.Background(), tt.timeout)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("WaitReady error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("%d attempts, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestWaitReadyStopsOnContext(t *testing.T) {
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := c.WaitReady(ctx, time.Minute)
+	if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		t.Fatalf("WaitReady error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("WaitReady took %v after its context ended", elapsed)
+	}
+}
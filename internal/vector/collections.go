@@ -0,0 +1,31 @@
+package vector
+
+// ForCollection returns a client for another collection on the same Qdrant
+// server. It shares c's connection pool and settings, including the vector
+// size and hybrid mode.
+func (c *Client) ForCollection(name string) *Client {
+	clone := *c
+	clone.collectionName = name
+	return &clone
+}
+
+// ForModules returns a client per module from a module to collection
+// mapping, for knowledge bases that keep each module in its own collection.
+// Modules mapped to the same collection share a client, and modules mapped
+// to c's collection get c itself, so each collection has one client.
+func (c *Client) ForModules(collections map[string]string) map[string]VectorStore {
+	clients := map[string]*Client{c.collectionName: c}
+	stores := make(map[string]VectorStore, len(collections))
+	for module, name := range collections {
+		if _, ok := clients[name]; !ok {
+			clients[name] = c.ForCollection(name)
+		}
+		stores[module] = clients[name]
+	}
+	return stores
+}
+
+// CollectionName returns the collection the client reads and writes.
+func (c *Client) CollectionName() string {
+	return c.collectionName
+}
@@ -0,0 +1,94 @@
+package vector
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MatchAny returns a condition matching points whose payload field equals
+// any of values. For array fields it matches when any element is in values.
+func MatchAny(field string, values []string) map[string]interface{} {
+	if len(values) == 1 {
+		return map[string]interface{}{
+			"key":   field,
+			"match": map[string]interface{}{"value": values[0]},
+		}
+	}
+	return map[string]interface{}{
+		"key":   field,
+		"match": map[string]interface{}{"any": values},
+	}
+}
+
+// MustFilter combines conditions into a filter that requires all of them.
+// It returns nil when there are no conditions.
+func MustFilter(conditions ...map[string]interface{}) map[string]interface{} {
+	if len(conditions) == 0 {
+		return nil
+	}
+	must := make([]interface{}, len(conditions))
+	for i, c := range conditions {
+		must[i] = c
+	}
+	return map[string]interface{}{"must": must}
+}
+
+// MustNotFilter returns a filter excluding points that match any of the
+// conditions.
+func MustNotFilter(conditions ...map[string]interface{}) map[string]interface{} {
+	if len(conditions) == 0 {
+		return nil
+	}
+	mustNot := make([]interface{}, len(conditions))
+	for i, c := range conditions {
+		mustNot[i] = c
+	}
+	return map[string]interface{}{"must_not": mustNot}
+}
+
+// MatchText returns a condition matching points whose text payload field
+// contains every word of text. It needs a "text" payload index on field;
+// without one Qdrant falls back to an exact substring match.
+func MatchText(field, text string) map[string]interface{} {
+	return map[string]interface{}{
+		"key":   field,
+		"match": map[string]interface{}{"text": text},
+	}
+}
+
+// AnyWordFilter returns a filter requiring all of must and at least one
+// word of text in the text payload field, as opposed to MatchText, which
+// requires every word. It returns nil when there are no conditions.
+func AnyWordFilter(field, text string, must ...map[string]interface{}) map[string]interface{} {
+	filter := MustFilter(must...)
+	var should []interface{}
+	seen := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if !seen[word] {
+			seen[word] = true
+			should = append(should, MatchText(field, word))
+		}
+	}
+	if len(should) == 0 {
+		return filter
+	}
+	if filter == nil {
+		filter = make(map[string]interface{})
+	}
+	filter["should"] = should
+	return filter
+}
+
+// NormalizeRoles lowercases and trims roles, so the roles stored on points
+// and the roles queries filter by match regardless of case.
+func NormalizeRoles(roles []string) []string {
+	normalized := make([]string, 0, len(roles))
+	for _, role := range roles {
+		if role = strings.ToLower(strings.TrimSpace(role)); role != "" {
+			normalized = append(normalized, role)
+		}
+	}
+	return normalized
+}
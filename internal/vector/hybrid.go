@@ -0,0 +1,214 @@
+package vector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+)
+
+// Names of the vectors stored on each point in hybrid mode.
+const (
+	denseVectorName  = "dense"
+	sparseVectorName = "sparse"
+)
+
+// rrfK dampens the weight of top ranks in reciprocal rank fusion; 60 is the
+// value from the original RRF paper.
+const rrfK = 60
+
+// SparseVector is a keyword vector: token hashes and their weights.
+type SparseVector struct {
+	Indices []uint32  `json:"indices"`
+	Values  []float32 `json:"values"`
+}
+
+// WithHybrid stores a dense and a sparse vector per point, as Qdrant named
+// vectors, and enables HybridSearch. The collection must have been created
+// in the same mode.
+func WithHybrid(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.hybrid = enabled
+	}
+}
+
+// Hybrid reports whether the client uses named dense and sparse vectors.
+func (c *Client) Hybrid() bool {
+	return c.hybrid
+}
+
+// EncodeSparse turns text into a term-frequency sparse vector over hashed,
+// lowercased tokens. Qdrant applies IDF weighting at search time.
+func EncodeSparse(text string) SparseVector {
+	counts := make(map[uint32]float32)
+	for _, token := range textWords(text) {
+		h := fnv.New32a()
+		h.Write([]byte(token))
+		counts[h.Sum32()]++
+	}
+
+	sparse := SparseVector{
+		Indices: make([]uint32, 0, len(counts)),
+		Values:  make([]float32, 0, len(counts)),
+	}
+	for index := range counts {
+		sparse.Indices = append(sparse.Indices, index)
+	}
+	sort.Slice(sparse.Indices, func(i, j int) bool { return sparse.Indices[i] < sparse.Indices[j] })
+	for _, index := range sparse.Indices {
+		sparse.Values = append(sparse.Values, counts[index])
+	}
+	return sparse
+}
+
+// collectionConfig is the body used to create the collection.
+func (c *Client) collectionConfig() map[string]interface{} {
+	dense := map[string]interface{}{
+		"size":     c.vectorSize,
+		"distance": "Cosine",
+	}
+	if !c.hybrid {
+		return map[string]interface{}{"vectors": dense}
+	}
+	return map[string]interface{}{
+		"vectors": map[string]interface{}{denseVectorName: dense},
+		"sparse_vectors": map[string]interface{}{
+			sparseVectorName: map[string]interface{}{"modifier": "idf"},
+		},
+	}
+}
+
+// pointVectors returns the vector field of a point for upserting.
+func (c *Client) pointVectors(p Point) interface{} {
+	if !c.hybrid {
+		return p.Vector
+	}
+	vectors := map[string]interface{}{denseVectorName: p.Vector}
+	if p.Sparse != nil && len(p.Sparse.Indices) > 0 {
+		vectors[sparseVectorName] = p.Sparse
+	}
+	return vectors
+}
+
+// queryVector returns the vector field of a dense search request.
+func (c *Client) queryVector(v []float32) interface{} {
+	if !c.hybrid {
+		return v
+	}
+	return map[string]interface{}{"name": denseVectorName, "vector": v}
+}
+
+// withVector returns the with_vector field of a request. Only the dense
+// vector is fetched in hybrid mode.
+func (c *Client) withVector(enabled bool) interface{} {
+	if !enabled || !c.hybrid {
+		return enabled
+	}
+	return []string{denseVectorName}
+}
+
+// decodeVector reads a point's dense vector from either the unnamed or the
+// named vector format.
+func decodeVector(raw json.RawMessage) []float32 {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	var vector []float32
+	if err := json.Unmarshal(raw, &vector); err == nil {
+		return vector
+	}
+	var named struct {
+		Dense []float32 `json:"dense"`
+	}
+	json.Unmarshal(raw, &named)
+	return named.Dense
+}
+
+// HybridSearch runs a dense and a sparse search and fuses the two rankings
+// with reciprocal rank fusion. Fusion decides which points make the top K;
+// each result's Score stays its dense cosine similarity so score thresholds
+// keep their meaning.
+func (c *Client) HybridSearch(ctx context.Context, dense []float32, sparse SparseVector, topK int, filter map[string]interface{}, withVector bool) ([]SearchResult, error) {
+	if !c.hybrid {
+		return nil, fmt.Errorf("hybrid search: collection %s is not in hybrid mode", c.collectionName)
+	}
+
+	denseResults, err := c.search(ctx, dense, topK, filter, withVector)
+	if err != nil {
+		return nil, err
+	}
+	if len(sparse.Indices) == 0 {
+		return denseResults, nil
+	}
+
+	// The dense vector is needed to score points only the sparse search found
+	sparseReq := map[string]interface{}{
+		"vector":       map[string]interface{}{"name": sparseVectorName, "vector": sparse},
+		"limit":        topK,
+		"with_payload": true,
+		"with_vector":  c.withVector(true),
+	}
+	if filter != nil {
+		sparseReq["filter"] = filter
+	}
+	sparseResults, err := c.doSearch(ctx, sparseReq)
+	if err != nil {
+		return nil, fmt.Errorf("sparse %w", err)
+	}
+
+	return fuseRRF(denseResults, sparseResults, dense, topK, withVector), nil
+}
+
+// fuseRRF merges two rankings by reciprocal rank fusion and keeps the topK
+// best fused points.
+func fuseRRF(dense, sparse []SearchResult, query []float32, topK int, withVector bool) []SearchResult {
+	fused := make(map[string]float64)
+	byID := make(map[string]SearchResult)
+	for rank, r := range dense {
+		fused[r.ID] += 1 / float64(rrfK+rank+1)
+		byID[r.ID] = r
+	}
+	for rank, r := range sparse {
+		fused[r.ID] += 1 / float64(rrfK+rank+1)
+		if _, ok := byID[r.ID]; !ok {
+			r.Score = cosineSimilarity(query, r.Vector)
+			if !withVector {
+				r.Vector = nil
+			}
+			byID[r.ID] = r
+		}
+	}
+
+	results := make([]SearchResult, 0, len(byID))
+	for _, r := range byID {
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if fused[results[i].ID] != fused[results[j].ID] {
+			return fused[results[i].ID] > fused[results[j].ID]
+		}
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > topK {
+		results = results[:topK]
+	}
+	return results
+}
+
+func cosineSimilarity(a, b []float32) float32 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB)))
+}
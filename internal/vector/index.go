@@ -0,0 +1,97 @@
+package vector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+)
+
+// DefaultPayloadIndexes are the payload fields filtered on by retrieval.
+var DefaultPayloadIndexes = map[string]string{
+	"module": "keyword",
+	"roles":  "keyword",
+}
+
+// WithPayloadIndexes sets the payload fields, mapped to their Qdrant schema
+// type (e.g. "keyword"), that EnsureCollection indexes. Without an index,
+// filtering on a field scans the whole collection.
+func WithPayloadIndexes(indexes map[string]string) ClientOption {
+	return func(c *Client) {
+		c.payloadIndexes = indexes
+	}
+}
+
+// CreatePayloadIndex indexes a payload field. Creating an index that already
+// exists is a no-op.
+func (c *Client) CreatePayloadIndex(ctx context.Context, field, schema string) error {
+	body, _ := json.Marshal(map[string]interface{}{
+		"field_name":   field,
+		"field_schema": schema,
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut,
+		fmt.Sprintf("%s/collections/%s/index?wait=true", c.baseURL, c.collectionName),
+		bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("create payload index: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("create payload index %s failed (status %d): %s", field, resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// PayloadSchema returns the collection's indexed payload fields and their
+// schema types.
+func (c *Client) PayloadSchema(ctx context.Context) (map[string]string, error) {
+	info, err := c.CollectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return info.PayloadSchema, nil
+}
+
+// ensurePayloadIndexes creates the configured payload indexes and checks
+// that Qdrant reports them afterwards.
+func (c *Client) ensurePayloadIndexes(ctx context.Context) error {
+	if len(c.payloadIndexes) == 0 {
+		return nil
+	}
+
+	fields := make([]string, 0, len(c.payloadIndexes))
+	for field := range c.payloadIndexes {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		if err := c.CreatePayloadIndex(ctx, field, c.payloadIndexes[field]); err != nil {
+			return err
+		}
+	}
+
+	schema, err := c.PayloadSchema(ctx)
+	if err != nil {
+		return fmt.Errorf("check payload indexes: %w", err)
+	}
+	for _, field := range fields {
+		if _, ok := schema[field]; !ok {
+			return fmt.Errorf("payload index on %s missing after creation", field)
+		}
+	}
+	log.Printf("Payload indexes ready on %v", fields)
+	return nil
+}
@@ -0,0 +1,113 @@
+package vector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// fakeQdrant serves the collection and payload index endpoints used by
+// EnsureCollection.
+type fakeQdrant struct {
+	mu      sync.Mutex
+	exists  bool
+	indexes map[string]string
+	// ignore lists fields whose index requests succeed without an index
+	// being created.
+	ignore map[string]bool
+}
+
+func (q *fakeQdrant) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/collections/kb":
+		if !q.exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		schema := map[string]interface{}{}
+		for field, typ := range q.indexes {
+			schema[field] = map[string]string{"data_type": typ}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"result": map[string]interface{}{"status": "green", "payload_schema": schema},
+		})
+	case r.Method == http.MethodPut && r.URL.Path == "/collections/kb":
+		q.exists = true
+		w.Write([]byte(`{"result":true}`))
+	case r.Method == http.MethodPut && r.URL.Path == "/collections/kb/index":
+		var req struct {
+			FieldName   string `json:"field_name"`
+			FieldSchema string `json:"field_schema"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if !q.ignore[req.FieldName] {
+			q.indexes[req.FieldName] = req.FieldSchema
+		}
+		w.Write([]byte(`{"result":{"status":"completed"}}`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestEnsureCollectionCreatesPayloadIndexes(t *testing.T) {
+	tests := []struct {
+		name    string
+		exists  bool
+		opts    []ClientOption
+		ignore  map[string]bool
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "new collection",
+			want: DefaultPayloadIndexes,
+		},
+		{
+			name:   "existing collection",
+			exists: true,
+			want:   DefaultPayloadIndexes,
+		},
+		{
+			name: "configured fields",
+			opts: []ClientOption{WithPayloadIndexes(map[string]string{"module": "keyword", "topic": "text"})},
+			want: map[string]string{"module": "keyword", "topic": "text"},
+		},
+		{
+			name: "indexing disabled",
+			opts: []ClientOption{WithPayloadIndexes(nil)},
+			want: map[string]string{},
+		},
+		{
+			name:    "index missing after creation",
+			ignore:  map[string]bool{"roles": true},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qdrant := &fakeQdrant{exists: tt.exists, indexes: map[string]string{}, ignore: tt.ignore}
+			c := newTestClient(t, qdrant, tt.opts...)
+
+			err := c.EnsureCollection(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EnsureCollection error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			schema, err := c.PayloadSchema(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(schema, tt.want) {
+				t.Errorf("payload indexes = %v, want %v", schema, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package vector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// CollectionInfo summarizes the state of the collection.
+type CollectionInfo struct {
+	Name string `json:"name"`
+	// Status is Qdrant's health status: green, yellow (optimizing) or red.
+	Status              string `json:"status"`
+	OptimizerStatus     string `json:"optimizer_status"`
+	PointsCount         int    `json:"points_count"`
+	IndexedVectorsCount int    `json:"indexed_vectors_count"`
+	SegmentsCount       int    `json:"segments_count"`
+	// VectorSize is the size of the dense vector the client searches; zero
+	// if the collection has none in the client's mode.
+	VectorSize int  `json:"vector_size"`
+	Hybrid     bool `json:"hybrid"`
+	// PayloadSchema maps indexed payload fields to their schema type.
+	PayloadSchema map[string]string `json:"payload_schema"`
+}
+
+// CollectionInfo fetches the collection's point count, vector size, payload
+// indexes and indexing status.
+func (c *Client) CollectionInfo(ctx context.Context) (*CollectionInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		fmt.Sprintf("%s/collections/%s", c.baseURL, c.collectionName), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get collection: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("get collection failed (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	var infoResp struct {
+		Result struct {
+			Status              string          `json:"status"`
+			OptimizerStatus     json.RawMessage `json:"optimizer_status"`
+			PointsCount         int             `json:"points_count"`
+			IndexedVectorsCount int             `json:"indexed_vectors_count"`
+			SegmentsCount       int             `json:"segments_count"`
+			Config              struct {
+				Params struct {
+					Vectors struct {
+						Size  int `json:"size"`
+						Dense struct {
+							Size int `json:"size"`
+						} `json:"dense"`
+					} `json:"vectors"`
+				} `json:"params"`
+			} `json:"config"`
+			PayloadSchema map[string]struct {
+				DataType string `json:"data_type"`
+			} `json:"payload_schema"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&infoResp); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	result := infoResp.Result
+
+	info := &CollectionInfo{
+		Name:                c.collectionName,
+		Status:              result.Status,
+		OptimizerStatus:     optimizerStatus(result.OptimizerStatus),
+		PointsCount:         result.PointsCount,
+		IndexedVectorsCount: result.IndexedVectorsCount,
+		SegmentsCount:       result.SegmentsCount,
+		VectorSize:          result.Config.Params.Vectors.Size,
+		Hybrid:              c.hybrid,
+		PayloadSchema:       make(map[string]string, len(result.PayloadSchema)),
+	}
+	if c.hybrid {
+		info.VectorSize = result.Config.Params.Vectors.Dense.Size
+	}
+	for field, schema := range result.PayloadSchema {
+		info.PayloadSchema[field] = schema.DataType
+	}
+	return info, nil
+}
+
+// optimizerStatus flattens Qdrant's optimizer status, which is either "ok"
+// or an object like {"error": "..."}.
+func optimizerStatus(raw json.RawMessage) string {
+	var status string
+	if err := json.Unmarshal(raw, &status); err == nil {
+		return status
+	}
+	var failed struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &failed); err == nil && failed.Error != "" {
+		return "error: " + failed.Error
+	}
+	return ""
+}
@@ -0,0 +1,265 @@
+package vector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// MemoryStore is an in-memory VectorStore that searches by brute-force
+// cosine similarity. It understands the filters built by MatchAny,
+// MatchText, MustFilter and MustNotFilter.
+type MemoryStore struct {
+	vectorSize int
+
+	mu     sync.RWMutex
+	points map[string]Point
+}
+
+// NewMemoryStore creates an empty store for vectors of vectorSize.
+func NewMemoryStore(vectorSize int) *MemoryStore {
+	return &MemoryStore{
+		vectorSize: vectorSize,
+		points:     make(map[string]Point),
+	}
+}
+
+// EnsureCollection is a no-op; the store always exists.
+func (m *MemoryStore) EnsureCollection(ctx context.Context) error {
+	return nil
+}
+
+// UpsertPoints inserts or replaces points by ID.
+func (m *MemoryStore) UpsertPoints(ctx context.Context, points []Point) error {
+	for _, p := range points {
+		if len(p.Vector) != m.vectorSize {
+			return fmt.Errorf("upsert %s: %w: vector has %d dimensions, store has %d",
+				p.ID, ErrDimensionMismatch, len(p.Vector), m.vectorSize)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, p := range points {
+		m.points[p.ID] = p
+	}
+	return nil
+}
+
+// DeleteByFilter removes all points matching filter.
+func (m *MemoryStore) DeleteByFilter(ctx context.Context, filter map[string]interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, p := range m.points {
+		if matchesFilter(p.Payload, filter) {
+			delete(m.points, id)
+		}
+	}
+	return nil
+}
+
+// Count returns the number of points matching filter.
+func (m *MemoryStore) Count(ctx context.Context, filter map[string]interface{}) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	count := 0
+	for _, p := range m.points {
+		if matchesFilter(p.Payload, filter) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Search returns the topK points most similar to vector.
+func (m *MemoryStore) Search(ctx context.Context, vector []float32, topK int) ([]SearchResult, error) {
+	return m.search(vector, topK, nil, false), nil
+}
+
+// SearchWithFilter is Search restricted by a payload filter.
+func (m *MemoryStore) SearchWithFilter(ctx context.Context, vector []float32, topK int, filter map[string]interface{}) ([]SearchResult, error) {
+	return m.search(vector, topK, filter, false), nil
+}
+
+// SearchWithVectors is SearchWithFilter that also returns each point's
+// vector.
+func (m *MemoryStore) SearchWithVectors(ctx context.Context, vector []float32, topK int, filter map[string]interface{}) ([]SearchResult, error) {
+	return m.search(vector, topK, filter, true), nil
+}
+
+// HybridSearch always fails; the store keeps dense vectors only.
+func (m *MemoryStore) HybridSearch(ctx context.Context, dense []float32, sparse SparseVector, topK int, filter map[string]interface{}, withVector bool) ([]SearchResult, error) {
+	return nil, fmt.Errorf("hybrid search: memory store is not in hybrid mode")
+}
+
+// Hybrid reports false; the store keeps dense vectors only.
+func (m *MemoryStore) Hybrid() bool {
+	return false
+}
+
+// Dimension returns the store's vector size.
+func (m *MemoryStore) Dimension() int {
+	return m.vectorSize
+}
+
+// Close is a no-op.
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+func (m *MemoryStore) search(vector []float32, topK int, filter map[string]interface{}, withVector bool) []SearchResult {
+	m.mu.RLock()
+	results := make([]SearchResult, 0, len(m.points))
+	for _, p := range m.points {
+		if !matchesFilter(p.Payload, filter) {
+			continue
+		}
+		id := p.ID
+		if idVal, ok := p.Payload["id"].(string); ok {
+			id = idVal
+		}
+		r := SearchResult{
+			ID:      id,
+			Score:   cosineSimilarity(vector, p.Vector),
+			Payload: p.Payload,
+		}
+		if withVector {
+			r.Vector = p.Vector
+		}
+		results = append(results, r)
+	}
+	m.mu.RUnlock()
+
+	// Break ties by ID so results are deterministic
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	if len(results) > topK {
+		results = results[:topK]
+	}
+	return results
+}
+
+// matchesFilter evaluates a Qdrant-style filter with must, must_not and
+// should clauses of key/match conditions against a payload. A nil filter
+// matches everything.
+func matchesFilter(payload map[string]interface{}, filter map[string]interface{}) bool {
+	if filter == nil {
+		return true
+	}
+	for _, c := range conditions(filter["must"]) {
+		if !matchesCondition(payload, c) {
+			return false
+		}
+	}
+	for _, c := range conditions(filter["must_not"]) {
+		if matchesCondition(payload, c) {
+			return false
+		}
+	}
+	if should := conditions(filter["should"]); len(should) > 0 {
+		for _, c := range should {
+			if matchesCondition(payload, c) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func conditions(clause interface{}) []map[string]interface{} {
+	items, _ := clause.([]interface{})
+	conds := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		if c, ok := item.(map[string]interface{}); ok {
+			conds = append(conds, c)
+		}
+	}
+	return conds
+}
+
+// matchesCondition reports whether the payload field named by the
+// condition's key equals its match value, or any of its match values. Array
+// fields match when any element does.
+func matchesCondition(payload map[string]interface{}, cond map[string]interface{}) bool {
+	key, _ := cond["key"].(string)
+	match, _ := cond["match"].(map[string]interface{})
+
+	if text, ok := match["text"].(string); ok {
+		for _, have := range fieldValues(payload[key]) {
+			if containsWords(have, text) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var wanted []string
+	if value, ok := match["value"]; ok {
+		wanted = append(wanted, fmt.Sprint(value))
+	}
+	switch values := match["any"].(type) {
+	case []string:
+		wanted = append(wanted, values...)
+	case []interface{}:
+		for _, v := range values {
+			wanted = append(wanted, fmt.Sprint(v))
+		}
+	}
+
+	for _, have := range fieldValues(payload[key]) {
+		for _, w := range wanted {
+			if have == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsWords reports whether text contains every word of words, ignoring
+// case and punctuation, like a Qdrant full-text match.
+func containsWords(text, words string) bool {
+	have := make(map[string]bool)
+	for _, w := range textWords(text) {
+		have[w] = true
+	}
+	for _, w := range textWords(words) {
+		if !have[w] {
+			return false
+		}
+	}
+	return true
+}
+
+// textWords splits text into lowercased words.
+func textWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// fieldValues flattens a payload value into strings.
+func fieldValues(value interface{}) []string {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []string:
+		return v
+	case []interface{}:
+		values := make([]string, len(v))
+		for i, item := range v {
+			values[i] = fmt.Sprint(item)
+		}
+		return values
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
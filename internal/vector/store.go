@@ -0,0 +1,41 @@
+package vector
+
+import "context"
+
+// VectorStore is the vector database the RAG and ingest services work
+// against. Client implements it on Qdrant; MemoryStore implements it in
+// memory for tests and local experiments.
+type VectorStore interface {
+	// EnsureCollection creates the collection if it doesn't exist.
+	EnsureCollection(ctx context.Context) error
+	// UpsertPoints inserts or replaces points by ID.
+	UpsertPoints(ctx context.Context, points []Point) error
+	// DeleteByFilter removes all points matching a payload filter.
+	DeleteByFilter(ctx context.Context, filter map[string]interface{}) error
+	// Count returns the number of points matching a payload filter; nil
+	// counts every point.
+	Count(ctx context.Context, filter map[string]interface{}) (int, error)
+
+	// Search returns the topK points most similar to vector.
+	Search(ctx context.Context, vector []float32, topK int) ([]SearchResult, error)
+	// SearchWithFilter is Search restricted by a payload filter.
+	SearchWithFilter(ctx context.Context, vector []float32, topK int, filter map[string]interface{}) ([]SearchResult, error)
+	// SearchWithVectors is SearchWithFilter that also returns each point's
+	// vector.
+	SearchWithVectors(ctx context.Context, vector []float32, topK int, filter map[string]interface{}) ([]SearchResult, error)
+	// HybridSearch fuses dense and sparse search; it fails unless Hybrid
+	// reports true.
+	HybridSearch(ctx context.Context, dense []float32, sparse SparseVector, topK int, filter map[string]interface{}, withVector bool) ([]SearchResult, error)
+
+	// Hybrid reports whether points carry sparse vectors.
+	Hybrid() bool
+	// Dimension returns the configured dense vector size.
+	Dimension() int
+	// Close releases the store's resources.
+	Close() error
+}
+
+var (
+	_ VectorStore = (*Client)(nil)
+	_ VectorStore = (*MemoryStore)(nil)
+)
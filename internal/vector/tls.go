@@ -0,0 +1,60 @@
+package vector
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrPlaintextServer is returned when TLS is enabled but Qdrant answered
+// the handshake in plaintext.
+var ErrPlaintextServer = errors.New("qdrant server does not speak TLS")
+
+// ClientOption configures optional Client behaviour.
+type ClientOption func(*Client)
+
+// WithTLS makes the client talk to Qdrant over HTTPS.
+func WithTLS(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.useTLS = enabled
+	}
+}
+
+// WithAPIKey sends key in the api-key header on every request, as Qdrant
+// Cloud requires. An empty key sends no header.
+func WithAPIKey(key string) ClientOption {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithTransport sends requests through t instead of http.DefaultTransport,
+// so the client can share a connection pool with the other clients.
+func WithTransport(t http.RoundTripper) ClientOption {
+	return func(c *Client) {
+		c.transport = t
+	}
+}
+
+// qdrantTransport adds the API key header and explains TLS handshakes
+// against plaintext servers.
+type qdrantTransport struct {
+	base   http.RoundTripper
+	apiKey string
+}
+
+func (t *qdrantTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.apiKey != "" {
+		// RoundTrippers must not modify the caller's request
+		req = req.Clone(req.Context())
+		req.Header.Set("api-key", t.apiKey)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	var recordErr tls.RecordHeaderError
+	if errors.As(err, &recordErr) {
+		return nil, fmt.Errorf("%w at %s; unset QDRANT_USE_TLS or check the port", ErrPlaintextServer, req.URL.Host)
+	}
+	return resp, err
+}
@@ -0,0 +1,30 @@
+// Package version reports which build is running. Version and Commit are
+// set at build time with -ldflags, e.g.
+//
+//	go build -ldflags "-X go-bot/internal/version.Version=v1.2.0 -X go-bot/internal/version.Commit=$(git rev-parse --short HEAD)"
+package version
+
+import "runtime/debug"
+
+var (
+	// Version is the release version of the build.
+	Version = "dev"
+	// Commit is the VCS revision of the build. When not set with -ldflags
+	// it falls back to the revision Go embeds when building from a checkout.
+	Commit = ""
+)
+
+// GetCommit returns Commit, or the embedded VCS revision, or "unknown".
+func GetCommit() string {
+	if Commit != "" {
+		return Commit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				return s.Value
+			}
+		}
+	}
+	return "unknown"
+}
@@ -5,14 +5,20 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"os"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
 type ChatRequest struct {
-	Query string `json:"query"`
+	Query  string `json:"query"`
+	Stream bool   `json:"stream,omitempty"`
 }
 
 func main() {
@@ -20,6 +26,8 @@ func main() {
 	url := flag.String("url", "http://localhost:8080/chat", "API endpoint")
 	concurrent := flag.Int("c", 10, "Number of concurrent users")
 	requests := flag.Int("n", 100, "Total number of requests")
+	stream := flag.Bool("stream", false, "Request streamed answers and report time to first byte")
+	queriesFile := flag.String("queries", "", "File of queries to cycle through: one per line, or a JSON array")
 	flag.Parse()
 
 	queries := []string{
@@ -29,23 +37,29 @@ func main() {
 		"What is the dashboard?",
 		"How do I reset my password?",
 	}
-
-	var (
-		successCount int64
-		failCount    int64
-		totalLatency int64
-		minLatency   int64 = 999999
-		maxLatency   int64
-		mu           sync.Mutex
-	)
+	if *queriesFile != "" {
+		loaded, err := loadQueries(*queriesFile)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		queries = loaded
+	}
 
 	fmt.Printf("🚀 Load Test Starting...\n")
 	fmt.Printf("   URL: %s\n", *url)
 	fmt.Printf("   Concurrent users: %d\n", *concurrent)
-	fmt.Printf("   Total requests: %d\n\n", *requests)
+	fmt.Printf("   Total requests: %d\n", *requests)
+	fmt.Printf("   Streaming: %t\n", *stream)
+	fmt.Printf("   Distinct queries: %d\n\n", len(queries))
 
 	startTime := time.Now()
 
+	// Each request writes only its own slot, so no locking is needed;
+	// stats are computed once every request is done.
+	results := make([]requestResult, *requests)
+	var completed int64
+
 	// Create a semaphore for concurrency control
 	sem := make(chan struct{}, *concurrent)
 	var wg sync.WaitGroup
@@ -63,40 +77,15 @@ func main() {
 			defer func() { <-sem }() // Release
 
 			query := queries[reqNum%len(queries)]
-			reqBody := ChatRequest{Query: query}
-			body, _ := json.Marshal(reqBody)
-
-			reqStart := time.Now()
-			resp, err := client.Post(*url, "application/json", bytes.NewReader(body))
-			latency := time.Since(reqStart).Milliseconds()
-
-			if err != nil {
-				atomic.AddInt64(&failCount, 1)
+			results[reqNum] = sendRequest(client, *url, ChatRequest{Query: query, Stream: *stream})
+			if err := results[reqNum].err; err != nil {
 				fmt.Printf("❌ Request %d failed: %v\n", reqNum+1, err)
-				return
-			}
-			defer resp.Body.Close()
-
-			if resp.StatusCode == 200 {
-				atomic.AddInt64(&successCount, 1)
-			} else {
-				atomic.AddInt64(&failCount, 1)
-				fmt.Printf("❌ Request %d: status %d\n", reqNum+1, resp.StatusCode)
-			}
-
-			atomic.AddInt64(&totalLatency, latency)
-
-			mu.Lock()
-			if latency < minLatency {
-				minLatency = latency
+			} else if status := results[reqNum].status; status != http.StatusOK {
+				fmt.Printf("❌ Request %d: status %d\n", reqNum+1, status)
 			}
-			if latency > maxLatency {
-				maxLatency = latency
-			}
-			mu.Unlock()
 
-			if (reqNum+1)%10 == 0 {
-				fmt.Printf("✓ Completed %d/%d requests\n", reqNum+1, *requests)
+			if n := atomic.AddInt64(&completed, 1); n%10 == 0 {
+				fmt.Printf("✓ Completed %d/%d requests\n", n, *requests)
 			}
 		}(i)
 	}
@@ -105,8 +94,38 @@ func main() {
 	totalTime := time.Since(startTime)
 
 	// Results
+	var (
+		successCount, failCount int
+		latencies, ttfbs        []int64
+		totalLatency            int64
+	)
+	statusCounts := make(map[int]int)
+	for _, r := range results {
+		if r.err != nil {
+			failCount++
+			statusCounts[0]++
+			continue
+		}
+		statusCounts[r.status]++
+		if r.status == http.StatusOK {
+			successCount++
+		} else {
+			failCount++
+		}
+		latencies = append(latencies, r.latency)
+		ttfbs = append(ttfbs, r.ttfb)
+		totalLatency += r.latency
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	sort.Slice(ttfbs, func(i, j int) bool { return ttfbs[i] < ttfbs[j] })
+
 	total := successCount + failCount
-	avgLatency := float64(totalLatency) / float64(total)
+	var avgLatency float64
+	var minLatency, maxLatency int64
+	if len(latencies) > 0 {
+		avgLatency = float64(totalLatency) / float64(len(latencies))
+		minLatency, maxLatency = latencies[0], latencies[len(latencies)-1]
+	}
 	rps := float64(total) / totalTime.Seconds()
 
 	fmt.Println("\n" + "══════════════════════════════════════════════════")
@@ -118,8 +137,115 @@ func main() {
 	fmt.Printf("Total Time:         %.2fs\n", totalTime.Seconds())
 	fmt.Printf("Requests/sec:       %.2f\n", rps)
 	fmt.Println("──────────────────────────────────────────────────")
+	codes := make([]int, 0, len(statusCounts))
+	for code := range statusCounts {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		label := fmt.Sprintf("Status %d:", code)
+		if code == 0 {
+			label = "No response:"
+		}
+		fmt.Printf("%-20s%d\n", label, statusCounts[code])
+	}
+	fmt.Println("──────────────────────────────────────────────────")
 	fmt.Printf("Avg Latency:        %.0fms\n", avgLatency)
 	fmt.Printf("Min Latency:        %dms\n", minLatency)
 	fmt.Printf("Max Latency:        %dms\n", maxLatency)
+	fmt.Println("──────────────────────────────────────────────────")
+	fmt.Printf("p50 Latency:        %dms\n", percentile(latencies, 50))
+	fmt.Printf("p90 Latency:        %dms\n", percentile(latencies, 90))
+	fmt.Printf("p95 Latency:        %dms\n", percentile(latencies, 95))
+	fmt.Printf("p99 Latency:        %dms\n", percentile(latencies, 99))
+	if *stream {
+		fmt.Println("──────────────────────────────────────────────────")
+		fmt.Printf("p50 TTFB:           %dms\n", percentile(ttfbs, 50))
+		fmt.Printf("p90 TTFB:           %dms\n", percentile(ttfbs, 90))
+		fmt.Printf("p95 TTFB:           %dms\n", percentile(ttfbs, 95))
+		fmt.Printf("p99 TTFB:           %dms\n", percentile(ttfbs, 99))
+	}
 	fmt.Println("══════════════════════════════════════════════════")
 }
+
+// requestResult is the outcome of one request. Latencies are in
+// milliseconds; err is set when no response was received.
+type requestResult struct {
+	status  int
+	latency int64
+	ttfb    int64
+	err     error
+}
+
+// sendRequest posts one chat request and times it. Time to first byte is
+// when the first answer byte arrives, not the headers; latency covers the
+// whole body.
+func sendRequest(client *http.Client, url string, req ChatRequest) requestResult {
+	body, _ := json.Marshal(req)
+
+	start := time.Now()
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return requestResult{err: err}
+	}
+	defer resp.Body.Close()
+
+	first := make([]byte, 1)
+	_, err = io.ReadFull(resp.Body, first)
+	ttfb := time.Since(start).Milliseconds()
+	if err == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+	}
+	if err != nil && err != io.EOF {
+		return requestResult{err: fmt.Errorf("reading body: %w", err)}
+	}
+
+	return requestResult{
+		status:  resp.StatusCode,
+		latency: time.Since(start).Milliseconds(),
+		ttfb:    ttfb,
+	}
+}
+
+// loadQueries reads queries from a JSON array of strings or, failing that,
+// one query per line. Blank lines are skipped.
+func loadQueries(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read queries: %w", err)
+	}
+
+	var queries []string
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal(data, &queries); err != nil {
+			return nil, fmt.Errorf("parse queries %s: %w", path, err)
+		}
+	} else {
+		for _, line := range strings.Split(trimmed, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				queries = append(queries, line)
+			}
+		}
+	}
+
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("no queries in %s", path)
+	}
+	return queries, nil
+}
+
+// percentile returns the p-th percentile of sorted latencies using the
+// nearest-rank method.
+func percentile(sorted []int64, p float64) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}